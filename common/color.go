@@ -196,3 +196,30 @@ func RandColorScale(p, max, h, s, minB float64) *Color {
 	nc := hsv2rgb(c)
 	return &Color{r: int(255 * nc.r), g: int(255 * nc.g), b: int(255 * nc.b)}
 }
+
+// Hex returns the hexadecimal representation of this color (e.g. #ff0000 for red).
+func (c *Color) Hex() string { return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b) }
+
+// HeatColor returns the color of point p in a heatmap scale over the interval [0,1], going from
+// blue (p=0) to red (p=1) through green. Points outside [0,1] are clamped.
+func HeatColor(p float64) *Color {
+	if p < 0 || p != p {
+		p = 0
+	} else if p > 1 {
+		p = 1
+	}
+	nc := hsv2rgb(hsv{h: 240 * (1 - p), s: 0.75, v: 1})
+	return &Color{r: int(255 * nc.r), g: int(255 * nc.g), b: int(255 * nc.b)}
+}
+
+// GrayColor returns the color of point p in a grayscale over the interval [0,1], going from white
+// (p=0) to black (p=1). Points outside [0,1] are clamped.
+func GrayColor(p float64) *Color {
+	if p < 0 || p != p {
+		p = 0
+	} else if p > 1 {
+		p = 1
+	}
+	v := int(255 * (1 - p))
+	return NewColor(v, v, v)
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/RenatoGeh/gospn/learn"
+	"github.com/RenatoGeh/gospn/spn"
 	"github.com/RenatoGeh/gospn/utils"
 	"os"
 	"path/filepath"
@@ -169,8 +170,8 @@ func ARFFToData(dirname, fname, dname string) {
 // 	% We modified variable Winter, changing it to Season and made it into a numeric (yet
 // 	% categorical) variable just to showcase how we deal with numeric variables.
 // 	@RELATION weather
-// 	% GoSPN doesn't (yet) support continuous variables. It does accept discrete values sent as
-// 	% numeric type. In this case we assume a variable season that is discrete and has 4 possible
+// 	% ParseArff only supports discrete variables (see ParseArffF for continuous ones). It does
+// 	% accept discrete values sent as numeric type. In this case we assume a variable season that is discrete and has 4 possible
 // 	% values: 0, 1, 2, 3 with 0-3 being numeric representations for spring-winter.
 // 	@ATTRIBUTE season NUMERIC
 // 	% We can also use the numeric type as boolean.
@@ -193,9 +194,34 @@ func ARFFToData(dirname, fname, dname string) {
 //  3,1,false,true,false
 //
 // For numeric variables, we take the highest value in the dataset and set this value as the
-// categorical upper bound of the variable.
+// categorical upper bound of the variable. Numeric variables are ordinal (see learn.VarType), while
+// class and string variables are categorical.
 func ParseArff(filename string) (name string, sc map[int]*learn.Variable, vals []map[int]int,
 	labels map[int]map[string]int) {
+	name, sc, F, labels := parseArff(filename, false)
+	vals = make([]map[int]int, len(F))
+	for i, I := range F {
+		vals[i] = make(map[int]int, len(I))
+		for k, v := range I {
+			vals[i][k] = int(v)
+		}
+	}
+	return
+}
+
+// ParseArffF is ParseArff for real-valued data. Numeric attributes are kept as floating point
+// numbers, and their variables are continuous, with zero categories. Class and string attributes
+// are handled just like in ParseArff.
+func ParseArffF(filename string) (name string, sc map[int]*learn.Variable, vals spn.DatasetF,
+	labels map[int]map[string]int) {
+	name, sc, F, labels := parseArff(filename, true)
+	return name, sc, spn.DatasetF(F), labels
+}
+
+// parseArff parses an ARFF file. If float is set, numeric attributes are parsed as floating point
+// numbers and do not count categories. Otherwise, they are parsed as integers.
+func parseArff(filename string, float bool) (name string, sc map[int]*learn.Variable,
+	vals []map[int]float64, labels map[int]map[string]int) {
 	in, err := os.Open(filename)
 
 	if err != nil {
@@ -232,9 +258,13 @@ func ParseArff(filename string) (name string, sc map[int]*learn.Variable, vals [
 
 				_t := strings.ToLower(typ)
 				var cat int
+				vt := learn.Categorical
 				if _t == "numeric" {
 					// Special treatment for numerics.
 					typs = append(typs, _t)
+					if vt = learn.Ordinal; float {
+						vt = learn.Continuous
+					}
 				} else if _t == "string" {
 					// Special treatment for strings.
 					labels[i] = make(map[string]int)
@@ -251,7 +281,7 @@ func ParseArff(filename string) (name string, sc map[int]*learn.Variable, vals [
 					cat = len(l)
 					typs = append(typs, "class")
 				}
-				sc[i] = &learn.Variable{Varid: i, Categories: cat, Name: n}
+				sc[i] = &learn.Variable{Varid: i, Categories: cat, Name: n, Type: vt}
 				i++
 			} else if strings.HasPrefix(_l, "@data") {
 				data = true
@@ -263,15 +293,22 @@ func ParseArff(filename string) (name string, sc map[int]*learn.Variable, vals [
 				return c == ' ' || c == ','
 			})
 
-			vals = append(vals, make(map[int]int))
+			vals = append(vals, make(map[int]float64))
 			for j := range v {
-				if typs[j] == "numeric" {
-					_v, err := strconv.Atoi(v[j])
+				if typs[j] == "numeric" && float {
+					_v, err := strconv.ParseFloat(v[j], 64)
 					if err != nil {
 						fmt.Printf("Error parsing line %d of file [%s].\n", lc, filename)
 						panic(err)
 					}
 					vals[i][j] = _v
+				} else if typs[j] == "numeric" {
+					_v, err := strconv.Atoi(v[j])
+					if err != nil {
+						fmt.Printf("Error parsing line %d of file [%s].\n", lc, filename)
+						panic(err)
+					}
+					vals[i][j] = float64(_v)
 					_tv := sc[j]
 					if _v+1 > _tv.Categories {
 						_tv.Categories = _v + 1
@@ -286,14 +323,14 @@ func ParseArff(filename string) (name string, sc map[int]*learn.Variable, vals [
 						labels[j][tk] = counts[j]
 						counts[j]++
 					}
-					vals[i][j] = labels[j][tk]
+					vals[i][j] = float64(labels[j][tk])
 				} else /* class */ {
 					tk := v[j]
 					if _, e := labels[j][tk]; !e {
 						labels[j][tk] = counts[j]
 						counts[j]++
 					}
-					vals[i][j] = labels[j][tk]
+					vals[i][j] = float64(labels[j][tk])
 				}
 			}
 			i++
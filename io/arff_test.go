@@ -2,7 +2,12 @@ package io
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/RenatoGeh/gospn/learn"
 )
 
 func TestParseArff(t *testing.T) {
@@ -28,3 +33,26 @@ func TestParseArff(t *testing.T) {
 		fmt.Println(" }")
 	}
 }
+
+func TestParseArffF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "sensors.arff")
+	data := "@RELATION sensors\n@ATTRIBUTE temp NUMERIC\n@ATTRIBUTE on {yes,no}\n@DATA\n" +
+		"21.5,yes\n-3.25,no\n"
+	if err := ioutil.WriteFile(f, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	name, sc, vals, _ := ParseArffF(f)
+	if name != "sensors" || sc[0].Categories != 0 || sc[1].Categories != 2 ||
+		sc[0].Type != learn.Continuous || sc[1].Type != learn.Categorical {
+		t.Errorf("Unexpected header %s %v.", name, sc)
+	}
+	if len(vals) != 2 || vals[0][0] != 21.5 || vals[1][0] != -3.25 || vals[0][1] != 0 ||
+		vals[1][1] != 1 {
+		t.Errorf("Unexpected data %v.", vals)
+	}
+}
@@ -0,0 +1,370 @@
+package io
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+// CircuitOp is the operation computed by a circuit node.
+type CircuitOp string
+
+// Circuit operations. All values are computed in logspace.
+const (
+	// OpSum is the weighted sum of the node's inputs.
+	OpSum CircuitOp = "sum"
+	// OpProduct is the product of the node's inputs.
+	OpProduct CircuitOp = "product"
+	// OpIndicator is 1 if variable Var is Value or is not set, and 0 otherwise.
+	OpIndicator CircuitOp = "indicator"
+	// OpCategorical is Params[x] if variable Var is set to x, and 1 otherwise.
+	OpCategorical CircuitOp = "categorical"
+	// OpGaussian is the gaussian density with mean Params[0] and standard deviation Params[1] if
+	// variable Var is set, and 1 otherwise. If the standard deviation is zero, it is a point mass at
+	// the mean: 1 if Var is set to the mean or is not set, and 0 otherwise.
+	OpGaussian CircuitOp = "gaussian"
+)
+
+// CircuitNode is a node of an arithmetic circuit.
+type CircuitNode struct {
+	// Op is the operation computed by this node.
+	Op CircuitOp `json:"op"`
+	// Inputs are the indices of the input nodes of sums and products.
+	Inputs []int `json:"inputs,omitempty"`
+	// Weights of sum nodes, aligned with Inputs.
+	Weights []float64 `json:"weights,omitempty"`
+	// Var is the variable of leaf operations, and is meaningless otherwise.
+	Var int `json:"var"`
+	// Value is the value of indicators.
+	Value int `json:"value,omitempty"`
+	// Params are the parameters of categorical and gaussian nodes.
+	Params []float64 `json:"params,omitempty"`
+}
+
+// Circuit is an arithmetic circuit: a computation graph of sums, products and leaf operations
+// that does not depend on gospn. Nodes are in dependency order (inputs before the nodes that use
+// them), and the last node is the output.
+type Circuit struct {
+	Nodes []CircuitNode `json:"nodes"`
+}
+
+// ToCircuit converts SPN S into an arithmetic circuit. Weights and leaf parameters are copied, so
+// that further changes to S do not affect the circuit. Only the builtin leaf types (indicators,
+// multinomials and gaussians) are supported.
+func ToCircuit(S spn.SPN) (*Circuit, error) {
+	C := &Circuit{}
+	M := make(map[spn.SPN]int)
+	var err error
+	spn.TopSortTarjanFunc(S, nil, func(Z spn.SPN) bool {
+		var n CircuitNode
+		if n, err = toCircuitNode(Z, M); err != nil {
+			return false
+		}
+		M[Z] = len(C.Nodes)
+		C.Nodes = append(C.Nodes, n)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return C, nil
+}
+
+func toCircuitNode(Z spn.SPN, M map[spn.SPN]int) (CircuitNode, error) {
+	var n CircuitNode
+	switch Z.Type() {
+	case "sum":
+		n.Op = OpSum
+		n.Weights = append([]float64{}, Z.(*spn.Sum).Weights()...)
+	case "product":
+		n.Op = OpProduct
+	}
+	if n.Op != "" {
+		n.Inputs = []int{}
+		for _, c := range Z.Ch() {
+			n.Inputs = append(n.Inputs, M[c])
+		}
+		return n, nil
+	}
+	Z, err := spn.Unlazy(Z)
+	if err != nil {
+		return n, err
+	}
+	switch L := Z.(type) {
+	case *spn.Indicator:
+		n.Op = OpIndicator
+		n.Var, n.Value = L.Params()
+	case *spn.Multinomial:
+		n.Op, n.Var = OpCategorical, L.Sc()[0]
+		n.Params = append([]float64{}, L.Pr()...)
+	case *spn.Gaussian:
+		mu, sigma := L.Params()
+		n.Op, n.Var, n.Params = OpGaussian, L.Sc()[0], []float64{mu, sigma}
+	default:
+		return n, fmt.Errorf("io: leaf subtype %s not supported by circuits", Z.SubType())
+	}
+	return n, nil
+}
+
+// Eval returns the logarithm of the circuit's output given the valuation x. Unset variables are
+// marginalized. For circuits produced by ToCircuit, this is the same as spn.Inference.
+func (C *Circuit) Eval(x map[int]int) float64 {
+	V := make([]float64, len(C.Nodes))
+	for i, n := range C.Nodes {
+		switch n.Op {
+		case OpSum:
+			L := make([]float64, len(n.Inputs))
+			for j, c := range n.Inputs {
+				L[j] = V[c] + math.Log(n.Weights[j])
+			}
+			V[i] = logSumExp(L)
+		case OpProduct:
+			for _, c := range n.Inputs {
+				V[i] += V[c]
+			}
+		case OpIndicator:
+			if u, e := x[n.Var]; e && u != n.Value {
+				V[i] = math.Inf(-1)
+			}
+		case OpCategorical:
+			if u, e := x[n.Var]; e {
+				if u < 0 || u >= len(n.Params) {
+					V[i] = math.Inf(-1)
+				} else {
+					V[i] = math.Log(n.Params[u])
+				}
+			}
+		case OpGaussian:
+			u, e := x[n.Var]
+			V[i] = logGaussian(float64(u), e, n.Params[0], n.Params[1])
+		}
+	}
+	return V[len(V)-1]
+}
+
+// logSumExp is the same as utils.LogSumExp. It is duplicated here (and in generated code) so that
+// circuits do not depend on the rest of gospn.
+func logSumExp(L []float64) float64 {
+	if len(L) == 0 {
+		return math.Inf(-1)
+	}
+	max := L[0]
+	for _, l := range L {
+		if l > max {
+			max = l
+		}
+	}
+	if math.IsInf(max, 0) {
+		return max
+	}
+	var s float64
+	for _, l := range L {
+		s += math.Exp(l - max)
+	}
+	return math.Log(s) + max
+}
+
+// logGaussian follows spn.Gaussian.Value.
+func logGaussian(x float64, set bool, mu, sigma float64) float64 {
+	if !set {
+		return 0
+	}
+	if sigma == 0 {
+		if x == mu {
+			return 0
+		}
+		return math.Inf(-1)
+	}
+	z := (x - mu) / sigma
+	return -z*z/2 - math.Log(sigma) - math.Log(2*math.Pi)/2
+}
+
+// WriteCircuit writes circuit C to w as a JSON object with a list of nodes, where each node has an
+// operation ("sum", "product", "indicator", "categorical" or "gaussian"), its inputs, its weights
+// (for sums), its variable, value (for indicators) and parameters (for categoricals and gaussians).
+func WriteCircuit(w io.Writer, C *Circuit) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(C)
+}
+
+// ReadCircuit reads a circuit written by WriteCircuit from r, checking that inputs come before the
+// nodes that use them.
+func ReadCircuit(r io.Reader) (*Circuit, error) {
+	C := &Circuit{}
+	if err := json.NewDecoder(r).Decode(C); err != nil {
+		return nil, err
+	}
+	if len(C.Nodes) == 0 {
+		return nil, fmt.Errorf("io: circuit has no nodes")
+	}
+	for i, n := range C.Nodes {
+		for _, c := range n.Inputs {
+			if c < 0 || c >= i {
+				return nil, fmt.Errorf("io: circuit node %d has invalid input %d", i, c)
+			}
+		}
+		switch n.Op {
+		case OpSum:
+			if len(n.Weights) != len(n.Inputs) {
+				return nil, fmt.Errorf("io: circuit node %d has %d weights for %d inputs", i,
+					len(n.Weights), len(n.Inputs))
+			}
+		case OpGaussian:
+			if len(n.Params) != 2 {
+				return nil, fmt.Errorf("io: circuit node %d expects mean and standard deviation", i)
+			}
+		case OpProduct, OpIndicator, OpCategorical:
+		default:
+			return nil, fmt.Errorf("io: circuit node %d has unknown operation %q", i, n.Op)
+		}
+	}
+	return C, nil
+}
+
+// goFloat returns a Go expression for f.
+func goFloat(f float64) string {
+	switch {
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsNaN(f):
+		return "math.NaN()"
+	}
+	return fmt.Sprintf("%v", f)
+}
+
+// WriteGo writes to w the source code of a standalone Go function named fn, in package pkg, that
+// evaluates circuit C. The generated function has signature
+//
+//	func fn(x map[int]int) float64
+//
+// and returns the same value as C.Eval(x), that is ln S(x) for circuits produced by ToCircuit.
+// The generated code only depends on the standard library (package math), and all its top-level
+// identifiers are prefixed with fn, so that many functions can be generated into the same package.
+// Leaf parameters and weights are embedded as constants with full precision.
+func WriteGo(w io.Writer, C *Circuit, pkg, fn string) error {
+	var b bytes.Buffer
+	n := len(C.Nodes)
+	fmt.Fprintf(&b, "// Code generated by gospn. DO NOT EDIT.\n\npackage %s\n\nimport \"math\"\n\n", pkg)
+	fmt.Fprintf(&b, "// %s returns the log-value of an SPN exported by gospn given the valuation x. "+
+		"Variables\n// absent from x are marginalized.\n", fn)
+	fmt.Fprintf(&b, "func %s(x map[int]int) float64 {\n\tvar v [%d]float64\n", fn, n)
+	var tables []string
+	for i, c := range C.Nodes {
+		fmt.Fprintf(&b, "\tv[%d] = ", i)
+		switch c.Op {
+		case OpSum:
+			var T []string
+			for j, k := range c.Inputs {
+				if lw := math.Log(c.Weights[j]); lw < 0 && !math.IsInf(lw, -1) {
+					T = append(T, fmt.Sprintf("v[%d]-%s", k, goFloat(-lw)))
+				} else {
+					T = append(T, fmt.Sprintf("v[%d]+%s", k, goFloat(lw)))
+				}
+			}
+			fmt.Fprintf(&b, "%sLogSumExp(%s)", fn, strings.Join(T, ", "))
+		case OpProduct:
+			if len(c.Inputs) == 0 {
+				b.WriteString("0")
+			}
+			for j, k := range c.Inputs {
+				if j > 0 {
+					b.WriteString(" + ")
+				}
+				fmt.Fprintf(&b, "v[%d]", k)
+			}
+		case OpIndicator:
+			fmt.Fprintf(&b, "%sIndicator(x, %d, %d)", fn, c.Var, c.Value)
+		case OpCategorical:
+			var T []string
+			for _, p := range c.Params {
+				T = append(T, goFloat(math.Log(p)))
+			}
+			fmt.Fprintf(&b, "%sCategorical(x, %d, %sTables[%d])", fn, c.Var, fn, len(tables))
+			tables = append(tables, "{"+strings.Join(T, ", ")+"}")
+		case OpGaussian:
+			fmt.Fprintf(&b, "%sGaussian(x, %d, %s, %s)", fn, c.Var, goFloat(c.Params[0]),
+				goFloat(c.Params[1]))
+		default:
+			return fmt.Errorf("io: unknown circuit operation %q", c.Op)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\treturn v[%d]\n}\n\n", n-1)
+	fmt.Fprintf(&b, "// %sTables holds the log-probabilities of categorical leaves.\n", fn)
+	fmt.Fprintf(&b, "var %sTables = [][]float64{\n", fn)
+	for _, t := range tables {
+		fmt.Fprintf(&b, "\t%s,\n", t)
+	}
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, goHelpers, fn)
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+const goHelpers = `
+func %[1]sLogSumExp(L ...float64) float64 {
+	if len(L) == 0 {
+		return math.Inf(-1)
+	}
+	max := L[0]
+	for _, l := range L {
+		if l > max {
+			max = l
+		}
+	}
+	if math.IsInf(max, 0) {
+		return max
+	}
+	var s float64
+	for _, l := range L {
+		s += math.Exp(l - max)
+	}
+	return math.Log(s) + max
+}
+
+func %[1]sIndicator(x map[int]int, k, u int) float64 {
+	if v, e := x[k]; e && v != u {
+		return math.Inf(-1)
+	}
+	return 0
+}
+
+func %[1]sCategorical(x map[int]int, k int, lp []float64) float64 {
+	v, e := x[k]
+	if !e {
+		return 0
+	}
+	if v < 0 || v >= len(lp) {
+		return math.Inf(-1)
+	}
+	return lp[v]
+}
+
+func %[1]sGaussian(x map[int]int, k int, mu, sigma float64) float64 {
+	v, e := x[k]
+	if !e {
+		return 0
+	}
+	if sigma == 0 {
+		if float64(v) == mu {
+			return 0
+		}
+		return math.Inf(-1)
+	}
+	z := (float64(v) - mu) / sigma
+	return -z*z/2 - math.Log(sigma) - math.Log(2*math.Pi)/2
+}
+`
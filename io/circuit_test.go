@@ -0,0 +1,134 @@
+package io
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+func circuitInstances() []spn.VarSet {
+	return []spn.VarSet{{0: 0, 1: 2}, {0: 1, 1: 0}, {0: 1}, {1: 2}, {}}
+}
+
+// pointMassSPN returns an SPN with zero variance gaussians, one of them centered at a non-integer
+// mean.
+func pointMassSPN() spn.SPN {
+	R := spn.NewSum()
+	P1, P2 := spn.NewProduct(), spn.NewProduct()
+	X := spn.NewMultinomial(0, []float64{0.3, 0.7})
+	R.AddChildW(P1, 0.4)
+	R.AddChildW(P2, 0.6)
+	P1.AddChild(X)
+	P1.AddChild(spn.NewGaussianParams(1, 2.5, 0))
+	P2.AddChild(X)
+	P2.AddChild(spn.NewGaussianParams(1, 2, 0))
+	return R
+}
+
+func TestCircuit(t *testing.T) {
+	S := textSPN()
+	C, err := ToCircuit(S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := WriteCircuit(&b, C); err != nil {
+		t.Fatal(err)
+	}
+	D, err := ReadCircuit(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, I := range circuitInstances() {
+		v := spn.Inference(S, I)
+		if u, w := C.Eval(I), D.Eval(I); math.Abs(v-u) > 1e-12 || u != w {
+			t.Errorf("Expected %v, got %v and %v for %v.", v, u, w, I)
+		}
+	}
+	if _, err := ReadCircuit(strings.NewReader(`{"nodes": [{"op": "product", "inputs": [0]}]}`)); err == nil {
+		t.Errorf("Expected an error on invalid inputs.")
+	}
+}
+
+func TestCircuitPointMass(t *testing.T) {
+	S := pointMassSPN()
+	C, err := ToCircuit(S)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, I := range circuitInstances() {
+		v, u := spn.Inference(S, I), C.Eval(I)
+		if math.IsInf(v, -1) != math.IsInf(u, -1) || (!math.IsInf(v, -1) && math.Abs(v-u) > 1e-12) {
+			t.Errorf("Expected %v, got %v for %v.", v, u, I)
+		}
+	}
+}
+
+func TestWriteGo(t *testing.T) {
+	models := map[string]spn.SPN{"model": textSPN(), "pointMass": pointMassSPN()}
+	names := []string{"model", "pointMass"}
+	files := map[string][]byte{"go.mod": []byte("module model\n")}
+	C := make(map[string]*Circuit)
+	for _, name := range names {
+		C[name], _ = ToCircuit(models[name])
+		var b bytes.Buffer
+		if err := WriteGo(&b, C[name], "main", name); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), name+".go", b.Bytes(), 0); err != nil {
+			t.Fatalf("Expected valid Go code, got %v:\n%s", err, b.String())
+		}
+		files[name+".go"] = b.Bytes()
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil || testing.Short() {
+		t.Skip("skipping compilation of generated code")
+	}
+	dir, err := ioutil.TempDir("", "gospn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var m bytes.Buffer
+	m.WriteString("package main\n\nimport \"fmt\"\n\nfunc main() {\n")
+	for _, name := range names {
+		for _, I := range circuitInstances() {
+			m.WriteString("\tfmt.Println(" + name + "(map[int]int{")
+			for k, v := range I {
+				m.WriteString(strconv.Itoa(k) + ": " + strconv.Itoa(v) + ", ")
+			}
+			m.WriteString("}))\n")
+		}
+	}
+	m.WriteString("}\n")
+	files["main.go"] = m.Bytes()
+	for f, d := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), d, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(gobin, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Expected generated code to run, got %v:\n%s", err, out)
+	}
+	n := len(circuitInstances())
+	for i, l := range strings.Fields(string(out)) {
+		u, _ := strconv.ParseFloat(l, 64)
+		v := C[names[i/n]].Eval(circuitInstances()[i%n])
+		if u != v && math.Abs(u-v) > 1e-12 {
+			t.Errorf("Expected %v, got %v.", v, u)
+		}
+	}
+}
@@ -20,8 +20,15 @@
 	doing so, a new image of the SPN will be generated. Note that this requires the graph-tool
 	library (https://graph-tool.skewed.de/). DrawGraph uses Graphviz to draw the graph. You can then
 	run the resulting dot script with sfdp, neato or any other layout program. This requires the
-	graphviz library (http://www.graphviz.org/).
+	graphviz library (http://www.graphviz.org/). WriteDOT and SaveDOT also produce Graphviz graphs, but
+	can optionally show sum weights, scopes and leaf parameters, colour nodes by stored values, and
+	collapse nodes beyond a certain depth (see DOTOptions).
 
-	WriteToFile writes the SPN to a file. TODO: ReadFromFile should read the SPN from a .mdl file.
+	SaveSPN and LoadSPN write and read SPNs in a Go-only binary format. SaveText and LoadText (and
+	their io.Writer/io.Reader variants WriteText and ReadText) use a human-readable text format
+	with one node per line, which is easy to keep under version control. ReadFromFile reads the text
+	format as well. WriteSPFlow and ReadSPFlow (and SaveSPFlow and LoadSPFlow) use the textual format
+	of the Python library SPFlow, so that models can be exchanged with it. WriteJSON and ReadJSON
+	(and SaveJSON and LoadJSON) encode SPNs and their variables in a versioned JSON schema.
 */
 package io
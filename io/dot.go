@@ -0,0 +1,194 @@
+package io
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RenatoGeh/gospn/common"
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+// DOTOptions configures how WriteDOT draws an SPN. The zero value draws the bare structure.
+type DOTOptions struct {
+	// Weights annotates the edges of sum nodes with their weights.
+	Weights bool
+	// Scopes labels every node with its scope.
+	Scopes bool
+	// Params labels leaves with their parameters.
+	Params bool
+	// Values, if not nil, colours each node by its stored value in the table (e.g. an inference
+	// value or derivative). Values are taken to be in logspace, and are linearly scaled between the
+	// minimum and maximum finite values found in the table for the drawn nodes. Nodes with no
+	// stored value are left uncoloured.
+	Values spn.StorerTable
+	// Entry is the index of the entry in Values to be used for each node.
+	Entry int
+	// Scale maps a point in [0,1] to a colour. Defaults to common.HeatColor.
+	Scale func(p float64) *common.Color
+	// MaxDepth, if positive, collapses every node at depth MaxDepth into a single node. The depth
+	// of a node is its shortest distance from the root.
+	MaxDepth int
+	// Precision is the number of decimal places for weights, parameters and values. Defaults to 3.
+	Precision int
+}
+
+// WriteDOT writes the SPN S to w as a directed graph in Graphviz's DOT language. Shared nodes are
+// drawn once, and each node is named after its ID.
+func WriteDOT(w io.Writer, S spn.SPN, opts DOTOptions) error {
+	if opts.Scale == nil {
+		opts.Scale = common.HeatColor
+	}
+	if opts.Precision <= 0 {
+		opts.Precision = 3
+	}
+	G := spn.Index(S)
+	N := G.Nodes()
+	drawn := func(Z spn.SPN) bool { return opts.MaxDepth <= 0 || G.Depth(Z) <= opts.MaxDepth }
+	lo, hi := math.Inf(1), math.Inf(-1)
+	if opts.Values != nil {
+		for _, Z := range N {
+			if v, e := opts.Values.Entry(Z, opts.Entry); e && drawn(Z) && !math.IsInf(v, 0) &&
+				!math.IsNaN(v) {
+				lo, hi = math.Min(lo, v), math.Max(hi, v)
+			}
+		}
+	}
+	var sc map[spn.SPN][]int
+	if opts.Scopes {
+		sc = dotScopes(S)
+	}
+	ftoa := func(f float64) string { return strconv.FormatFloat(f, 'f', opts.Precision, 64) }
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "digraph {")
+	fmt.Fprintln(out, "  node [style=filled, fillcolor=white];")
+	for _, Z := range N {
+		if !drawn(Z) {
+			continue
+		}
+		var label, shape string
+		collapsed := opts.MaxDepth > 0 && G.Depth(Z) == opts.MaxDepth && len(Z.Ch()) > 0
+		switch Z.Type() {
+		case "sum":
+			label, shape = "+", "circle"
+		case "product":
+			label, shape = "×", "circle"
+		default:
+			label, shape = dotLeafLabel(Z, opts.Params, ftoa), "box"
+		}
+		if collapsed {
+			label = fmt.Sprintf("%s\n[%d nodes]", label, len(spn.Index(Z).Nodes()))
+			shape = "box3d"
+		}
+		if opts.Scopes {
+			label += "\n" + fmt.Sprint(sc[Z])
+		}
+		attrs := fmt.Sprintf("label=%s, shape=%s", strconv.Quote(label), shape)
+		if opts.Values != nil {
+			if v, e := opts.Values.Entry(Z, opts.Entry); e {
+				p := 0.0
+				if math.IsInf(v, -1) {
+					p = 0
+				} else if math.IsInf(v, 1) || hi == lo {
+					p = 1
+				} else {
+					p = (v - lo) / (hi - lo)
+				}
+				attrs += fmt.Sprintf(", fillcolor=%q, tooltip=%q", opts.Scale(p).Hex(), ftoa(v))
+			}
+		}
+		fmt.Fprintf(out, "  n%d [%s];\n", Z.ID(), attrs)
+	}
+	for _, Z := range N {
+		if !drawn(Z) || (opts.MaxDepth > 0 && G.Depth(Z) >= opts.MaxDepth) {
+			continue
+		}
+		var W []float64
+		if s, ok := Z.(*spn.Sum); ok && opts.Weights {
+			W = s.Weights()
+		}
+		for i, c := range Z.Ch() {
+			if i < len(W) {
+				fmt.Fprintf(out, "  n%d -> n%d [label=%q];\n", Z.ID(), c.ID(), ftoa(W[i]))
+			} else {
+				fmt.Fprintf(out, "  n%d -> n%d;\n", Z.ID(), c.ID())
+			}
+		}
+	}
+	fmt.Fprintln(out, "}")
+	return out.Flush()
+}
+
+// SaveDOT creates a file filename and writes S to it as a DOT graph (see WriteDOT).
+func SaveDOT(filename string, S spn.SPN, opts DOTOptions) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = WriteDOT(f, S, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dotScopes returns the sorted scope of every node in S. Scopes are computed from the leaves, as
+// inner node scopes may not have been computed yet, and calling Sc on them may cache them.
+func dotScopes(S spn.SPN) map[spn.SPN][]int {
+	sc := make(map[spn.SPN][]int)
+	spn.TopSortTarjanFunc(S, nil, func(Z spn.SPN) bool {
+		ch := Z.Ch()
+		if len(ch) == 0 {
+			sc[Z] = append([]int(nil), Z.Sc()...)
+			sort.Ints(sc[Z])
+			return true
+		}
+		M := make(map[int]bool)
+		var U []int
+		for _, c := range ch {
+			for _, v := range sc[c] {
+				if !M[v] {
+					M[v] = true
+					U = append(U, v)
+				}
+			}
+		}
+		sort.Ints(U)
+		sc[Z] = U
+		return true
+	})
+	return sc
+}
+
+// dotLeafLabel returns the label of leaf L, including its parameters if params is set.
+func dotLeafLabel(L spn.SPN, params bool, ftoa func(float64) string) string {
+	var v int
+	if sc := L.Sc(); len(sc) > 0 {
+		v = sc[0]
+	}
+	name := fmt.Sprintf("X%d", v)
+	if !params {
+		return name
+	}
+	L, _ = spn.Unlazy(L)
+	switch T := L.(type) {
+	case *spn.Indicator:
+		x, u := T.Params()
+		return fmt.Sprintf("X%d=%d", x, u)
+	case *spn.Multinomial:
+		P := make([]string, len(T.Pr()))
+		for i, p := range T.Pr() {
+			P[i] = ftoa(p)
+		}
+		return fmt.Sprintf("%s\n[%s]", name, strings.Join(P, " "))
+	case *spn.Gaussian:
+		mu, sigma := T.Params()
+		return fmt.Sprintf("%s\nμ=%s σ=%s", name, ftoa(mu), ftoa(sigma))
+	}
+	return fmt.Sprintf("%s\n%s", name, L.SubType())
+}
@@ -0,0 +1,56 @@
+package io
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/common"
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+func TestWriteDOT(t *testing.T) {
+	S := textSPN()
+	st := spn.NewStorer()
+	tk := st.NewTicket()
+	spn.StoreInference(S, spn.VarSet{0: 1}, tk, st)
+	T, _ := st.Table(tk)
+	var b bytes.Buffer
+	err := WriteDOT(&b, S, DOTOptions{Weights: true, Scopes: true, Params: true, Values: T,
+		Scale: common.GrayColor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	P := S.Ch()
+	X := P[0].Ch()[0]
+	for _, s := range []string{
+		fmt.Sprintf("n%d -> n%d [label=\"0.300\"]", S.ID(), P[0].ID()),
+		fmt.Sprintf("n%d -> n%d;", P[0].ID(), X.ID()),
+		fmt.Sprintf("n%d -> n%d;", P[1].ID(), X.ID()),
+		"X1=2", "μ=0.500 σ=1.250", "[0.123 0.877]",
+		fmt.Sprintf("n%d [label=%q", S.ID(), "+\n[0 1]"),
+		fmt.Sprintf("n%d [label=%q", P[1].ID(), "×\n[0 1]"),
+		common.GrayColor(1).Hex(),
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected output to contain %q:\n%s", s, out)
+		}
+	}
+	if n := strings.Count(out, fmt.Sprintf("  n%d [", X.ID())); n != 1 {
+		t.Errorf("Expected shared node to be drawn once, got %d.", n)
+	}
+
+	b.Reset()
+	if err := WriteDOT(&b, S, DOTOptions{MaxDepth: 1}); err != nil {
+		t.Fatal(err)
+	}
+	out = b.String()
+	if strings.Contains(out, fmt.Sprintf("n%d", X.ID())) {
+		t.Errorf("Expected nodes beyond depth 1 to be collapsed:\n%s", out)
+	}
+	if !strings.Contains(out, "[3 nodes]") {
+		t.Errorf("Expected collapsed nodes to show subgraph sizes:\n%s", out)
+	}
+}
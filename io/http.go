@@ -37,18 +37,18 @@ func DownloadFromURL(u, p string, override bool) error {
 		return e
 	}
 	out, e := os.Create(f)
-	defer out.Close()
 	if e != nil {
-		fmt.Println("Error when trying to create file [%s].\n", f)
+		fmt.Printf("Error when trying to create file [%s].\n", f)
 		return e
 	}
+	defer out.Close()
 	sys.Printf("Downloading from [%s] to {./%s}.\n", u, f)
 	d, e := http.Get(u)
-	defer d.Body.Close()
 	if e != nil {
-		fmt.Println("Error while downloading [%s].\nStopping download.", u)
+		fmt.Printf("Error while downloading [%s].\nStopping download.\n", u)
 		return e
 	}
+	defer d.Body.Close()
 	_, e = io.Copy(out, d.Body)
 	if e != nil {
 		fmt.Println("Error while copying download to local directory.")
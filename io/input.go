@@ -32,6 +32,27 @@ func GetPath(relpath string) string {
 	return rp
 }
 
+// parseVariable parses a variable definition line of a data file, of the form
+//
+//	var <varid> <categories> [<type>]
+//
+// where type is the name of a learn.VarType (e.g. binary or continuous). Variables with no
+// declared type are categorical.
+func parseVariable(line string) (*learn.Variable, error) {
+	v := &learn.Variable{}
+	if _, err := fmt.Sscanf(line, "var %d %d", &v.Varid, &v.Categories); err != nil {
+		return v, err
+	}
+	if f := strings.Fields(line); len(f) > 3 {
+		t, err := learn.ParseVarType(f[3])
+		if err != nil {
+			return v, err
+		}
+		v.Type = t
+	}
+	return v, nil
+}
+
 // ParseData reads from a file named filename and returns the scope and data map of the parsed data
 // file.
 func ParseData(filename string) (map[int]*learn.Variable, []map[int]int) {
@@ -57,9 +78,12 @@ func ParseData(filename string) (map[int]*learn.Variable, []map[int]int) {
 		if line[0] != 'v' {
 			break
 		}
-		var varid, cats int
-		fmt.Sscanf(line, "var %d %d", &varid, &cats)
-		sc[varid] = &learn.Variable{Varid: varid, Categories: cats}
+		v, err := parseVariable(line)
+		if err != nil {
+			fmt.Printf("Invalid variable definition \"%s\" found in data file [%s].\n", line, filename)
+			panic(err)
+		}
+		sc[v.Varid] = v
 	}
 
 	n := len(sc)
@@ -104,6 +128,55 @@ func ParseData(filename string) (map[int]*learn.Variable, []map[int]int) {
 	return sc, cvntmap
 }
 
+// ParseDataF is ParseData for real-valued data. Values are kept as floating point numbers instead
+// of being parsed as integers. Variables with zero categories and no declared type are taken to be
+// continuous. Unlike ParseData, ParseDataF returns an error instead of panicking.
+func ParseDataF(filename string) (map[int]*learn.Variable, spn.DatasetF, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	sc := make(map[int]*learn.Variable)
+	var data spn.DatasetF
+	regex := regexp.MustCompile("[\\,\\s]+")
+	scanner := bufio.NewScanner(file)
+	for l := 1; scanner.Scan(); l++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "var") {
+			v, err := parseVariable(line)
+			if err != nil {
+				return nil, nil, fmt.Errorf("io: %s:%d: %v", filename, l, err)
+			}
+			if v.Categories == 0 && len(strings.Fields(line)) < 4 {
+				v.Type = learn.Continuous
+			}
+			sc[v.Varid] = v
+			continue
+		}
+		s := regex.Split(line, -1)
+		if len(s) < len(sc) {
+			return nil, nil, fmt.Errorf("io: %s:%d: expected %d values, got %d", filename, l, len(sc),
+				len(s))
+		}
+		I := make(map[int]float64, len(sc))
+		for j := 0; j < len(sc); j++ {
+			if I[j], err = strconv.ParseFloat(s[j], 64); err != nil {
+				return nil, nil, fmt.Errorf("io: %s:%d: %v", filename, l, err)
+			}
+		}
+		data = append(data, I)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return sc, data, nil
+}
+
 // ParseDataNL reads from a file named filename and returns the scope and data map of the parsed
 // data file. This version doesn't add labels as variables, but return them separately as a slice.
 func ParseDataNL(filename string) (map[int]*learn.Variable, []map[int]int, []int) {
@@ -129,9 +202,12 @@ func ParseDataNL(filename string) (map[int]*learn.Variable, []map[int]int, []int
 		if line[0] != 'v' {
 			break
 		}
-		var varid, cats int
-		fmt.Sscanf(line, "var %d %d", &varid, &cats)
-		sc[varid] = &learn.Variable{Varid: varid, Categories: cats}
+		v, err := parseVariable(line)
+		if err != nil {
+			fmt.Printf("Invalid variable definition \"%s\" found in data file [%s].\n", line, filename)
+			panic(err)
+		}
+		sc[v.Varid] = v
 	}
 
 	n := len(sc) - 1
@@ -226,9 +302,12 @@ func ParseEvidence(filename string) (map[int]*learn.Variable, []map[int]int, []i
 		if line[0] != 'v' {
 			break
 		}
-		var varid, cats int
-		fmt.Sscanf(line, "var %d %d", &varid, &cats)
-		sc[varid] = &learn.Variable{Varid: varid, Categories: cats}
+		v, err := parseVariable(line)
+		if err != nil {
+			fmt.Printf("Invalid variable definition \"%s\" found in data file [%s].\n", line, filename)
+			panic(err)
+		}
+		sc[v.Varid] = v
 	}
 
 	n := len(sc)
@@ -334,14 +413,12 @@ func ParsePartitionedData(filename string, p float64, rseed int64) (map[int]*lea
 	return vartable, fdata, test, lbls
 }
 
-// ReadFromFile reads an SPN from an spn mdl file.
+// ReadFromFile reads an SPN from a text model file (see WriteText and LoadText).
 func ReadFromFile(filename string) spn.SPN {
-	file, err := os.Create(filename)
+	S, err := LoadText(filename)
 	if err != nil {
-		fmt.Printf("Error. Could not create file [%s].\n", filename)
+		fmt.Printf("Error. Could not read file [%s].\n", filename)
 		panic(err)
 	}
-	defer file.Close()
-
-	return nil
+	return S
 }
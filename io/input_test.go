@@ -0,0 +1,72 @@
+package io
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/learn"
+)
+
+func TestParseDataF(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "sensors.data")
+	data := "var 0 0\nvar 1 2 binary\nvar 2 0 count\n0.25 1 3\n-1.5e-3,0,0\n"
+	if err := ioutil.WriteFile(f, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sc, D, err := ParseDataF(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc) != 3 || sc[1].Categories != 2 || len(D) != 2 {
+		t.Fatalf("Expected 3 variables and 2 instances, got %v and %v.", sc, D)
+	}
+	if sc[0].Type != learn.Continuous || sc[1].Type != learn.Binary || sc[2].Type != learn.Count {
+		t.Errorf("Expected types continuous, binary and count, got %v, %v and %v.", sc[0].Type,
+			sc[1].Type, sc[2].Type)
+	}
+	if D[0][0] != 0.25 || D[0][1] != 1 || D[1][0] != -1.5e-3 || D[1][1] != 0 {
+		t.Errorf("Unexpected data %v.", D)
+	}
+	for _, d := range []string{"var 0 0\n0.5x\n", "var 0 2 nominal\n1\n"} {
+		if err := ioutil.WriteFile(f, []byte(d), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := ParseDataF(f); err == nil {
+			t.Errorf("Expected an error on %q.", d)
+		}
+	}
+}
+
+func TestParseDataInvalidVariable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "typo.data")
+	if err := ioutil.WriteFile(f, []byte("var 0 2\nvar 1 0 contnuous\n0,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	parsers := map[string]func(){
+		"ParseData":     func() { ParseData(f) },
+		"ParseDataNL":   func() { ParseDataNL(f) },
+		"ParseEvidence": func() { ParseEvidence(f) },
+	}
+	for name, parse := range parsers {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %s to panic on an invalid variable type.", name)
+				}
+			}()
+			parse()
+		}()
+	}
+}
@@ -0,0 +1,345 @@
+package io
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/RenatoGeh/gospn/learn"
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+// JSONVersion is the version of the JSON schema written by WriteJSON. ReadJSON accepts any version
+// up to JSONVersion.
+const JSONVersion = 1
+
+// jsonModel is the top level object of the JSON schema.
+type jsonModel struct {
+	// Version of the schema.
+	Version int `json:"version"`
+	// Root node ID.
+	Root int `json:"root"`
+	// Variables metadata.
+	Variables []jsonVariable `json:"variables,omitempty"`
+	// Nodes in dependency order (children first).
+	Nodes []jsonNode `json:"nodes"`
+}
+
+type jsonVariable struct {
+	ID         int    `json:"id"`
+	Categories int    `json:"categories"`
+	Name       string `json:"name,omitempty"`
+	// Type is the name of the variable's learn.VarType. Defaults to categorical.
+	Type string `json:"type,omitempty"`
+}
+
+type jsonNode struct {
+	// Node ID (see spn.SPN.ID).
+	ID int `json:"id"`
+	// Type is either sum, product or leaf.
+	Type string `json:"type"`
+	// SubType is the leaf subtype.
+	SubType string `json:"subtype,omitempty"`
+	// Scope of the node, sorted.
+	Scope []int `json:"scope"`
+	// Children IDs.
+	Children []int `json:"children,omitempty"`
+	// Weights of sum nodes, aligned with Children.
+	Weights []float64 `json:"weights,omitempty"`
+	// Params of builtin leaves: probabilities of a multinomial, mean and standard deviation of a
+	// gaussian and value of an indicator.
+	Params []float64 `json:"params,omitempty"`
+	// Encoded holds the GobEncode output of leaves of any other subtype.
+	Encoded string `json:"encoded,omitempty"`
+}
+
+// WriteJSON writes SPN S and the metadata of its variables sc (which may be nil) to w as a JSON
+// object of the form
+//
+//	{
+//	  "version": 1,
+//	  "root": <root ID>,
+//	  "variables": [{"id": 0, "categories": 2, "name": "X", "type": "binary"}, ...],
+//	  "nodes": [
+//	    {"id": 1, "type": "leaf", "subtype": "multinomial", "scope": [0], "params": [0.2, 0.8]},
+//	    {"id": 2, "type": "leaf", "subtype": "gaussian", "scope": [1], "params": [0.5, 1]},
+//	    {"id": 3, "type": "leaf", "subtype": "indicator", "scope": [1], "params": [0]},
+//	    {"id": 4, "type": "product", "scope": [0, 1], "children": [1, 2]},
+//	    {"id": 5, "type": "sum", "scope": [0, 1], "children": [4, ...], "weights": [0.3, ...]},
+//	    ...
+//	  ]
+//	}
+//
+// Nodes are listed in dependency order, with each node's stable ID (see spn.SPN.ID). Leaves of
+// other subtypes store their GobEncode output in "encoded" instead of "params", and must be
+// registered with spn.RegisterGobType to be read back.
+func WriteJSON(w io.Writer, S spn.SPN, sc map[int]*learn.Variable) error {
+	M := jsonModel{Version: JSONVersion, Root: S.ID()}
+	for _, v := range sc {
+		M.Variables = append(M.Variables, jsonVariable{v.Varid, v.Categories, v.Name,
+			v.Type.String()})
+	}
+	sort.Slice(M.Variables, func(i, j int) bool { return M.Variables[i].ID < M.Variables[j].ID })
+	Sc := make(map[spn.SPN][]int)
+	var err error
+	spn.TopSortTarjanFunc(S, nil, func(Z spn.SPN) bool {
+		var n jsonNode
+		n, err = toJSONNode(Z, Sc)
+		M.Nodes = append(M.Nodes, n)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&M)
+}
+
+func toJSONNode(Z spn.SPN, Sc map[spn.SPN][]int) (jsonNode, error) {
+	n := jsonNode{ID: Z.ID(), Type: Z.Type()}
+	ch := Z.Ch()
+	if len(ch) == 0 {
+		n.Scope = uniqueInts(Z.Sc())
+	} else {
+		var u []int
+		for _, c := range ch {
+			u = append(u, Sc[c]...)
+			n.Children = append(n.Children, c.ID())
+		}
+		n.Scope = uniqueInts(u)
+	}
+	Sc[Z] = n.Scope
+	switch Z.Type() {
+	case "sum":
+		n.Weights = append([]float64{}, Z.(*spn.Sum).Weights()...)
+		return n, nil
+	case "product":
+		return n, nil
+	}
+	n.SubType = Z.SubType()
+	Z, err := spn.Unlazy(Z)
+	if err != nil {
+		return n, err
+	}
+	switch L := Z.(type) {
+	case *spn.Multinomial:
+		n.Params = L.Pr()
+	case *spn.Gaussian:
+		mu, sigma := L.Params()
+		n.Params = []float64{mu, sigma}
+	case *spn.Indicator:
+		_, v := L.Params()
+		n.Params = []float64{float64(v)}
+	case gob.GobEncoder:
+		p, err := L.GobEncode()
+		if err != nil {
+			return n, err
+		}
+		n.Encoded = string(p)
+	default:
+		return n, fmt.Errorf("io: cannot write leaf of subtype %s: GobEncoder not implemented",
+			n.SubType)
+	}
+	return n, nil
+}
+
+// ReadJSON reads an SPN and the metadata of its variables from a JSON object written by WriteJSON.
+// The model is validated: the schema version must be supported, node IDs must be unique, children
+// must reference existing nodes without forming cycles, every node must be reachable from the
+// root, sum nodes must have one weight per child, leaves must have valid parameters and every
+// node's declared scope must match the scope computed from its leaves. Node IDs are preserved.
+func ReadJSON(r io.Reader) (spn.SPN, map[int]*learn.Variable, error) {
+	var M jsonModel
+	if err := json.NewDecoder(r).Decode(&M); err != nil {
+		return nil, nil, err
+	}
+	if M.Version < 1 || M.Version > JSONVersion {
+		return nil, nil, fmt.Errorf("io: unsupported JSON schema version %d", M.Version)
+	}
+	sc := make(map[int]*learn.Variable)
+	for _, v := range M.Variables {
+		if _, e := sc[v.ID]; e {
+			return nil, nil, fmt.Errorf("io: duplicate variable %d", v.ID)
+		}
+		t := learn.Categorical
+		if v.Type != "" {
+			var err error
+			if t, err = learn.ParseVarType(v.Type); err != nil {
+				return nil, nil, fmt.Errorf("io: variable %d: %v", v.ID, err)
+			}
+		}
+		sc[v.ID] = &learn.Variable{Varid: v.ID, Categories: v.Categories, Name: v.Name, Type: t}
+	}
+	N := make(map[int]spn.SPN)
+	D := make(map[int][]int)
+	for i := range M.Nodes {
+		n := &M.Nodes[i]
+		if _, e := N[n.ID]; e {
+			return nil, nil, fmt.Errorf("io: duplicate node ID %d", n.ID)
+		}
+		Z, err := fromJSONNode(n)
+		if err != nil {
+			return nil, nil, fmt.Errorf("io: node %d: %v", n.ID, err)
+		}
+		spn.SetID(Z, n.ID)
+		N[n.ID], D[n.ID] = Z, uniqueInts(n.Scope)
+	}
+	for i := range M.Nodes {
+		n := &M.Nodes[i]
+		Z := N[n.ID]
+		for j, c := range n.Children {
+			C, e := N[c]
+			if !e {
+				return nil, nil, fmt.Errorf("io: node %d: child %d does not exist", n.ID, c)
+			}
+			if s, ok := Z.(*spn.Sum); ok {
+				s.AddChildW(C, n.Weights[j])
+			} else {
+				Z.AddChild(C)
+			}
+		}
+	}
+	S, e := N[M.Root]
+	if !e {
+		return nil, nil, fmt.Errorf("io: root %d does not exist", M.Root)
+	}
+	if R := spn.Validate(S); R.Has(spn.Cycle) {
+		return nil, nil, fmt.Errorf("io: graph has cycles")
+	} else if R.Nodes != len(N) {
+		return nil, nil, fmt.Errorf("io: %d nodes unreachable from the root", len(N)-R.Nodes)
+	}
+	Sc := make(map[spn.SPN][]int)
+	var err error
+	spn.TopSortTarjanFunc(S, nil, func(Z spn.SPN) bool {
+		var u []int
+		if ch := Z.Ch(); len(ch) == 0 {
+			u = Z.Sc()
+		} else {
+			for _, c := range ch {
+				u = append(u, Sc[c]...)
+			}
+		}
+		Sc[Z] = uniqueInts(u)
+		if d := D[Z.ID()]; !equalInts(d, Sc[Z]) {
+			err = fmt.Errorf("io: node %d: declared scope %v, expected %v", Z.ID(), d, Sc[Z])
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return S, sc, nil
+}
+
+func fromJSONNode(n *jsonNode) (spn.SPN, error) {
+	switch n.Type {
+	case "sum":
+		if len(n.Weights) != len(n.Children) {
+			return nil, fmt.Errorf("%d weights for %d children", len(n.Weights), len(n.Children))
+		}
+		if len(n.Children) == 0 {
+			return nil, fmt.Errorf("sum node has no children")
+		}
+		return spn.NewSum(), nil
+	case "product":
+		if len(n.Children) == 0 {
+			return nil, fmt.Errorf("product node has no children")
+		}
+		return spn.NewProduct(), nil
+	case "leaf":
+	default:
+		return nil, fmt.Errorf("unknown node type %q", n.Type)
+	}
+	if len(n.Children) > 0 {
+		return nil, fmt.Errorf("leaf has children")
+	}
+	if n.SubType != "multinomial" && n.SubType != "gaussian" && n.SubType != "indicator" {
+		L, e := spn.NewOfSubType(n.SubType)
+		if !e {
+			return nil, fmt.Errorf("unregistered leaf subtype %q", n.SubType)
+		}
+		d, ok := L.(gob.GobDecoder)
+		if !ok {
+			return nil, fmt.Errorf("leaf subtype %q does not implement GobDecoder", n.SubType)
+		}
+		if err := d.GobDecode([]byte(n.Encoded)); err != nil {
+			return nil, err
+		}
+		return L, nil
+	}
+	if len(n.Scope) != 1 {
+		return nil, fmt.Errorf("%s leaf must have exactly one variable in scope", n.SubType)
+	}
+	v, P := n.Scope[0], n.Params
+	switch n.SubType {
+	case "multinomial":
+		if len(P) == 0 {
+			return nil, fmt.Errorf("multinomial has no probabilities")
+		}
+		for _, p := range P {
+			if p < 0 || p > 1 {
+				return nil, fmt.Errorf("invalid probability %v", p)
+			}
+		}
+		return spn.NewMultinomial(v, P), nil
+	case "gaussian":
+		if len(P) != 2 || !(P[1] >= 0) {
+			return nil, fmt.Errorf("gaussian expects mean and non-negative standard deviation")
+		}
+		return spn.NewGaussianParams(v, P[0], P[1]), nil
+	}
+	if len(P) != 1 || P[0] != float64(int(P[0])) {
+		return nil, fmt.Errorf("indicator expects one integer value")
+	}
+	return spn.NewIndicator(v, int(P[0])), nil
+}
+
+func uniqueInts(a []int) []int {
+	M := make(map[int]bool)
+	u := []int{}
+	for _, v := range a {
+		if !M[v] {
+			M[v] = true
+			u = append(u, v)
+		}
+	}
+	sort.Ints(u)
+	return u
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveJSON writes SPN S and its variables sc to file filename as in WriteJSON. Suggested extension:
+// ".json".
+func SaveJSON(filename string, S spn.SPN, sc map[int]*learn.Variable) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteJSON(f, S, sc)
+}
+
+// LoadJSON reads an SPN and its variables from a file written by SaveJSON.
+func LoadJSON(filename string) (spn.SPN, map[int]*learn.Variable, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return ReadJSON(f)
+}
@@ -0,0 +1,97 @@
+package io
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/learn"
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	S := textSPN()
+	sc := map[int]*learn.Variable{
+		0: {Varid: 0, Categories: 2, Name: "X"},
+		1: {Varid: 1, Categories: 0, Name: "Y", Type: learn.Continuous},
+	}
+	var b bytes.Buffer
+	if err := WriteJSON(&b, S, sc); err != nil {
+		t.Fatal(err)
+	}
+	T, tc, err := ReadJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spn.Equal(S, T, 0) {
+		t.Errorf("Expected SPNs to be equal after JSON round trip.")
+	}
+	if !spn.Equal(spn.Unmarshal(spn.Marshal(S)), T, 1e-6) {
+		t.Errorf("Expected JSON and Marshal round trips to agree.")
+	}
+	G, H := spn.Index(S), spn.Index(T)
+	for _, Z := range G.Nodes() {
+		if _, e := H.Node(Z.ID()); !e {
+			t.Errorf("Expected node ID %d to be preserved.", Z.ID())
+		}
+	}
+	for k, v := range sc {
+		if u := tc[k]; u == nil || *u != *v {
+			t.Errorf("Expected variable %v, got %v.", v, u)
+		}
+	}
+	// Lazily decoded leaves are written as their underlying leaves.
+	L, err := spn.DecodeWith(bytes.NewReader(spn.Marshal(S)), spn.DecodeOptions{Lazy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	if err := WriteJSON(&b, L, sc); err != nil {
+		t.Fatal(err)
+	}
+	if T, _, err = ReadJSON(&b); err != nil {
+		t.Fatal(err)
+	} else if !spn.Equal(S, T, 0) {
+		t.Errorf("Expected SPNs to be equal after JSON round trip of a lazily decoded SPN.")
+	}
+}
+
+func TestJSONPointMass(t *testing.T) {
+	S := pointMassSPN()
+	var b bytes.Buffer
+	if err := WriteJSON(&b, S, nil); err != nil {
+		t.Fatal(err)
+	}
+	T, _, err := ReadJSON(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spn.Equal(S, T, 0) {
+		t.Errorf("Expected zero variance gaussians to survive a JSON round trip.")
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	leaf := `{"id": 1, "type": "leaf", "subtype": "indicator", "scope": [0], "params": [1]}`
+	bad := []string{
+		`{"version": 2, "root": 1, "nodes": [` + leaf + `]}`,
+		`{"version": 1, "root": 2, "nodes": [` + leaf + `]}`,
+		`{"version": 1, "root": 1, "nodes": [` + leaf + `, ` + leaf + `]}`,
+		`{"version": 1, "root": 2, "nodes": [` + leaf +
+			`, {"id": 2, "type": "sum", "scope": [0], "children": [1]}]}`,
+		`{"version": 1, "root": 2, "nodes": [` + leaf +
+			`, {"id": 2, "type": "sum", "scope": [0], "children": [3], "weights": [1]}]}`,
+		`{"version": 1, "root": 2, "nodes": [` + leaf +
+			`, {"id": 2, "type": "sum", "scope": [0, 1], "children": [1], "weights": [1]}]}`,
+		`{"version": 1, "root": 2, "nodes": [` + leaf +
+			`, {"id": 2, "type": "product", "scope": [0], "children": [1, 3]}` +
+			`, {"id": 3, "type": "product", "scope": [0], "children": [2]}]}`,
+		`{"version": 1, "root": 1, "nodes": [{"id": 1, "type": "leaf", "subtype": "gaussian", ` +
+			`"scope": [0], "params": [0, -1]}]}`,
+	}
+	for _, s := range bad {
+		if _, _, err := ReadJSON(strings.NewReader(s)); err == nil {
+			t.Errorf("Expected an error reading %s.", s)
+		}
+	}
+}
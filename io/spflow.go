@@ -0,0 +1,376 @@
+package io
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+// WriteSPFlow writes SPN S to w in the textual format of SPFlow, as written by SPFlow's
+// spn_to_str_equation and read by its str_to_spn, so that models can be exchanged with SPFlow. A
+// sum is written as (w_1*c_1 + ... + w_n*c_n), a product as (c_1 * ... * c_n) and a leaf over
+// variable i as Type(Vi|name=value;...), with the following types and parameters:
+//
+//	Categorical(V0|p=[0.2, 0.8])        spn.Multinomial, and spn.Indicator as a one-hot vector
+//	Gaussian(V1|mean=0.5;stdev=1.25)    spn.Gaussian
+//	Bernoulli(V2|p=0.3)                 spn.Bernoulli
+//	Poisson(V3|mean=2)                  spn.Poisson
+//	Exponential(V4|l=1.5)               spn.Exponential, where l is the rate
+//	Gamma(V5|alpha=2;beta=0.5)          spn.Gamma, where alpha is the shape and beta the rate
+//	LogNormal(V6|mean=0;stdev=1)        spn.LogNormal
+//
+// Other leaf types cannot be written. The format describes a tree, and so nodes with more than one
+// parent are written once per parent, and node IDs are not kept. Floating point numbers are
+// written with full precision.
+func WriteSPFlow(w io.Writer, S spn.SPN) error {
+	M := make(map[spn.SPN]string)
+	var err error
+	spn.TopSortTarjanFunc(S, nil, func(Z spn.SPN) bool {
+		M[Z], err = spflowNode(Z, M)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, M[S])
+	return err
+}
+
+// pyFloat formats f with full precision and without exponent, which every SPFlow version parses.
+func pyFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// spflowNode returns the SPFlow representation of Z, where M holds the representation of Z's
+// children.
+func spflowNode(Z spn.SPN, M map[spn.SPN]string) (string, error) {
+	var b bytes.Buffer
+	switch Z.Type() {
+	case "sum":
+		W := Z.(*spn.Sum).Weights()
+		b.WriteByte('(')
+		for i, c := range Z.Ch() {
+			if i > 0 {
+				b.WriteString(" + ")
+			}
+			fmt.Fprintf(&b, "%s*%s", pyFloat(W[i]), M[c])
+		}
+		b.WriteByte(')')
+		return b.String(), nil
+	case "product":
+		b.WriteByte('(')
+		for i, c := range Z.Ch() {
+			if i > 0 {
+				b.WriteString(" * ")
+			}
+			b.WriteString(M[c])
+		}
+		b.WriteByte(')')
+		return b.String(), nil
+	}
+	Z, err := spn.Unlazy(Z)
+	if err != nil {
+		return "", err
+	}
+	leaf := func(t string, params ...interface{}) string {
+		fmt.Fprintf(&b, "%s(V%d|", t, Z.Sc()[0])
+		for i := 0; i < len(params); i += 2 {
+			if i > 0 {
+				b.WriteByte(';')
+			}
+			fmt.Fprintf(&b, "%s=%s", params[i], params[i+1])
+		}
+		b.WriteByte(')')
+		return b.String()
+	}
+	list := func(P []float64) string {
+		T := make([]string, len(P))
+		for i, p := range P {
+			T[i] = pyFloat(p)
+		}
+		return "[" + strings.Join(T, ", ") + "]"
+	}
+	switch L := Z.(type) {
+	case *spn.Multinomial:
+		return leaf("Categorical", "p", list(L.Pr())), nil
+	case *spn.Indicator:
+		_, v := L.Params()
+		P := make([]float64, v+1)
+		P[v] = 1
+		return leaf("Categorical", "p", list(P)), nil
+	case *spn.Gaussian:
+		mu, sigma := L.Params()
+		return leaf("Gaussian", "mean", pyFloat(mu), "stdev", pyFloat(sigma)), nil
+	case *spn.Bernoulli:
+		return leaf("Bernoulli", "p", pyFloat(L.Params())), nil
+	case *spn.Poisson:
+		return leaf("Poisson", "mean", pyFloat(L.Params())), nil
+	case *spn.Exponential:
+		return leaf("Exponential", "l", pyFloat(L.Params())), nil
+	case *spn.Gamma:
+		k, theta := L.Params()
+		return leaf("Gamma", "alpha", pyFloat(k), "beta", pyFloat(1/theta)), nil
+	case *spn.LogNormal:
+		mu, sigma := L.Params()
+		return leaf("LogNormal", "mean", pyFloat(mu), "stdev", pyFloat(sigma)), nil
+	}
+	return "", fmt.Errorf("io: cannot write leaf of subtype %s in SPFlow format", Z.SubType())
+}
+
+// ReadSPFlow reads an SPN in the textual format of SPFlow (see WriteSPFlow) from r. Parentheses
+// around single nodes are dropped, a product may be written without parentheses, and spaces are
+// optional. Variables must be named Vi, where i is the variable ID.
+func ReadSPFlow(r io.Reader) (spn.SPN, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &spflowParser{s: string(data)}
+	S, err := p.expr()
+	if err == nil {
+		if p.skip(); p.i < len(p.s) {
+			err = fmt.Errorf("unexpected %q", p.s[p.i])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("io: SPFlow model at offset %d: %v", p.i, err)
+	}
+	return S, nil
+}
+
+// spflowParser is a recursive descent parser for SPFlow models, with grammar
+//
+//	expr   = term {"+" term}
+//	term   = [number "*"] factor {"*" factor}
+//	factor = "(" expr ")" | leaf
+//
+// where an expr of weighted terms is a sum and a term of many factors is a product.
+type spflowParser struct {
+	s string
+	i int
+}
+
+// skip skips whitespace.
+func (p *spflowParser) skip() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// accept consumes c if it is the next non-whitespace character.
+func (p *spflowParser) accept(c byte) bool {
+	if p.skip(); p.i < len(p.s) && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// until consumes and returns everything up to the next c, which is consumed but not returned.
+func (p *spflowParser) until(c byte) (string, error) {
+	j := strings.IndexByte(p.s[p.i:], c)
+	if j < 0 {
+		return "", fmt.Errorf("expected %q", c)
+	}
+	t := p.s[p.i : p.i+j]
+	p.i += j + 1
+	return t, nil
+}
+
+func (p *spflowParser) expr() (spn.SPN, error) {
+	var T []spn.SPN
+	var W []float64
+	weighted := true
+	for {
+		w, ok, Z, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		T, W, weighted = append(T, Z), append(W, w), weighted && ok
+		if !p.accept('+') {
+			break
+		}
+	}
+	if len(T) == 1 && !weighted {
+		return T[0], nil
+	}
+	if !weighted {
+		return nil, fmt.Errorf("expected a weight for every child of a sum")
+	}
+	S := spn.NewSum()
+	for i, Z := range T {
+		S.AddChildW(Z, W[i])
+	}
+	return S, nil
+}
+
+// term returns the weight of a term (1 if it has none), whether it has one and its node.
+func (p *spflowParser) term() (float64, bool, spn.SPN, error) {
+	w, ok := 1.0, false
+	p.skip()
+	if p.i < len(p.s) && strings.IndexByte("0123456789.-", p.s[p.i]) >= 0 {
+		j := p.i
+		for p.i < len(p.s) && strings.IndexByte("0123456789.-+eE", p.s[p.i]) >= 0 {
+			p.i++
+		}
+		var err error
+		if w, err = strconv.ParseFloat(p.s[j:p.i], 64); err != nil {
+			return 0, false, nil, err
+		}
+		if !p.accept('*') {
+			return 0, false, nil, fmt.Errorf("expected '*' after weight")
+		}
+		ok = true
+	}
+	var F []spn.SPN
+	for {
+		Z, err := p.factor()
+		if err != nil {
+			return 0, false, nil, err
+		}
+		F = append(F, Z)
+		if !p.accept('*') {
+			break
+		}
+	}
+	if len(F) == 1 {
+		return w, ok, F[0], nil
+	}
+	P := spn.NewProduct()
+	for _, Z := range F {
+		P.AddChild(Z)
+	}
+	return w, ok, P, nil
+}
+
+func (p *spflowParser) factor() (spn.SPN, error) {
+	if p.accept('(') {
+		Z, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, fmt.Errorf("expected ')'")
+		}
+		return Z, nil
+	}
+	t, err := p.until('(')
+	if err != nil {
+		return nil, err
+	}
+	t = strings.TrimSpace(t)
+	name, err := p.until('|')
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	v, err := strconv.Atoi(strings.TrimPrefix(name, "V"))
+	if err != nil || !strings.HasPrefix(name, "V") || v < 0 {
+		return nil, fmt.Errorf("expected variable name Vi, got %q", name)
+	}
+	args, err := p.until(')')
+	if err != nil {
+		return nil, err
+	}
+	P := make(map[string][]float64)
+	for _, a := range strings.Split(args, ";") {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("expected name=value, got %q", a)
+		}
+		var F []float64
+		for _, f := range strings.Split(strings.Trim(strings.TrimSpace(kv[1]), "[]"), ",") {
+			x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+			if err != nil {
+				return nil, err
+			}
+			F = append(F, x)
+		}
+		P[strings.TrimSpace(kv[0])] = F
+	}
+	get := func(k string) (float64, error) {
+		if F := P[k]; len(F) == 1 {
+			return F[0], nil
+		}
+		return 0, fmt.Errorf("%s leaf expects a single value for %s", t, k)
+	}
+	pair := func(a, b string) (float64, float64, error) {
+		x, err := get(a)
+		if err != nil {
+			return 0, 0, err
+		}
+		y, err := get(b)
+		return x, y, err
+	}
+	switch t {
+	case "Categorical":
+		if len(P["p"]) == 0 {
+			return nil, fmt.Errorf("Categorical leaf expects probabilities p")
+		}
+		return spn.NewMultinomial(v, P["p"]), nil
+	case "Gaussian":
+		mu, sigma, err := pair("mean", "stdev")
+		if err != nil {
+			return nil, err
+		}
+		return spn.NewGaussianParams(v, mu, sigma), nil
+	case "Bernoulli":
+		x, err := get("p")
+		if err != nil {
+			return nil, err
+		}
+		return spn.NewBernoulli(v, x), nil
+	case "Poisson":
+		x, err := get("mean")
+		if err != nil {
+			return nil, err
+		}
+		return spn.NewPoisson(v, x), nil
+	case "Exponential":
+		x, err := get("l")
+		if err != nil {
+			return nil, err
+		}
+		return spn.NewExponential(v, x), nil
+	case "Gamma":
+		alpha, beta, err := pair("alpha", "beta")
+		if err != nil {
+			return nil, err
+		}
+		return spn.NewGamma(v, alpha, 1/beta), nil
+	case "LogNormal":
+		mu, sigma, err := pair("mean", "stdev")
+		if err != nil {
+			return nil, err
+		}
+		return spn.NewLogNormal(v, mu, sigma), nil
+	}
+	return nil, fmt.Errorf("unsupported leaf type %q", t)
+}
+
+// SaveSPFlow writes SPN S to file filename in the SPFlow format of WriteSPFlow. Suggested
+// extension: ".spflow".
+func SaveSPFlow(filename string, S spn.SPN) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteSPFlow(f, S)
+}
+
+// LoadSPFlow reads an SPN from a file in the SPFlow format of WriteSPFlow.
+func LoadSPFlow(filename string) (spn.SPN, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSPFlow(f)
+}
@@ -0,0 +1,99 @@
+package io
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+func TestReadSPFlow(t *testing.T) {
+	// The example model of SPFlow's README, as printed by spn_to_str_equation.
+	S, err := LoadSPFlow("testdata/readme.spflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(S.Ch()); S.Type() != "sum" || n != 2 {
+		t.Fatalf("Expected a sum with 2 children, got a %s with %d.", S.Type(), n)
+	}
+	// Log-likelihood of instance (1, 0, 1) as given by SPFlow's README.
+	if v := spn.Inference(S, spn.VarSet{0: 1, 1: 0, 2: 1}); math.Abs(v+1.90730501) > 1e-8 {
+		t.Errorf("Expected log-likelihood -1.90730501, got %v.", v)
+	}
+	var b bytes.Buffer
+	if err := WriteSPFlow(&b, S); err != nil {
+		t.Fatal(err)
+	}
+	T, err := ReadSPFlow(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spn.Equal(S, T, 0) {
+		t.Errorf("Expected SPNs to be equal after round trip. Text:\n%s", b.String())
+	}
+}
+
+func TestSPFlowRoundTrip(t *testing.T) {
+	R := spn.NewSum()
+	P1, P2 := spn.NewProduct(), spn.NewProduct()
+	P1.AddChild(spn.NewGaussianParams(0, -0.5, 1.25))
+	P1.AddChild(spn.NewBernoulli(1, 0.3))
+	P1.AddChild(spn.NewPoisson(2, 2.5))
+	P1.AddChild(spn.NewIndicator(3, 2))
+	P2.AddChild(spn.NewExponential(0, 1.5))
+	P2.AddChild(spn.NewGamma(1, 2, 4))
+	P2.AddChild(spn.NewLogNormal(2, 0.1, 1e-7))
+	P2.AddChild(spn.NewMultinomial(3, []float64{0.1234567891, 0.2, 0.6765432109}))
+	R.AddChildW(P1, 0.25)
+	R.AddChildW(P2, 0.75)
+	var b bytes.Buffer
+	if err := WriteSPFlow(&b, R); err != nil {
+		t.Fatal(err)
+	}
+	text := b.String()
+	for _, s := range []string{"Gaussian(V0|mean=-0.5;stdev=1.25)", "Gamma(V1|alpha=2;beta=0.25)",
+		"Categorical(V3|p=[0, 0, 1])", "LogNormal(V2|mean=0.1;stdev=0.0000001)", "0.25*("} {
+		if !strings.Contains(text, s) {
+			t.Errorf("Expected %q in:\n%s", s, text)
+		}
+	}
+	S, err := ReadSPFlow(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, I := range []spn.VarSet{{0: 1, 1: 0, 2: 3, 3: 2}, {0: 0, 1: 1, 2: 1, 3: 0}, {3: 2}, {}} {
+		if u, v := spn.Inference(R, I), spn.Inference(S, I); math.Abs(u-v) > 1e-12 {
+			t.Errorf("Expected %v, got %v for %v.", u, v, I)
+		}
+	}
+	if err := WriteSPFlow(&b, spn.NewGeometric(0, 0.5)); err == nil {
+		t.Errorf("Expected an error writing a leaf unknown to SPFlow.")
+	}
+}
+
+func TestReadSPFlowErrors(t *testing.T) {
+	bad := []string{
+		"",
+		"Gaussian(V0|mean=0)",
+		"Gaussian(X|mean=0;stdev=1)",
+		"Geometric(V0|p=0.5)",
+		"(0.5*Bernoulli(V0|p=0.5) + Bernoulli(V0|p=0.5))",
+		"(0.5*Bernoulli(V0|p=0.5)",
+		"Bernoulli(V0|p=0.5) Bernoulli(V1|p=0.5)",
+	}
+	for _, s := range bad {
+		if _, err := ReadSPFlow(strings.NewReader(s)); err == nil {
+			t.Errorf("Expected an error reading %q.", s)
+		}
+	}
+	// Products without parentheses and a sum with a single child.
+	S, err := ReadSPFlow(strings.NewReader("Bernoulli(V0|p=0.5)*(1.0*Bernoulli(V1|p=0.25))"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := spn.Inference(S, spn.VarSet{0: 1, 1: 1}); math.Abs(v-math.Log(0.125)) > 1e-12 {
+		t.Errorf("Expected ln 0.125, got %v.", v)
+	}
+}
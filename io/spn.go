@@ -1,29 +1,41 @@
 package io
 
 import (
-	"github.com/RenatoGeh/gospn/spn"
-	"io/ioutil"
+	"bufio"
 	"os"
+
+	"github.com/RenatoGeh/gospn/spn"
 )
 
-// SaveSPN serializes an SPN and writes it to a file. Suggested extension: ".spn".
+// SaveSPN serializes an SPN with spn.Encode and writes it to a file. Suggested extension: ".spn".
 func SaveSPN(filename string, S spn.SPN) error {
 	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	bytes := spn.Marshal(S)
-	_, err = f.Write(bytes)
+	w := bufio.NewWriter(f)
+	if err = spn.Encode(w, S); err == nil {
+		err = w.Flush()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
 	return err
 }
 
-// LoadSPN reads a binary file that contains a serialized SPN.
+// LoadSPN reads a binary file that contains an SPN serialized by SaveSPN (or by older versions of
+// it). The file is decoded as it is read, without loading it into memory first.
 func LoadSPN(filename string) (spn.SPN, error) {
-	data, err := ioutil.ReadFile(filename)
+	return LoadSPNWith(filename, spn.DecodeOptions{})
+}
+
+// LoadSPNWith is LoadSPN with decoding options, which allow reporting progress and deferring the
+// decoding of leaves (see spn.DecodeWith).
+func LoadSPNWith(filename string, opts spn.DecodeOptions) (spn.SPN, error) {
+	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
-	S := spn.Unmarshal(data)
-	return S, nil
+	defer f.Close()
+	return spn.DecodeWith(f, opts)
 }
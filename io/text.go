@@ -0,0 +1,278 @@
+package io
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+// TextHeader is the first line of every file written by WriteText.
+const TextHeader = "# gospn text model v1"
+
+// WriteText writes SPN S to w in a human-readable text format. The format has one node per line,
+// in dependency order (children come before their parents, the root being the last node). Each
+// line starts with the node's type and ID (see spn.SPN.ID), followed by:
+//
+//	sum <id> <child>:<weight> ...
+//	product <id> <child> ...
+//	indicator <id> <var> <value>
+//	multinomial <id> <var> <p_0> <p_1> ... <p_k>
+//	gaussian <id> <var> <mean> <stddev>
+//	leaf <id> <subtype> <quoted GobEncode output>
+//
+// where children are referenced by ID. The last form is used for leaves of any other type, which
+// must implement gob.GobEncoder with a textual encoding and be registered with
+// spn.RegisterGobType. Lines starting with # are comments. Floating point numbers are written
+// with full precision.
+//
+// Unlike the SPFlow format (see WriteSPFlow), which describes trees, this format keeps shared
+// nodes and node IDs.
+func WriteText(w io.Writer, S spn.SPN) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, TextHeader)
+	var err error
+	spn.TopSortTarjanFunc(S, nil, func(Z spn.SPN) bool {
+		err = writeTextNode(b, Z)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return b.Flush()
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func writeTextNode(w *bufio.Writer, Z spn.SPN) error {
+	id := Z.ID()
+	switch Z.Type() {
+	case "sum":
+		fmt.Fprintf(w, "sum %d", id)
+		W := Z.(*spn.Sum).Weights()
+		for i, c := range Z.Ch() {
+			fmt.Fprintf(w, " %d:%s", c.ID(), ftoa(W[i]))
+		}
+	case "product":
+		fmt.Fprintf(w, "product %d", id)
+		for _, c := range Z.Ch() {
+			fmt.Fprintf(w, " %d", c.ID())
+		}
+	default:
+		Z, err := spn.Unlazy(Z)
+		if err != nil {
+			return err
+		}
+		switch L := Z.(type) {
+		case *spn.Indicator:
+			v, x := L.Params()
+			fmt.Fprintf(w, "indicator %d %d %d", id, v, x)
+		case *spn.Multinomial:
+			fmt.Fprintf(w, "multinomial %d %d", id, L.Sc()[0])
+			for _, p := range L.Pr() {
+				fmt.Fprintf(w, " %s", ftoa(p))
+			}
+		case *spn.Gaussian:
+			mu, sigma := L.Params()
+			fmt.Fprintf(w, "gaussian %d %d %s %s", id, L.Sc()[0], ftoa(mu), ftoa(sigma))
+		case gob.GobEncoder:
+			p, err := L.GobEncode()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "leaf %d %s %s", id, Z.SubType(), strconv.Quote(string(p)))
+		default:
+			return fmt.Errorf("io: cannot write leaf of subtype %s: GobEncoder not implemented",
+				Z.SubType())
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// ReadText reads an SPN written by WriteText from r. Node IDs are preserved.
+func ReadText(r io.Reader) (spn.SPN, error) {
+	M := make(map[int]spn.SPN)
+	var S spn.SPN
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1<<30)
+	for l := 1; s.Scan(); l++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		Z, id, err := readTextNode(line, M)
+		if err != nil {
+			return nil, fmt.Errorf("io: line %d: %v", l, err)
+		}
+		if _, e := M[id]; e {
+			return nil, fmt.Errorf("io: line %d: duplicate node ID %d", l, id)
+		}
+		spn.SetID(Z, id)
+		M[id], S = Z, Z
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if S == nil {
+		return nil, fmt.Errorf("io: no nodes found")
+	}
+	return S, nil
+}
+
+func readTextNode(line string, M map[int]spn.SPN) (spn.SPN, int, error) {
+	f := strings.Fields(line)
+	if len(f) < 2 {
+		return nil, 0, fmt.Errorf("expected node type and ID")
+	}
+	id, err := strconv.Atoi(f[1])
+	if err != nil {
+		return nil, 0, err
+	}
+	child := func(t string) (spn.SPN, error) {
+		c, err := strconv.Atoi(t)
+		if err != nil {
+			return nil, err
+		}
+		Z, e := M[c]
+		if !e {
+			return nil, fmt.Errorf("child %d not defined before its parent", c)
+		}
+		return Z, nil
+	}
+	floats := func(T []string) ([]float64, error) {
+		P := make([]float64, len(T))
+		for i, t := range T {
+			if P[i], err = strconv.ParseFloat(t, 64); err != nil {
+				return nil, err
+			}
+		}
+		return P, nil
+	}
+	args := f[2:]
+	switch f[0] {
+	case "sum":
+		S := spn.NewSum()
+		for _, a := range args {
+			i := strings.IndexByte(a, ':')
+			if i < 0 {
+				return nil, 0, fmt.Errorf("expected child:weight, got %q", a)
+			}
+			c, err := child(a[:i])
+			if err != nil {
+				return nil, 0, err
+			}
+			w, err := strconv.ParseFloat(a[i+1:], 64)
+			if err != nil {
+				return nil, 0, err
+			}
+			S.AddChildW(c, w)
+		}
+		return S, id, nil
+	case "product":
+		P := spn.NewProduct()
+		for _, a := range args {
+			c, err := child(a)
+			if err != nil {
+				return nil, 0, err
+			}
+			P.AddChild(c)
+		}
+		return P, id, nil
+	case "indicator":
+		if len(args) != 2 {
+			return nil, 0, fmt.Errorf("expected variable and value")
+		}
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		x, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		return spn.NewIndicator(v, x), id, nil
+	case "multinomial", "gaussian":
+		if len(args) < 2 {
+			return nil, 0, fmt.Errorf("expected variable and parameters")
+		}
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		P, err := floats(args[1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		if f[0] == "multinomial" {
+			return spn.NewMultinomial(v, P), id, nil
+		}
+		if len(P) != 2 {
+			return nil, 0, fmt.Errorf("expected mean and standard deviation")
+		}
+		return spn.NewGaussianParams(v, P[0], P[1]), id, nil
+	case "leaf":
+		if len(args) < 2 {
+			return nil, 0, fmt.Errorf("expected subtype and encoded parameters")
+		}
+		L, e := spn.NewOfSubType(args[0])
+		if !e {
+			return nil, 0, fmt.Errorf("unregistered leaf subtype %s", args[0])
+		}
+		d, ok := L.(gob.GobDecoder)
+		if !ok {
+			return nil, 0, fmt.Errorf("leaf subtype %s does not implement GobDecoder", args[0])
+		}
+		// The quoted parameters may contain spaces, so take everything after the subtype.
+		p, err := strconv.Unquote(skipFields(line, 3))
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := d.GobDecode([]byte(p)); err != nil {
+			return nil, 0, err
+		}
+		return L, id, nil
+	}
+	return nil, 0, fmt.Errorf("unknown node type %s", f[0])
+}
+
+// skipFields returns s without its first n whitespace separated fields, trimmed.
+func skipFields(s string, n int) string {
+	for ; n > 0; n-- {
+		s = strings.TrimLeft(s, " \t")
+		if i := strings.IndexAny(s, " \t"); i >= 0 {
+			s = s[i:]
+		} else {
+			s = ""
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// SaveText writes SPN S to file filename in the text format of WriteText. Suggested extension:
+// ".txt".
+func SaveText(filename string, S spn.SPN) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return WriteText(f, S)
+}
+
+// LoadText reads an SPN from a file written by SaveText.
+func LoadText(filename string) (spn.SPN, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadText(f)
+}
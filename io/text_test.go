@@ -0,0 +1,70 @@
+package io
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+func textSPN() spn.SPN {
+	R := spn.NewSum()
+	P1, P2 := spn.NewProduct(), spn.NewProduct()
+	X := spn.NewMultinomial(0, []float64{0.1234567891, 0.8765432109})
+	R.AddChildW(P1, 0.3)
+	R.AddChildW(P2, 0.7)
+	P1.AddChild(X)
+	P1.AddChild(spn.NewGaussianParams(1, 0.5, 1.25))
+	P2.AddChild(X)
+	P2.AddChild(spn.NewIndicator(1, 2))
+	return R
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	S := textSPN()
+	var b bytes.Buffer
+	if err := WriteText(&b, S); err != nil {
+		t.Fatal(err)
+	}
+	text := b.String()
+	T, err := ReadText(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !spn.Equal(S, T, 0) {
+		t.Errorf("Expected SPNs to be equal after round trip. Text:\n%s", text)
+	}
+	if S.ID() != T.ID() {
+		t.Errorf("Expected root ID %d, got %d.", S.ID(), T.ID())
+	}
+	var c bytes.Buffer
+	WriteText(&c, T)
+	if c.String() != text {
+		t.Errorf("Expected identical text after round trip, got:\n%s\nand\n%s", text, c.String())
+	}
+}
+
+func TestReadTextErrors(t *testing.T) {
+	bad := []string{
+		"",
+		"sum 1 2:0.5",
+		"indicator 1 0\n",
+		"indicator 1 0 1\nindicator 1 0 0",
+		"foo 1",
+		"leaf 1 unknown \"x\"",
+	}
+	for _, s := range bad {
+		if _, err := ReadText(strings.NewReader(s)); err == nil {
+			t.Errorf("Expected an error reading %q.", s)
+		}
+	}
+	S, err := ReadText(strings.NewReader("# comment\nindicator 3 0 1\n\nleaf 4 indicator \"0 0\\n\"\n" +
+		"sum 5 3:0.5 4:0.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := spn.Inference(S, spn.VarSet{0: 0}); v != -0.6931471805599453 {
+		t.Errorf("Expected ln 0.5, got %f.", v)
+	}
+}
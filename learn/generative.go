@@ -35,6 +35,22 @@ func Generative(S spn.SPN, D spn.Dataset) spn.SPN {
 // If a stack is used, perform a DFS. If a queue is used, BFS. If c is nil, we use a queue.
 // Argument norm indicates whether GenerativeGD should normalize weights at each node.
 func GenerativeGD(S spn.SPN, eta, eps float64, data spn.Dataset, c common.Collection, norm bool) spn.SPN {
+	return generativeGD(S, eta, eps, len(data), func(i, tk int, st *spn.Storer) {
+		spn.StoreInference(S, data[i], tk, st)
+	}, c, norm)
+}
+
+// GenerativeGDF is GenerativeGD for real-valued datasets. Leaves that do not accept real values
+// are evaluated on values rounded to the nearest integer (see spn.LeafF).
+func GenerativeGDF(S spn.SPN, eta, eps float64, data spn.DatasetF, c common.Collection, norm bool) spn.SPN {
+	return generativeGD(S, eta, eps, len(data), func(i, tk int, st *spn.Storer) {
+		spn.StoreInferenceF(S, data[i], tk, st)
+	}, c, norm)
+}
+
+// generativeGD performs generative gradient descent on n instances, where store stores the
+// inference values of the i-th instance under ticket tk of st.
+func generativeGD(S spn.SPN, eta, eps float64, n int, store func(i, tk int, st *spn.Storer), c common.Collection, norm bool) spn.SPN {
 	if c == nil {
 		c = &common.Queue{}
 	}
@@ -49,12 +65,10 @@ func GenerativeGD(S spn.SPN, eta, eps float64, data spn.Dataset, c common.Collec
 	for _l := 0; _l < P.Iterations; _l++ {
 		ollh = llh
 		llh = 0.0
-		n := len(data)
-		var i int
-		for _, I := range data {
+		for i := 0; i < n; i++ {
 			// Store inference values under T[itk].
 			sys.Println("Storing inference values...")
-			spn.StoreInference(S, I, itk, storage)
+			store(i, itk, storage)
 			lv, _ := storage.Single(itk, S)
 			// Store SPN derivatives under T[stk].
 			sys.Println("Computing dS(X)/dS...")
@@ -75,8 +89,7 @@ func GenerativeGD(S spn.SPN, eta, eps float64, data spn.Dataset, c common.Collec
 			// Add current log-value to log-likelihood.
 			sys.Printf("Log-value ln(S(X)) = %.3f\n", lv)
 			llh += lv
-			i++
-			sys.Printf("Instance %d/%d.\n", i, n)
+			sys.Printf("Instance %d/%d.\n", i+1, n)
 		}
 		sys.Printf("Log-likelihood value at this iteration: llh = %.3f\n", llh)
 		if sys.Verbose {
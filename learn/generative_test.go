@@ -224,3 +224,22 @@ func TestGenerativeGD(t *testing.T) {
 		return true
 	}, c)
 }
+
+func TestGenerativeGDF(t *testing.T) {
+	R, _ := initSimpleSPN()
+	T, _ := initSimpleSPN()
+	U, _ := initSimpleSPN()
+	D := spn.Dataset{{0: 1, 1: 0}, {0: 0, 1: 1}, {0: 1, 1: 1}}
+	F := make(spn.DatasetF, len(D))
+	for i, I := range D {
+		F[i] = spn.VarSet(I).Continuous()
+	}
+	GenerativeGD(R, 0.1, 0.01, D, nil, true)
+	GenerativeGDF(T, 0.1, 0.01, F, nil, true)
+	if !spn.Equal(R, T, 1e-12) {
+		t.Errorf("Expected GenerativeGDF to match GenerativeGD on discrete data.")
+	}
+	if spn.Equal(T, U, 1e-12) {
+		t.Errorf("Expected GenerativeGDF to change weights.")
+	}
+}
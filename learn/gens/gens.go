@@ -1,6 +1,8 @@
 package gens
 
 import (
+	"math"
+	"sort"
 	"sync"
 
 	"github.com/RenatoGeh/gospn/conc"
@@ -11,6 +13,12 @@ import (
 	"github.com/RenatoGeh/gospn/utils/indep"
 )
 
+// MaxLeafScope is the largest scope for which Learn stops recursing and returns a multivariate
+// leaf instead: a Chow-Liu tree if every variable in scope is discrete, or a multivariate gaussian
+// if every variable is continuous. Scopes mixing both keep being decomposed. The default value of 1
+// always factorizes down to univariate leaves.
+var MaxLeafScope = 1
+
 // Binded is a binded version of Gens.
 func Binded(kclusters int, pval, eps float64, mp int) learn.LearnFunc {
 	return func(sc map[int]*learn.Variable, data spn.Dataset) spn.SPN {
@@ -25,7 +33,12 @@ func LearnConcurrent(sc map[int]*learn.Variable, data []map[int]int, kclusters i
 		for _, v := range sc {
 			tv = v
 		}
-		return newMultinom(tv, data)
+		return newLeaf(tv, data)
+	}
+	if n <= MaxLeafScope {
+		if L := newMultiLeaf(sc, data); L != nil {
+			return L
+		}
 	}
 	vdata := learn.DataToVarData(data, sc)
 	igraph := indep.NewUFIndepGraph(vdata, pval)
@@ -50,7 +63,13 @@ func Learn(sc map[int]*learn.Variable, data []map[int]int, kclusters int, pval,
 		for _, v := range sc {
 			tv = v
 		}
-		return newMultinom(tv, data)
+		return newLeaf(tv, data)
+	}
+	// If the scope is small enough, we may return a multivariate leaf.
+	if n <= MaxLeafScope {
+		if L := newMultiLeaf(sc, data); L != nil {
+			return L
+		}
 	}
 
 	// Else we check for independent subsets of variables. We separate variables in k partitions,
@@ -69,10 +88,103 @@ func Learn(sc map[int]*learn.Variable, data []map[int]int, kclusters int, pval,
 	return clusterStep(1, kclusters, 0, pval, eps, mp, data, sc)
 }
 
+// newLeaf returns a univariate leaf over variable v fitted to data. The family of the leaf is
+// chosen by the type of v: binary variables get a bernoulli, count variables get either a poisson
+// or a geometric, continuous variables get either a gaussian, an exponential, a gamma or a
+// log-normal (the last three only if values are positive), and categorical and ordinal variables
+// get a multinomial. When more than one family applies, the one with the best BIC score is chosen.
+func newLeaf(v *learn.Variable, data []map[int]int) spn.SPN {
+	X := learn.ExtractInstance(v.Varid, data)
+	switch v.Type {
+	case learn.Binary:
+		// Laplace smoothing, just like in spn.NewCountingMultinomial.
+		var s int
+		for _, x := range X {
+			s += x
+		}
+		return spn.NewBernoulli(v.Varid, float64(s+1)/float64(len(X)+2))
+	case learn.Count:
+		return bestFit(X, spn.NewPoissonML(v.Varid, X), spn.NewGeometricML(v.Varid, X))
+	case learn.Continuous:
+		F := make([]float64, len(X))
+		pos := true
+		for i, x := range X {
+			F[i] = float64(x)
+			pos = pos && x > 0
+		}
+		L := []spn.SPN{spn.NewGaussianRaw(v.Varid, F)}
+		if pos {
+			L = append(L, spn.NewExponentialML(v.Varid, F), spn.NewGammaML(v.Varid, F),
+				spn.NewLogNormalML(v.Varid, F))
+		}
+		return bestFit(X, L...)
+	}
+	return newMultinom(v, data)
+}
+
+// newMultiLeaf returns a multivariate leaf over scope sc fitted to data: a Chow-Liu tree if all
+// variables are discrete, or a multivariate gaussian if all variables are continuous. Returns nil
+// if sc mixes discrete and continuous variables.
+func newMultiLeaf(sc map[int]*learn.Variable, data []map[int]int) spn.SPN {
+	V := make([]int, 0, len(sc))
+	var c int
+	for u, v := range sc {
+		V = append(V, u)
+		if v.Type == learn.Continuous {
+			c++
+		}
+	}
+	sort.Ints(V)
+	switch c {
+	case 0:
+		K := make([]int, len(V))
+		for i, u := range V {
+			K[i] = sc[u].Categories
+			for _, I := range data {
+				if I[u] >= K[i] {
+					K[i] = I[u] + 1
+				}
+			}
+		}
+		return spn.NewChowLiuML(V, K, data)
+	case len(V):
+		D := make(spn.DatasetF, len(data))
+		for i, I := range data {
+			D[i] = spn.VarSet(I).Continuous()
+		}
+		return spn.NewMVGaussianML(V, D)
+	}
+	return nil
+}
+
+// bestFit returns the leaf in L with the highest BIC score on data X.
+func bestFit(X []int, L ...spn.SPN) spn.SPN {
+	var b spn.SPN
+	bs := math.Inf(-1)
+	for _, l := range L {
+		v := l.Sc()[0]
+		var ll float64
+		for _, x := range X {
+			ll += l.Value(spn.VarSet{v: x})
+		}
+		s := ll - 0.5*float64(spn.Stats(l).Params)*math.Log(float64(len(X)))
+		if b == nil || s > bs {
+			b, bs = l, s
+		}
+	}
+	return b
+}
+
+// newMultinom returns a multinomial over variable v fitted to data. If data has values not
+// accounted for by v.Categories (e.g. count variables), categories are extended to fit them.
 func newMultinom(v *learn.Variable, data []map[int]int) spn.SPN {
 	counts := make([]int, v.Categories)
 	for i := range data {
-		counts[data[i][v.Varid]]++
+		x := data[i][v.Varid]
+		for x >= len(counts) {
+			counts = append(counts, 0)
+		}
+		counts[x]++
 	}
 	return spn.NewCountingMultinomial(v.Varid, counts)
 }
@@ -90,14 +202,8 @@ func newGaussMix(varid, g int, data []map[int]int) spn.SPN {
 func newFullyFactorized(g int, D []map[int]int, Sc map[int]*learn.Variable) spn.SPN {
 	prod := spn.NewProduct()
 	if g <= 0 {
-		m := len(D)
 		for _, v := range Sc {
-			counts := make([]int, v.Categories)
-			for i := 0; i < m; i++ {
-				counts[D[i][v.Varid]]++
-			}
-			leaf := spn.NewCountingMultinomial(v.Varid, counts)
-			prod.AddChild(leaf)
+			prod.AddChild(newLeaf(v, D))
 		}
 	} else {
 		for _, v := range Sc {
@@ -131,7 +237,7 @@ func indepStep(np, kc, g int, pval, eps float64, mp int, D []map[int]int, Sc map
 		nsc := make(map[int]*learn.Variable)
 		for j := 0; j < s; j++ {
 			t := (*kset)[id][j]
-			nsc[t] = &learn.Variable{Varid: t, Categories: Sc[t].Categories, Name: ""}
+			nsc[t] = &learn.Variable{Varid: t, Categories: Sc[t].Categories, Name: "", Type: Sc[t].Type}
 		}
 		var nc spn.SPN
 		if g > 0 {
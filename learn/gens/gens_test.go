@@ -0,0 +1,118 @@
+package gens
+
+import (
+	"math"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/learn"
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+// column returns data with values X for variable 0.
+func column(X ...int) []map[int]int {
+	D := make([]map[int]int, len(X))
+	for i, x := range X {
+		D[i] = map[int]int{0: x}
+	}
+	return D
+}
+
+// repeat returns each value x_i of X repeated n_i times, where N = {n_1, ..., n_k}.
+func repeat(X, N []int) []int {
+	var R []int
+	for i, x := range X {
+		for j := 0; j < N[i]; j++ {
+			R = append(R, x)
+		}
+	}
+	return R
+}
+
+func TestNewLeaf(t *testing.T) {
+	// Quantiles of an exponential distribution with mean 10.
+	var exp []int
+	for i := 0; i < 50; i++ {
+		exp = append(exp, 1+int(-10*math.Log(1-(float64(i)+0.5)/50)))
+	}
+	for _, c := range []struct {
+		t       learn.VarType
+		data    []int
+		subtype string
+	}{
+		{learn.Binary, []int{0, 1, 1, 0, 1}, "bernoulli"},
+		{learn.Categorical, []int{0, 2, 1, 2}, "multinomial"},
+		{learn.Ordinal, []int{3, 0, 1}, "multinomial"},
+		// Concentrated around the mean: poisson.
+		{learn.Count, repeat([]int{3, 4, 5, 6, 7}, []int{2, 5, 8, 5, 2}), "poisson"},
+		// Mostly zeros with a long tail: geometric.
+		{learn.Count, repeat([]int{0, 1, 2, 3, 5, 8, 13}, []int{20, 10, 5, 3, 2, 1, 1}), "geometric"},
+		// Negative values only fit a gaussian.
+		{learn.Continuous, []int{-3, 1, 4, -1, 5, -9, 2, 6}, "gaussian"},
+		{learn.Continuous, exp, "exponential"},
+		// Log-normal data.
+		{learn.Continuous, []int{1, 2, 3, 4, 6, 8, 12, 20, 35, 60, 100, 400}, "lognormal"},
+	} {
+		v := &learn.Variable{Varid: 0, Categories: 3, Type: c.t}
+		if L := newLeaf(v, column(c.data...)); L.SubType() != c.subtype {
+			t.Errorf("Expected a %s leaf for %s data %v, got %s.", c.subtype, c.t, c.data,
+				L.SubType())
+		}
+	}
+}
+
+func TestLearnMultiLeaf(t *testing.T) {
+	defer func(m int) { MaxLeafScope = m }(MaxLeafScope)
+	MaxLeafScope = 2
+
+	cont := map[int]*learn.Variable{
+		1: {Varid: 1, Type: learn.Continuous},
+		2: {Varid: 2, Type: learn.Continuous},
+	}
+	var data []map[int]int
+	for i := 0; i < 20; i++ {
+		data = append(data, map[int]int{1: i, 2: 2*i + i%3})
+	}
+	S := Learn(cont, data, 2, 0.01, 4.0, 4)
+	if S.SubType() != "mvgaussian" {
+		t.Fatalf("Expected a multivariate gaussian leaf, got %s.", S.SubType())
+	}
+	I := spn.VarSet{1: 7, 2: 15}
+	if v, u := spn.Inference(S, I), S.Value(I); v >= 0 || math.Abs(v-u) > 1e-12 {
+		t.Errorf("Expected Inference to evaluate the root leaf to %v, got %v.", u, v)
+	}
+	if v, u := spn.Inference(S, spn.VarSet{2: 15}), S.Value(spn.VarSet{2: 15}); v >= 0 ||
+		math.Abs(v-u) > 1e-12 {
+		t.Errorf("Expected marginal %v, got %v.", u, v)
+	}
+
+	disc := map[int]*learn.Variable{
+		0: {Varid: 0, Categories: 2, Type: learn.Binary},
+		1: {Varid: 1, Categories: 3},
+	}
+	data = nil
+	for i := 0; i < 30; i++ {
+		data = append(data, map[int]int{0: i % 2, 1: (i / 2) % 3})
+	}
+	S = LearnConcurrent(disc, data, 2, 0.01, 4.0, 4, 2)
+	if S.SubType() != "chowliu" {
+		t.Fatalf("Expected a Chow-Liu leaf, got %s.", S.SubType())
+	}
+	var z float64
+	for x := 0; x < 2; x++ {
+		for y := 0; y < 3; y++ {
+			z += math.Exp(spn.Inference(S, spn.VarSet{0: x, 1: y}))
+		}
+	}
+	if math.Abs(z-1) > 1e-9 {
+		t.Errorf("Expected probabilities to sum to 1, got %v.", z)
+	}
+	st := spn.NewStorer()
+	if _, tk := spn.StoreInference(S, spn.VarSet{0: 1}, -1, st); tk < 0 {
+		t.Errorf("Expected a valid ticket, got %d.", tk)
+	} else if v, _ := st.Single(tk, S); v != S.Value(spn.VarSet{0: 1}) {
+		t.Errorf("Expected stored value %v, got %v.", S.Value(spn.VarSet{0: 1}), v)
+	}
+	if _, _, M := spn.StoreMAP(S, spn.VarSet{0: 1}, -1, st); M[0] != 1 || len(M) != 2 {
+		t.Errorf("Expected a complete MAP assignment with X0=1, got %v.", M)
+	}
+}
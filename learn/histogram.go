@@ -0,0 +1,99 @@
+package learn
+
+import (
+	"github.com/RenatoGeh/gospn/common"
+	"github.com/RenatoGeh/gospn/spn"
+	"github.com/RenatoGeh/gospn/sys"
+	"math"
+)
+
+// histograms returns all spn.Histogram leaves in S, each mapped to its node in S. The two differ
+// when the leaf is wrapped in a spn.LazyLeaf.
+func histograms(S spn.SPN) map[*spn.Histogram]spn.SPN {
+	H := make(map[*spn.Histogram]spn.SPN)
+	spn.TopSortTarjanFunc(S, &common.Queue{}, func(s spn.SPN) bool {
+		D, _ := spn.Unlazy(s)
+		if h, ok := D.(*spn.Histogram); ok {
+			H[h] = s
+		}
+		return true
+	})
+	return H
+}
+
+// DeriveHistograms computes the derivative dS/dw, where w is a bin mass of a spn.Histogram leaf
+// L in SPN S. By the chain rule,
+// 	dS/dw_i = dS/dL * dL/dw_i
+// where dL/dw_i is given by spn.Histogram.Deriv. Argument storage holds the derivatives dS/dS_i
+// under ticket dtk (see DeriveSPN) and V is the valuation S was evaluated on. As every other
+// derivative in GoSPN, the returned derivatives are in logspace. Histograms not reached by dtk
+// are left out.
+func DeriveHistograms(S spn.SPN, storage *spn.Storer, dtk int, V spn.VarSetF) map[*spn.Histogram][]float64 {
+	D := make(map[*spn.Histogram][]float64)
+	for h, s := range histograms(S) {
+		dl, e := storage.Single(dtk, s)
+		if !e {
+			continue
+		}
+		d := h.Deriv(V)
+		for i := range d {
+			d[i] += dl
+		}
+		D[h] = d
+	}
+	return D
+}
+
+// HistogramEM learns the bin masses of every spn.Histogram leaf in SPN S through expectation
+// maximization on real-valued dataset data, leaving all other parameters untouched. At each
+// iteration, the expected number of instances falling into bin i of leaf L is
+// 	n_i = \sum_{X \in data} w_i * dS(X)/dw_i / S(X),
+// and bin masses are then updated to n_i + alpha, normalized. A positive alpha works as a
+// Dirichlet prior, preventing bins from becoming empty. The number of iterations is taken from
+// S's parameters.
+func HistogramEM(S spn.SPN, data spn.DatasetF, alpha float64) spn.SPN {
+	H := histograms(S)
+	if len(H) == 0 {
+		return S
+	}
+	c := &common.Queue{}
+	storage := spn.NewStorer()
+	dtk, itk := storage.NewTicket(), storage.NewTicket()
+	P := S.Parameters()
+	sys.Println("Initiating histogram EM...")
+	for _l := 0; _l < P.Iterations; _l++ {
+		N := make(map[*spn.Histogram][]float64, len(H))
+		for h := range H {
+			N[h] = make([]float64, len(h.Params()))
+		}
+		var llh float64
+		for _, I := range data {
+			spn.StoreInferenceF(S, I, itk, storage)
+			lv, _ := storage.Single(itk, S)
+			if math.IsInf(lv, -1) {
+				// Instance has zero density and carries no information on bin masses.
+				storage.Reset(itk)
+				continue
+			}
+			DeriveSPN(S, storage, dtk, itk, c)
+			for h, d := range DeriveHistograms(S, storage, dtk, I) {
+				W := h.Params()
+				for i := range d {
+					N[h][i] += math.Exp(math.Log(W[i]) + d[i] - lv)
+				}
+			}
+			storage.Reset(itk)
+			storage.Reset(dtk)
+			llh += lv
+		}
+		for h, n := range N {
+			for i := range n {
+				n[i] += alpha
+			}
+			h.SetMasses(n)
+		}
+		sys.Printf("Epoch %d: log-likelihood llh = %.3f\n", _l, llh)
+	}
+	sys.Println("Histogram EM done. Returning...")
+	return S
+}
@@ -0,0 +1,57 @@
+package learn
+
+import (
+	"github.com/RenatoGeh/gospn/spn"
+	"math"
+	"testing"
+)
+
+func llhF(S spn.SPN, D spn.DatasetF) float64 {
+	var l float64
+	for _, I := range D {
+		l += spn.InferenceF(S, I)
+	}
+	return l
+}
+
+func TestHistogramEM(t *testing.T) {
+	E := spn.UniformEdges(0, 4, 4)
+	D := spn.DatasetF{{0: 0.5, 1: 3.5}, {0: 0.2, 1: 2.5}, {0: 1.5, 1: 3.1}, {0: 3.9, 1: 3.2}}
+	// Marginals of a product are learnt independently: one iteration gives relative frequencies.
+	H, G := spn.NewHistogram(0, E, []float64{1, 1, 1, 1}), spn.NewHistogram(1, E, []float64{1, 1, 1, 1})
+	P := spn.NewProduct()
+	P.AddChild(H)
+	P.AddChild(G)
+	P.Parameters().Iterations = 1
+	HistogramEM(P, D, 0)
+	if W := H.Params(); math.Abs(W[0]-0.5) > 1e-12 || math.Abs(W[1]-0.25) > 1e-12 ||
+		W[2] != 0 || math.Abs(W[3]-0.25) > 1e-12 {
+		t.Errorf("Unexpected masses %v.", W)
+	}
+	if W := G.Params(); W[0] != 0 || W[1] != 0 || math.Abs(W[2]-0.25) > 1e-12 ||
+		math.Abs(W[3]-0.75) > 1e-12 {
+		t.Errorf("Unexpected masses %v.", W)
+	}
+	// EM never decreases the likelihood of a mixture.
+	S := spn.NewSum()
+	S.AddChildW(spn.NewHistogram(0, E, []float64{4, 3, 2, 1}), 0.3)
+	S.AddChildW(spn.NewHistogram(0, E, []float64{1, 1, 1, 1}), 0.7)
+	S.Parameters().Iterations = 1
+	l := llhF(S, D)
+	for i := 0; i < 5; i++ {
+		HistogramEM(S, D, 0)
+		u := llhF(S, D)
+		if u < l-1e-9 {
+			t.Errorf("Expected likelihood to not decrease, got %v after %v.", u, l)
+		}
+		l = u
+	}
+	// Lazily decoded histograms are learnt just the same.
+	T := lazyDecode(t, S)
+	T.Parameters().Iterations = 1
+	HistogramEM(S, D, 0)
+	HistogramEM(T, D, 0)
+	if u, v := llhF(S, D), llhF(T, D); math.Abs(u-v) > 1e-12 {
+		t.Errorf("Expected lazy histograms to reach log-likelihood %v, got %v.", u, v)
+	}
+}
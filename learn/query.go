@@ -0,0 +1,161 @@
+package learn
+
+import (
+	"math"
+
+	"github.com/RenatoGeh/gospn/spn"
+	"github.com/RenatoGeh/gospn/utils"
+)
+
+// Conditional returns the conditional probability ln P(Q=q | E=e), where Q is the query set and E
+// the evidence set. Both sets must be disjoint. If E is empty, Conditional returns the marginal
+// ln P(Q=q).
+func Conditional(S spn.SPN, Q, E spn.VarSet) float64 {
+	J := make(spn.VarSet)
+	for k, v := range E {
+		J[k] = v
+	}
+	for k, v := range Q {
+		J[k] = v
+	}
+	P := spn.Compile(S)
+	return P.Eval(J) - P.Eval(E)
+}
+
+// Marginals returns the posterior marginals P(X=k | E=e) of every variable X in the scope of S
+// that is not in evidence E. The result is a map varid -> []float64, where the k-th position of
+// each slice is the probability of that variable taking value k.
+//
+// Marginals performs a single upward pass (spn.StoreInference) and a single downward pass
+// (DeriveSPN). For a complete and decomposable SPN, the network polynomial is multilinear on the
+// leaves, and thus
+//  S(X=k, e) = \sum_{L : Sc(L)={X}} dS/dL(e) * L(X=k)
+// for every unobserved variable X. Only categorical leaves (spn.Multinomial and spn.Indicator)
+// are taken into account, with the number of categories of each variable taken as the largest
+// one found at its leaves.
+func Marginals(S spn.SPN, E spn.VarSet) map[int][]float64 {
+	return marginals(S, &spn.Evidence{Hard: E}, nil, catLeaf)
+}
+
+// MarginalsE is Marginals for soft and interval evidence (see spn.Evidence). Posteriors are
+// computed for every variable not in E.Hard, including those with soft or interval evidence, in
+// which case the posterior accounts for it, i.e. P(X=k | e) is proportional to
+//  \sum_{L : Sc(L)={X}} dS/dL(e) * L(X=k) * l_X(k)
+// where l_X(k) is the likelihood of X=k under E.
+func MarginalsE(S spn.SPN, E *spn.Evidence) map[int][]float64 {
+	return marginals(S, E, nil, catLeaf)
+}
+
+// Distribution returns the posterior distribution P(X=k | E=e) of a categorical variable X of ID
+// v, for every value k of X. Evidence on X itself is ignored.
+func Distribution(S spn.SPN, E spn.VarSet, v int) []float64 {
+	F := make(spn.VarSet)
+	for k, u := range E {
+		if k != v {
+			F[k] = u
+		}
+	}
+	return marginals(S, &spn.Evidence{Hard: F}, map[int]bool{v: true}, catLeaf)[v]
+}
+
+// Posteriors returns the posterior distributions P(X=k | E=e) of every variable X in scope Sc,
+// for every category k of X, in a single upward and downward pass, just like Marginals. Unlike
+// Marginals, Posteriors also accounts for spn.Gaussian, spn.DiscreteGaussian, spn.Histogram and
+// spn.PiecewiseLinear leaves, which are discretised over the integers {0,...,c-1}, with c the
+// number of categories of the variable as given by Sc. Observed variables are given a point mass
+// distribution at their evidence value.
+func Posteriors(S spn.SPN, E spn.VarSet, Sc map[int]*Variable) map[int][]float64 {
+	Z := make(map[int]bool)
+	for _, v := range Sc {
+		if _, e := E[v.Varid]; !e {
+			Z[v.Varid] = true
+		}
+	}
+	R := marginals(S, &spn.Evidence{Hard: E}, Z, func(L spn.SPN) (int, int, bool) {
+		D, _ := spn.Unlazy(L)
+		switch D.(type) {
+		case *spn.Multinomial, *spn.Indicator, *spn.Gaussian, *spn.DiscreteGaussian, *spn.Histogram,
+			*spn.PiecewiseLinear:
+			v := L.Sc()[0]
+			if u, e := Sc[v]; e {
+				return v, u.Categories, true
+			}
+		}
+		return -1, 0, false
+	})
+	for _, v := range Sc {
+		if k, e := E[v.Varid]; e {
+			P := make([]float64, v.Categories)
+			if k >= 0 && k < len(P) {
+				P[k] = 1
+			}
+			R[v.Varid] = P
+		}
+	}
+	return R
+}
+
+// catLeaf returns the variable ID and number of categories of a categorical leaf.
+func catLeaf(L spn.SPN) (int, int, bool) {
+	D, _ := spn.Unlazy(L)
+	switch t := D.(type) {
+	case *spn.Multinomial:
+		return L.Sc()[0], len(t.Pr()), true
+	case *spn.Indicator:
+		v, k := t.Params()
+		return v, k + 1, true
+	}
+	return -1, 0, false
+}
+
+// marginals computes Marginals restricted to the variables in Z. If Z is nil, every unobserved
+// variable is considered. Function cats returns the variable ID and number of categories of a
+// leaf, and whether it should be taken into account.
+func marginals(S spn.SPN, E *spn.Evidence, Z map[int]bool, cats func(spn.SPN) (int, int, bool)) map[int][]float64 {
+	st := spn.NewStorer()
+	_, itk := spn.StoreInferenceE(S, E, -1, st)
+	_, dtk := DeriveSPN(S, st, -1, itk, nil)
+	dt, _ := st.Table(dtk)
+
+	// Log-unnormalized posteriors.
+	M := make(map[int][]float64)
+	spn.BreadthFirst(S, func(L spn.SPN) int {
+		if L.Type() != "leaf" {
+			return 0
+		}
+		v, m, ok := cats(L)
+		if _, e := E.Hard[v]; !ok || e || (Z != nil && !Z[v]) {
+			return 0
+		}
+		d, e := dt.Single(L)
+		if !e {
+			return 0
+		}
+		P := M[v]
+		if len(P) < m {
+			P = append(P, make([]float64, m-len(P))...)
+			for k := len(M[v]); k < m; k++ {
+				P[k] = utils.LogZero
+			}
+		}
+		I := spn.VarSet{v: 0}
+		for k := 0; k < m; k++ {
+			I[v] = k
+			P[k] = utils.LogSumExpPair(P[k], d+L.Value(I)+E.LogLikelihood(v, k))
+		}
+		M[v] = P
+		return 0
+	})
+	st.Purge()
+
+	R := make(map[int][]float64)
+	for v, P := range M {
+		z := utils.LogSumExp(P)
+		Q := make([]float64, len(P))
+		for k, p := range P {
+			Q[k] = math.Exp(p - z)
+		}
+		R[v] = Q
+	}
+	return R
+}
@@ -0,0 +1,139 @@
+package learn
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/RenatoGeh/gospn/spn"
+)
+
+// lazyDecode returns a copy of S decoded with lazy leaves.
+func lazyDecode(t *testing.T, S spn.SPN) spn.SPN {
+	T, err := spn.DecodeWith(bytes.NewReader(spn.Marshal(S)), spn.DecodeOptions{Lazy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return T
+}
+
+// completeSPN returns a complete and decomposable SPN over variables 0 (ternary), 1 and 2
+// (binary).
+func completeSPN() spn.SPN {
+	R := spn.NewSum()
+	P1, P2, P3 := spn.NewProduct(), spn.NewProduct(), spn.NewProduct()
+	X1, X2 := spn.NewMultinomial(0, []float64{0.2, 0.5, 0.3}), spn.NewMultinomial(0, []float64{0.6, 0.1, 0.3})
+	Y1, Y2 := spn.NewMultinomial(1, []float64{0.7, 0.3}), spn.NewMultinomial(1, []float64{0.1, 0.9})
+	Z := spn.NewSum()
+	Z.AddChildW(spn.NewIndicator(2, 0), 0.4)
+	Z.AddChildW(spn.NewIndicator(2, 1), 0.6)
+	W := spn.NewMultinomial(2, []float64{0.8, 0.2})
+	P1.AddChild(X1)
+	P1.AddChild(Y1)
+	P1.AddChild(Z)
+	P2.AddChild(X2)
+	P2.AddChild(Y2)
+	P2.AddChild(W)
+	P3.AddChild(X1)
+	P3.AddChild(Y2)
+	P3.AddChild(Z)
+	R.AddChildW(P1, 0.5)
+	R.AddChildW(P2, 0.3)
+	R.AddChildW(P3, 0.2)
+	return R
+}
+
+func TestMarginals(t *testing.T) {
+	cats := map[int]int{0: 3, 1: 2, 2: 2}
+	for _, S := range []spn.SPN{completeSPN(), lazyDecode(t, completeSPN())} {
+		for _, E := range []spn.VarSet{{}, {0: 1}, {1: 0, 2: 1}} {
+			M := Marginals(S, E)
+			for v, m := range cats {
+				if _, e := E[v]; e {
+					if _, e := M[v]; e {
+						t.Errorf("Expected no marginal for observed variable %d.", v)
+					}
+					continue
+				}
+				for k := 0; k < m; k++ {
+					p := math.Exp(Conditional(S, spn.VarSet{v: k}, E))
+					if len(M[v]) != m || math.Abs(p-M[v][k]) > 1e-9 {
+						t.Errorf("Expected P(X_%d=%d|%v)=%f, got %v.", v, k, E, p, M[v])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMarginalsE(t *testing.T) {
+	S := completeSPN()
+	L := []float64{0.1, 0.7, 0.4}
+	E := &spn.Evidence{Soft: map[int][]float64{0: L}, Intervals: map[int]spn.Interval{2: {Lo: 0.5, Hi: 2}}}
+	M := MarginalsE(S, E)
+	// Brute force posteriors from the joint.
+	P := [][]float64{make([]float64, 3), make([]float64, 2), make([]float64, 2)}
+	var z float64
+	for x0 := 0; x0 < 3; x0++ {
+		for x1 := 0; x1 < 2; x1++ {
+			p := L[x0] * math.Exp(spn.Inference(S, spn.VarSet{0: x0, 1: x1, 2: 1}))
+			P[0][x0] += p
+			P[1][x1] += p
+			P[2][1] += p
+			z += p
+		}
+	}
+	for v := range P {
+		for k := range P[v] {
+			if p := P[v][k] / z; math.Abs(p-M[v][k]) > 1e-9 {
+				t.Errorf("Expected P(X_%d=%d|e)=%f, got %f.", v, k, p, M[v][k])
+			}
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	S := completeSPN()
+	E := spn.VarSet{0: 2, 1: 1}
+	P := Distribution(S, E, 0)
+	var s float64
+	for k, p := range P {
+		s += p
+		F := spn.VarSet{1: 1}
+		if q := math.Exp(Conditional(S, spn.VarSet{0: k}, F)); math.Abs(p-q) > 1e-9 {
+			t.Errorf("Expected P(X_0=%d|X_1=1)=%f, got %f.", k, q, p)
+		}
+	}
+	if math.Abs(s-1) > 1e-9 {
+		t.Errorf("Expected distribution to sum to 1, got %f.", s)
+	}
+}
+
+func TestPosteriors(t *testing.T) {
+	R := spn.NewSum()
+	P1, P2 := spn.NewProduct(), spn.NewProduct()
+	P1.AddChild(spn.NewGaussianParams(0, 1.0, 0.8))
+	P1.AddChild(spn.NewMultinomial(1, []float64{0.3, 0.7}))
+	P2.AddChild(spn.NewGaussianParams(0, 3.0, 1.2))
+	P2.AddChild(spn.NewMultinomial(1, []float64{0.9, 0.1}))
+	R.AddChildW(P1, 0.4)
+	R.AddChildW(P2, 0.6)
+	Sc := map[int]*Variable{0: {Varid: 0, Categories: 5}, 1: {Varid: 1, Categories: 2}}
+	E := spn.VarSet{1: 1}
+	M := Posteriors(R, E, Sc)
+	if P := M[1]; len(P) != 2 || P[0] != 0 || P[1] != 1 {
+		t.Errorf("Expected point mass at X_1=1, got %v.", P)
+	}
+	// Brute force: P(X_0=k|e) is proportional to S(X_0=k, e).
+	P := make([]float64, 5)
+	var z float64
+	for k := range P {
+		P[k] = math.Exp(spn.Inference(R, spn.VarSet{0: k, 1: 1}))
+		z += P[k]
+	}
+	for k := range P {
+		if p := P[k] / z; math.Abs(p-M[0][k]) > 1e-9 {
+			t.Errorf("Expected P(X_0=%d|e)=%f, got %f.", k, p, M[0][k])
+		}
+	}
+}
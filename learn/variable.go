@@ -3,6 +3,9 @@ package learn
 import (
 	"bytes"
 	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/RenatoGeh/gospn/spn"
 	"github.com/RenatoGeh/gospn/utils"
 )
@@ -11,26 +14,87 @@ func init() {
 	spn.RegisterGobType(&Variable{})
 }
 
-// Variable is a wrapper struct that contains the variable ID and its number of categories.
+// VarType is the statistical type of a variable. Structure learners use it to choose the family
+// of each leaf, as in Mixed SPNs.
+type VarType int
+
+// Constants to be used for Variable.Type.
+const (
+	Categorical VarType = iota // Unordered discrete values 0, 1, ..., Categories-1.
+	Ordinal                    // Ordered discrete values 0, 1, ..., Categories-1.
+	Binary                     // Discrete values 0 and 1.
+	Continuous                 // Real values.
+	Count                      // Non-negative integers with no upper bound.
+)
+
+var varTypeNames = [...]string{"categorical", "ordinal", "binary", "continuous", "count"}
+
+// String returns the name of this variable type.
+func (t VarType) String() string {
+	if t < 0 || int(t) >= len(varTypeNames) {
+		return "VarType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return varTypeNames[t]
+}
+
+// ParseVarType returns the variable type named s (case insensitive).
+func ParseVarType(s string) (VarType, error) {
+	for i, n := range varTypeNames {
+		if strings.EqualFold(s, n) {
+			return VarType(i), nil
+		}
+	}
+	return Categorical, fmt.Errorf("learn: unknown variable type %q", s)
+}
+
+// Discrete returns whether variables of this type take integer values.
+func (t VarType) Discrete() bool { return t != Continuous }
+
+// Variable is a wrapper struct that contains the variable ID, its number of categories and its
+// type.
 type Variable struct {
 	// Variable ID.
 	Varid int
-	// Number of categories.
+	// Number of categories. Only meaningful for categorical, ordinal and binary variables.
 	Categories int
 	// Variable name.
 	Name string
+	// Type of the variable. Defaults to Categorical.
+	Type VarType
 }
 
 func (v *Variable) GobEncode() ([]byte, error) {
 	var b bytes.Buffer
-	fmt.Fprintln(&b, v.Varid, v.Categories, v.Name)
+	fmt.Fprintf(&b, "%d %d %s %q\n", v.Varid, v.Categories, v.Type, v.Name)
 	return b.Bytes(), nil
 }
 
+// GobDecode decodes a variable encoded by GobEncode. Variables encoded by older versions of
+// GobEncode, which had no type, are decoded as Categorical.
 func (v *Variable) GobDecode(data []byte) error {
-	b := bytes.NewBuffer(data)
-	_, err := fmt.Fscanln(b, &v.Varid, &v.Categories, &v.Name)
-	return err
+	f := strings.SplitN(strings.TrimSpace(string(data)), " ", 4)
+	if len(f) < 2 {
+		return fmt.Errorf("learn: invalid variable encoding %q", data)
+	}
+	var err error
+	if v.Varid, err = strconv.Atoi(f[0]); err != nil {
+		return err
+	}
+	if v.Categories, err = strconv.Atoi(f[1]); err != nil {
+		return err
+	}
+	v.Type, v.Name = Categorical, ""
+	if len(f) == 4 && strings.HasPrefix(f[3], "\"") {
+		if v.Type, err = ParseVarType(f[2]); err != nil {
+			return err
+		}
+		v.Name, err = strconv.Unquote(f[3])
+		return err
+	}
+	if len(f) > 2 {
+		v.Name = f[2]
+	}
+	return nil
 }
 
 type Scope map[int]*Variable
@@ -169,21 +233,28 @@ func ReflectScope(Sc map[int]*Variable) map[int]*Variable {
 func CopyScope(Sc map[int]*Variable) map[int]*Variable {
 	nsc := make(map[int]*Variable)
 	for u, v := range Sc {
-		nsc[u] = &Variable{Varid: v.Varid, Categories: v.Categories, Name: v.Name}
+		nsc[u] = &Variable{Varid: v.Varid, Categories: v.Categories, Name: v.Name, Type: v.Type}
 	}
 	return nsc
 }
 
+// DataToVarData converts dataset D into a VarData for each variable in Sc. Variables with no
+// declared categories (e.g. continuous or count variables) get as many categories as needed to fit
+// their values in D.
 func DataToVarData(D []map[int]int, Sc map[int]*Variable) []*utils.VarData {
 	n := len(Sc)
 	vdata, l := make([]*utils.VarData, n), 0
 	for _, v := range Sc {
 		tn := len(D)
 		tdata := make([]int, tn)
+		c := v.Categories
 		for j := 0; j < tn; j++ {
 			tdata[j] = D[j][v.Varid]
+			if tdata[j] >= c {
+				c = tdata[j] + 1
+			}
 		}
-		vdata[l] = utils.NewVarData(v.Varid, v.Categories, tdata)
+		vdata[l] = utils.NewVarData(v.Varid, c, tdata)
 		l++
 	}
 	return vdata
@@ -0,0 +1,37 @@
+package learn
+
+import (
+	"testing"
+)
+
+func TestVariableGob(t *testing.T) {
+	for _, v := range []Variable{{3, 2, "X", Binary}, {1, 0, "a b", Continuous}, {0, 5, "", Count}} {
+		data, err := v.GobEncode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var u Variable
+		if err := u.GobDecode(data); err != nil || u != v {
+			t.Errorf("Expected %v, got %v (%v).", v, u, err)
+		}
+	}
+	// Encoding of older versions, with no type.
+	for d, v := range map[string]Variable{"2 4 Y\n": {2, 4, "Y", Categorical},
+		"2 4 \n": {2, 4, "", Categorical}} {
+		var u Variable
+		if err := u.GobDecode([]byte(d)); err != nil || u != v {
+			t.Errorf("Expected %v, got %v (%v).", v, u, err)
+		}
+	}
+}
+
+func TestParseVarType(t *testing.T) {
+	for _, T := range []VarType{Categorical, Ordinal, Binary, Continuous, Count} {
+		if u, err := ParseVarType(T.String()); err != nil || u != T {
+			t.Errorf("Expected %v, got %v (%v).", T, u, err)
+		}
+	}
+	if _, err := ParseVarType("nominal"); err == nil {
+		t.Errorf("Expected an error on unknown type.")
+	}
+}
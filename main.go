@@ -1,13 +1,16 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"os"
 	"path/filepath"
 
 	"github.com/RenatoGeh/gospn/app"
 	"github.com/RenatoGeh/gospn/io"
 	"github.com/RenatoGeh/gospn/learn/gens"
+	"github.com/RenatoGeh/gospn/spn"
 	"github.com/RenatoGeh/gospn/sys"
 	"github.com/RenatoGeh/gospn/utils"
 	//profile "github.com/pkg/profile"
@@ -20,6 +23,42 @@ func convertData() {
 	io.BufferedPGMFToData(cmn, "all.data")
 }
 
+// loadModel loads an SPN from filename. Files with extension .json are read with io.LoadJSON,
+// files with extension .txt with io.LoadText, files with extension .spflow with io.LoadSPFlow,
+// and any other file with io.LoadSPN.
+func loadModel(filename string) (spn.SPN, error) {
+	switch filepath.Ext(filename) {
+	case ".json":
+		S, _, err := io.LoadJSON(filename)
+		return S, err
+	case ".txt":
+		return io.LoadText(filename)
+	case ".spflow":
+		return io.LoadSPFlow(filename)
+	}
+	return io.LoadSPN(filename)
+}
+
+// printStats prints the statistics of each model in files, either as text or as JSON.
+func printStats(files []string, asJSON bool) {
+	R := make(map[string]*spn.Statistics)
+	for _, f := range files {
+		S, err := loadModel(f)
+		if err != nil {
+			fmt.Printf("Could not load model %s: %v\n", f, err)
+			os.Exit(1)
+		}
+		R[f] = spn.Stats(S)
+		if !asJSON {
+			fmt.Printf("%s:\n%s\n", f, R[f])
+		}
+	}
+	if asJSON {
+		data, _ := json.MarshalIndent(R, "", "  ")
+		fmt.Println(string(data))
+	}
+}
+
 func main() {
 	var p float64
 	var clusters int
@@ -27,6 +66,7 @@ func main() {
 	var iterations int
 	var concurrents int
 	var mode string
+	var asJSON bool
 
 	flag.Float64Var(&p, "p", 0.7, "Train/test partition ratio to be used for cross-validation. ")
 	flag.IntVar(&clusters, "clusters", -1, "Number of clusters to be used during training. If "+
@@ -53,14 +93,28 @@ func main() {
 		"completed.")
 	flag.IntVar(&sys.Max, "max", sys.Max, "The maximum pixel value the images can have.")
 	flag.StringVar(&mode, "mode", "cmpl", "Whether to convert a directory structure into a data "+
-		"file (data), run an image completion job (cmpl) or a classification job (class).")
+		"file (data), run an image completion job (cmpl), a classification job (class) or print "+
+		"the statistics of the models given as arguments (stats).")
+	flag.BoolVar(&asJSON, "json", false, "Print statistics as JSON when -mode=stats.")
 	flag.Float64Var(&sys.Pval, "pval", sys.Pval, "The significance value for the independence test.")
 	flag.Float64Var(&sys.Eps, "eps", sys.Eps, "The epsilon minimum distance value for DBSCAN.")
 	flag.IntVar(&sys.Mp, "mp", sys.Mp, "The minimum points density for DBSCAN.")
+	flag.IntVar(&gens.MaxLeafScope, "leafscope", gens.MaxLeafScope, "The largest scope for which "+
+		"Gens returns a multivariate leaf (Chow-Liu tree or multivariate gaussian) instead of "+
+		"decomposing it further.")
 	flag.BoolVar(&sys.Verbose, "v", sys.Verbose, "Verbose mode.")
 
 	flag.Parse()
 
+	if mode == "stats" {
+		if flag.NArg() == 0 {
+			fmt.Println("Mode stats requires at least one model file as argument.")
+			return
+		}
+		printStats(flag.Args(), asJSON)
+		return
+	}
+
 	if p == 0 || p < 0 || p == 1 {
 		fmt.Println("Argument p must be a float64 in range (0, 1).")
 		return
@@ -108,6 +162,6 @@ func main() {
 		//app.ImgCompletion(lf, "data/olivetti_padded/compiled/all.data", 1)
 		//learn.PoonTest(data, 2, 2)
 	} else {
-		fmt.Printf("Mode %s not found. Possible mode options:\n  cmpl, class, data\n", mode)
+		fmt.Printf("Mode %s not found. Possible mode options:\n  cmpl, class, data, stats\n", mode)
 	}
 }
@@ -86,36 +86,39 @@ func (s *S) Evaluate(T spn.Dataset, L []int, N spn.SPN, classVar *learn.Variable
 }
 
 // EvaluatePosterior evaluates the SPN classification score by computing the exact probabilities,
-// instead of the approximate MAP.
+// instead of the approximate MAP. The SPN is compiled once and each label is evaluated over the
+// whole dataset in a single batched pass (see spn.InferenceBatch).
 func (s *S) EvaluatePosterior(T spn.Dataset, L []int, N spn.SPN, classVar *learn.Variable) {
-	st := spn.NewStorer()
-	tk := st.NewTicket()
 	v := classVar.Varid
 	sys.Println("Evaluating scores...")
-	n := len(T) / 10
+	P := spn.Compile(N)
+	n := len(T)
+	ls := make([]int, n)
 	for i, I := range T {
-		if i > 0 && i%n == 0 {
-			sys.Printf("... %d%% ...\n", int(100.0*(float64(i)/float64(len(T)))))
-		}
-		l := I[v]
+		ls[i] = I[v]
 		delete(I, v)
-		spn.StoreInference(N, I, tk, st)
-		pe, _ := st.Single(tk, N)
-		mp := math.Inf(-1)
-		var ml int
-		for j := 0; j < classVar.Categories; j++ {
-			st.Reset(tk)
+	}
+	pe := P.EvalBatch(T, nil)
+	mp, ml := make([]float64, n), make([]int, n)
+	for i := range mp {
+		mp[i] = math.Inf(-1)
+	}
+	pj := make([]float64, n)
+	for j := 0; j < classVar.Categories; j++ {
+		sys.Printf("... label %d/%d ...\n", j+1, classVar.Categories)
+		for _, I := range T {
 			I[v] = j
-			spn.StoreInference(N, I, tk, st)
-			pj, _ := st.Single(tk, N)
-			if pd := pj - pe; pd > mp {
-				mp, ml = pd, j
+		}
+		P.EvalBatch(T, pj)
+		for i := range T {
+			if pd := pj[i] - pe[i]; pd > mp[i] {
+				mp[i], ml[i] = pd, j
 			}
-			delete(I, v)
 		}
-		s.Register(ml, L[i])
-		st.Reset(tk)
-		I[v] = l
+	}
+	for i, I := range T {
+		s.Register(ml[i], L[i])
+		I[v] = ls[i]
 	}
 }
 
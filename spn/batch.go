@@ -0,0 +1,140 @@
+package spn
+
+import (
+	"math"
+
+	"github.com/RenatoGeh/gospn/conc"
+	"github.com/RenatoGeh/gospn/utils"
+)
+
+// InferenceBatch returns the values S(I) for every instance I in dataset D. Instead of running
+// Inference once per instance, InferenceBatch compiles S once and sweeps the graph a single time,
+// keeping a value vector of length |D| for each node. Vectors are released as soon as every parent
+// of a node has been computed, so that memory usage is bounded by the widest frontier of the
+// graph instead of its total number of nodes.
+func InferenceBatch(S SPN, D Dataset) []float64 {
+	return Compile(S).EvalBatch(D, nil)
+}
+
+// InferenceBatchConc runs InferenceBatch concurrently, sharding D into k contiguous batches. If
+// k <= 0, k is set to the number of CPUs available.
+func InferenceBatchConc(S SPN, D Dataset, k int) []float64 {
+	return Compile(S).EvalBatchConc(D, nil, k)
+}
+
+// EvalBatch computes the value of the compiled SPN for every instance in D, storing the value of
+// D[j] in R[j]. If R is shorter than len(D), a new slice is allocated. Returns R.
+func (p *Plan) EvalBatch(D Dataset, R []float64) []float64 {
+	m := len(D)
+	if len(R) < m {
+		R = make([]float64, m)
+	}
+	if m == 0 {
+		return R
+	}
+	n := len(p.nodes)
+	// Number of parents yet to be computed for each node.
+	pa := make([]int, n)
+	for _, c := range p.ch {
+		pa[c]++
+	}
+	V := make([][]float64, n)
+	var free [][]float64
+	alloc := func() []float64 {
+		if k := len(free); k > 0 {
+			v := free[k-1]
+			free = free[:k-1]
+			return v
+		}
+		return make([]float64, m)
+	}
+	r := p.Root()
+	for i, Z := range p.nodes {
+		var v []float64
+		if i == r {
+			v = R[:m]
+		} else {
+			v = alloc()
+		}
+		ch := p.Ch(i)
+		switch p.kind[i] {
+		case kLeaf:
+			for j, I := range D {
+				v[j] = Z.Value(I)
+			}
+		case kSum:
+			a := p.off[i]
+			for j := range v {
+				v[j] = lseColumn(V, ch, p.lw[a:a+len(ch)], j)
+			}
+		case kProduct:
+			for j := range v {
+				v[j] = 0
+			}
+			for _, c := range ch {
+				for j, u := range V[c] {
+					v[j] += u
+				}
+			}
+		}
+		V[i] = v
+		for _, c := range ch {
+			if pa[c]--; pa[c] == 0 {
+				free = append(free, V[c])
+				V[c] = nil
+			}
+		}
+	}
+	return R
+}
+
+// EvalBatchConc runs EvalBatch concurrently, sharding D into k contiguous batches. If k <= 0, k is
+// set to the number of CPUs available. If R is shorter than len(D), a new slice is allocated.
+// Returns R.
+func (p *Plan) EvalBatchConc(D Dataset, R []float64, k int) []float64 {
+	m := len(D)
+	if len(R) < m {
+		R = make([]float64, m)
+	}
+	Q := conc.NewSingleQueue(k)
+	k = Q.Allowed()
+	if m < k {
+		return p.EvalBatch(D, R)
+	}
+	b := (m + k - 1) / k
+	for i := 0; i < k; i++ {
+		Q.Run(func(id int) {
+			l, u := id*b, (id+1)*b
+			if u > m {
+				u = m
+			}
+			if l < u {
+				p.EvalBatch(D[l:u], R[l:u])
+			}
+		}, i)
+	}
+	Q.Wait()
+	return R
+}
+
+// lseColumn computes the log-sum-exp of the j-th entries of the weighted children vectors, in the
+// same order of operations as utils.LogSumExp.
+func lseColumn(V [][]float64, ch []int, lw []float64, j int) float64 {
+	if len(ch) == 0 {
+		return utils.LogZero
+	}
+	max := V[ch[0]][j] + lw[0]
+	for i, c := range ch {
+		if v := V[c][j] + lw[i]; v > max {
+			max = v
+		}
+	}
+	if math.IsInf(max, 0) {
+		return max
+	}
+	var l float64
+	for i, c := range ch {
+		l += math.Exp(V[c][j] + lw[i] - max)
+	}
+	return math.Log(l) + max
+}
@@ -0,0 +1,119 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Bernoulli represents a Bernoulli distribution over a binary variable, with Pr(X=1)=p.
+type Bernoulli struct {
+	Node
+	// Variable ID
+	varid int
+	// Probability of success
+	p float64
+}
+
+// NewBernoulli constructs a new Bernoulli with probability of success p.
+func NewBernoulli(varid int, p float64) *Bernoulli {
+	return &Bernoulli{Node{sc: []int{varid}}, varid, p}
+}
+
+// NewBernoulliML constructs a new Bernoulli from the maximum likelihood estimate of data X, a slice
+// of zeros and ones.
+func NewBernoulliML(varid int, X []int) *Bernoulli {
+	return NewBernoulli(varid, meanInts(X))
+}
+
+// Type returns the type of this node.
+func (b *Bernoulli) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (b *Bernoulli) SubType() string { return "bernoulli" }
+
+// logProb returns ln(Pr(X=x)).
+func (b *Bernoulli) logProb(x int) float64 {
+	switch x {
+	case 0:
+		return math.Log(1 - b.p)
+	case 1:
+		return math.Log(b.p)
+	}
+	return math.Inf(-1)
+}
+
+// Interval returns the probability Pr(lo <= X <= hi), in logspace.
+func (b *Bernoulli) Interval(lo, hi float64) float64 { return intervalSum(lo, hi, 0, 1, b.logProb) }
+
+// mode returns the most probable value.
+func (b *Bernoulli) mode() int {
+	if b.p > 0.5 {
+		return 1
+	}
+	return 0
+}
+
+// Value returns the probability of a certain valuation. That is Pr(X=val[varid]), or 1 if the
+// variable is not set.
+func (b *Bernoulli) Value(val VarSet) float64 {
+	if v, ok := val[b.varid]; ok {
+		return b.logProb(v)
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (b *Bernoulli) Max(val VarSet) float64 {
+	if v, ok := val[b.varid]; ok {
+		return b.logProb(v)
+	}
+	return b.logProb(b.mode())
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+func (b *Bernoulli) ArgMax(val VarSet) (VarSet, float64) {
+	v, ok := val[b.varid]
+	if !ok {
+		v = b.mode()
+	}
+	return VarSet{b.varid: v}, b.logProb(v)
+}
+
+// Sample draws a value from this distribution if the variable is not set in val.
+func (b *Bernoulli) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[b.varid]; ok {
+		return
+	}
+	if rng.Float64() < b.p {
+		val[b.varid] = 1
+	} else {
+		val[b.varid] = 0
+	}
+}
+
+// Params returns the probability of success.
+func (b *Bernoulli) Params() float64 { return b.p }
+
+// Sc returns the scope of this node.
+func (b *Bernoulli) Sc() []int {
+	if len(b.sc) == 0 {
+		b.sc = []int{b.varid}
+	}
+	return b.sc
+}
+
+// GobEncode serializes this bernoulli node.
+func (b *Bernoulli) GobEncode() ([]byte, error) {
+	var w bytes.Buffer
+	fmt.Fprintln(&w, b.varid, b.p)
+	return w.Bytes(), nil
+}
+
+// GobDecode unserializes this bernoulli node.
+func (b *Bernoulli) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &b.varid, &b.p)
+	b.sc = []int{b.varid}
+	return err
+}
@@ -0,0 +1,136 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Binomial represents a binomial distribution over the number of successes in n independent
+// trials, each with probability of success p.
+type Binomial struct {
+	Node
+	// Variable ID
+	varid int
+	// Number of trials
+	n int
+	// Probability of success
+	p float64
+}
+
+// NewBinomial constructs a new Binomial with n trials and probability of success p.
+func NewBinomial(varid, n int, p float64) *Binomial {
+	return &Binomial{Node{sc: []int{varid}}, varid, n, p}
+}
+
+// NewBinomialML constructs a new Binomial with n trials from the maximum likelihood estimate of
+// data X, where each value is a number of successes in [0, n].
+func NewBinomialML(varid, n int, X []int) *Binomial {
+	var p float64
+	if n > 0 {
+		p = meanInts(X) / float64(n)
+	}
+	return NewBinomial(varid, n, p)
+}
+
+// Type returns the type of this node.
+func (b *Binomial) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (b *Binomial) SubType() string { return "binomial" }
+
+// logProb returns ln(Pr(X=x)).
+func (b *Binomial) logProb(x int) float64 {
+	if x < 0 || x > b.n {
+		return math.Inf(-1)
+	}
+	c := logFactorial(b.n) - logFactorial(x) - logFactorial(b.n-x)
+	// Avoid 0*log(0) at the boundaries.
+	if x > 0 {
+		c += float64(x) * math.Log(b.p)
+	}
+	if x < b.n {
+		c += float64(b.n-x) * math.Log(1-b.p)
+	}
+	return c
+}
+
+// Interval returns the probability Pr(lo <= X <= hi), in logspace.
+func (b *Binomial) Interval(lo, hi float64) float64 {
+	return intervalSum(lo, hi, 0, float64(b.n), b.logProb)
+}
+
+// mode returns the most probable value.
+func (b *Binomial) mode() int {
+	m := int(math.Floor(float64(b.n+1) * b.p))
+	if m > b.n {
+		return b.n
+	}
+	return m
+}
+
+// Value returns the probability of a certain valuation. That is Pr(X=val[varid]), or 1 if the
+// variable is not set.
+func (b *Binomial) Value(val VarSet) float64 {
+	if v, ok := val[b.varid]; ok {
+		return b.logProb(v)
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (b *Binomial) Max(val VarSet) float64 {
+	if v, ok := val[b.varid]; ok {
+		return b.logProb(v)
+	}
+	return b.logProb(b.mode())
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+func (b *Binomial) ArgMax(val VarSet) (VarSet, float64) {
+	v, ok := val[b.varid]
+	if !ok {
+		v = b.mode()
+	}
+	return VarSet{b.varid: v}, b.logProb(v)
+}
+
+// Sample draws a value from this distribution if the variable is not set in val.
+func (b *Binomial) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[b.varid]; ok {
+		return
+	}
+	var k int
+	for i := 0; i < b.n; i++ {
+		if rng.Float64() < b.p {
+			k++
+		}
+	}
+	val[b.varid] = k
+}
+
+// Params returns the number of trials and the probability of success.
+func (b *Binomial) Params() (int, float64) { return b.n, b.p }
+
+// Sc returns the scope of this node.
+func (b *Binomial) Sc() []int {
+	if len(b.sc) == 0 {
+		b.sc = []int{b.varid}
+	}
+	return b.sc
+}
+
+// GobEncode serializes this binomial node.
+func (b *Binomial) GobEncode() ([]byte, error) {
+	var w bytes.Buffer
+	fmt.Fprintln(&w, b.varid, b.n, b.p)
+	return w.Bytes(), nil
+}
+
+// GobDecode unserializes this binomial node.
+func (b *Binomial) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &b.varid, &b.n, &b.p)
+	b.sc = []int{b.varid}
+	return err
+}
@@ -0,0 +1,352 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/RenatoGeh/gospn/utils"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// ChowLiu represents a tree-shaped Bayesian network over categorical variables, as learnt by the
+// Chow-Liu algorithm. Each variable X_i is conditioned on at most one parent variable X_pa(i),
+// with Pr(X_i | X_pa(i)) given by a conditional probability table. Unobserved variables are summed
+// out (or maximized out) exactly by message passing over the tree.
+type ChowLiu struct {
+	Node
+	// Variable IDs, in topological order (parents come before children)
+	vars []int
+	// Number of categories of each variable
+	k []int
+	// Index of each variable's parent in vars, or -1 for the root
+	pa []int
+	// Conditional probability tables: pr[i][j][x] = Pr(X_i=x | X_pa(i)=j). The root has a single
+	// row.
+	pr [][][]float64
+	// Children of each variable
+	ch [][]int
+}
+
+// NewChowLiu constructs a new ChowLiu tree over variables vars, where variable vars[i] has k[i]
+// categories and parent vars[pa[i]] (pa[i] = -1 for the root). Variables must be given in
+// topological order, i.e. pa[i] < i for every non-root variable. Argument pr holds the
+// conditional probability tables, where pr[i][j][x] = Pr(X_i=x | X_pa(i)=j), and the root's
+// table has a single row.
+func NewChowLiu(vars, k, pa []int, pr [][][]float64) *ChowLiu {
+	c := &ChowLiu{vars: vars, k: k, pa: pa, pr: pr}
+	c.init()
+	return c
+}
+
+// NewChowLiuML constructs a new ChowLiu tree over variables sc from data D, where variable sc[i]
+// has k[i] categories. The tree is the maximum spanning tree of the complete graph weighted by the
+// empirical mutual information between each pair of variables, rooted at sc[0]. Conditional
+// probability tables are Laplace smoothed, just like in NewCountingMultinomial.
+func NewChowLiuML(sc, k []int, D Dataset) *ChowLiu {
+	n, N := len(sc), float64(len(D))
+	// Marginal and pairwise counts.
+	C := make([][]float64, n)
+	P := make([][][][]float64, n)
+	for i := range sc {
+		C[i] = make([]float64, k[i])
+		P[i] = make([][][]float64, n)
+		for j := i + 1; j < n; j++ {
+			P[i][j] = make([][]float64, k[i])
+			for x := range P[i][j] {
+				P[i][j][x] = make([]float64, k[j])
+			}
+		}
+	}
+	for _, I := range D {
+		for i, u := range sc {
+			C[i][I[u]]++
+			for j := i + 1; j < n; j++ {
+				P[i][j][I[u]][I[sc[j]]]++
+			}
+		}
+	}
+	// Mutual information.
+	M := make([][]float64, n)
+	for i := range M {
+		M[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			var m float64
+			for x := range P[i][j] {
+				for y, c := range P[i][j][x] {
+					if c > 0 {
+						m += c / N * math.Log(c*N/(C[i][x]*C[j][y]))
+					}
+				}
+			}
+			M[i][j], M[j][i] = m, m
+		}
+	}
+	// Prim's algorithm for the maximum spanning tree. Variables are added in topological order.
+	order, pa := []int{0}, make([]int, n)
+	in := make([]bool, n)
+	in[0], pa[0] = true, -1
+	best := make([]float64, n)
+	for i := 1; i < n; i++ {
+		best[i] = M[0][i]
+	}
+	for len(order) < n {
+		u := -1
+		for i := 0; i < n; i++ {
+			if !in[i] && (u < 0 || best[i] > best[u]) {
+				u = i
+			}
+		}
+		in[u] = true
+		order = append(order, u)
+		for i := 0; i < n; i++ {
+			if !in[i] && M[u][i] > best[i] {
+				best[i], pa[i] = M[u][i], u
+			}
+		}
+	}
+	// Conditional probability tables, reindexed in topological order.
+	pos := make([]int, n)
+	for i, u := range order {
+		pos[u] = i
+	}
+	vars, ks, pas := make([]int, n), make([]int, n), make([]int, n)
+	pr := make([][][]float64, n)
+	for i, u := range order {
+		vars[i], ks[i] = sc[u], k[u]
+		if pa[u] < 0 {
+			pas[i] = -1
+			pr[i] = [][]float64{laplace(C[u])}
+			continue
+		}
+		p := pa[u]
+		pas[i] = pos[p]
+		pr[i] = make([][]float64, k[p])
+		for j := range pr[i] {
+			R := make([]float64, k[u])
+			for x := range R {
+				if p < u {
+					R[x] = P[p][u][j][x]
+				} else {
+					R[x] = P[u][p][x][j]
+				}
+			}
+			pr[i][j] = laplace(R)
+		}
+	}
+	return NewChowLiu(vars, ks, pas, pr)
+}
+
+// laplace returns the Laplace smoothed distribution of counts C.
+func laplace(C []float64) []float64 {
+	var s float64
+	for _, c := range C {
+		s += c
+	}
+	P := make([]float64, len(C))
+	for i, c := range C {
+		P[i] = (c + 1) / (s + float64(len(C)))
+	}
+	return P
+}
+
+// init computes the scope and children of each variable.
+func (c *ChowLiu) init() {
+	c.sc = append([]int(nil), c.vars...)
+	sort.Ints(c.sc)
+	c.ch = make([][]int, len(c.vars))
+	for i, p := range c.pa {
+		if p >= 0 {
+			c.ch[p] = append(c.ch[p], i)
+		}
+	}
+}
+
+// Type returns the type of this node.
+func (c *ChowLiu) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (c *ChowLiu) SubType() string { return "chowliu" }
+
+// row returns the index of the conditional probability table row of the i-th variable given
+// assignment A.
+func (c *ChowLiu) row(i int, A []int) int {
+	if c.pa[i] < 0 {
+		return 0
+	}
+	return A[c.pa[i]]
+}
+
+// upward passes messages from the leaves of the tree up to the root given valuation val. It
+// returns, for each variable X_i and each of its values x, the log-value g[i][x] of the messages
+// received by X_i from its children (-Inf if x contradicts val), and the log-value of the whole
+// tree. Messages are sums over the children's values if max is false, or maxima otherwise.
+func (c *ChowLiu) upward(val VarSet, max bool) ([][]float64, float64) {
+	n := len(c.vars)
+	g, msg := make([][]float64, n), make([][]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		g[i] = make([]float64, c.k[i])
+		v, ok := val[c.vars[i]]
+		for x := range g[i] {
+			if ok && v != x {
+				g[i][x] = math.Inf(-1)
+				continue
+			}
+			for _, j := range c.ch[i] {
+				g[i][x] += msg[j][x]
+			}
+		}
+		msg[i] = make([]float64, len(c.pr[i]))
+		T := make([]float64, c.k[i])
+		for j, R := range c.pr[i] {
+			for x, p := range R {
+				T[x] = math.Log(p) + g[i][x]
+			}
+			if max {
+				_, msg[i][j] = argmax(T)
+			} else {
+				msg[i][j] = utils.LogSumExp(T)
+			}
+		}
+	}
+	return g, msg[0][0]
+}
+
+// downward assigns every variable in topological order, choosing the value of X_i from the
+// log-values of Pr(X_i=x | X_pa(i)) * g[i][x] through pick.
+func (c *ChowLiu) downward(g [][]float64, pick func([]float64) int) []int {
+	A := make([]int, len(c.vars))
+	for i := range c.vars {
+		R := c.pr[i][c.row(i, A)]
+		T := make([]float64, len(R))
+		for x, p := range R {
+			T[x] = math.Log(p) + g[i][x]
+		}
+		A[i] = pick(T)
+	}
+	return A
+}
+
+// argmax returns the index and value of the maximum of T.
+func argmax(T []float64) (int, float64) {
+	m := 0
+	for i := range T {
+		if T[i] > T[m] {
+			m = i
+		}
+	}
+	return m, T[m]
+}
+
+// Value returns the probability of a certain valuation, summing out unset variables.
+func (c *ChowLiu) Value(val VarSet) float64 {
+	for i, u := range c.vars {
+		if v, ok := val[u]; ok && (v < 0 || v >= c.k[i]) {
+			return math.Inf(-1)
+		}
+	}
+	_, v := c.upward(val, false)
+	return v
+}
+
+// Max returns the MAP value given a valuation.
+func (c *ChowLiu) Max(val VarSet) float64 {
+	_, v := c.ArgMax(val)
+	return v
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+func (c *ChowLiu) ArgMax(val VarSet) (VarSet, float64) {
+	for i, u := range c.vars {
+		if v, ok := val[u]; ok && (v < 0 || v >= c.k[i]) {
+			return VarSet{u: v}, math.Inf(-1)
+		}
+	}
+	g, v := c.upward(val, true)
+	A := c.downward(g, func(T []float64) int {
+		m, _ := argmax(T)
+		return m
+	})
+	M := make(VarSet, len(A))
+	for i, x := range A {
+		M[c.vars[i]] = x
+	}
+	return M, v
+}
+
+// Sample draws the unset variables of val from their distribution conditioned on the set ones.
+func (c *ChowLiu) Sample(val VarSet, rng *rand.Rand) {
+	g, z := c.upward(val, false)
+	if math.IsInf(z, -1) {
+		return
+	}
+	A := c.downward(g, func(T []float64) int {
+		s := utils.LogSumExp(T)
+		W := make([]float64, len(T))
+		for x, t := range T {
+			W[x] = math.Exp(t - s)
+		}
+		return sampleIndex(W, rng)
+	})
+	for i, x := range A {
+		if _, ok := val[c.vars[i]]; !ok {
+			val[c.vars[i]] = x
+		}
+	}
+}
+
+// Params returns the variables (in topological order), their number of categories, the index of
+// their parents and their conditional probability tables.
+func (c *ChowLiu) Params() ([]int, []int, []int, [][][]float64) { return c.vars, c.k, c.pa, c.pr }
+
+// GobEncode serializes this Chow-Liu tree node.
+func (c *ChowLiu) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d", len(c.vars))
+	for i := range c.vars {
+		fmt.Fprintf(&b, " %d %d %d", c.vars[i], c.k[i], c.pa[i])
+	}
+	for i := range c.pr {
+		for _, R := range c.pr[i] {
+			for _, p := range R {
+				fmt.Fprintf(&b, " %v", p)
+			}
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this Chow-Liu tree node.
+func (c *ChowLiu) GobDecode(data []byte) error {
+	b := bytes.NewBuffer(data)
+	var n int
+	if _, err := fmt.Fscanf(b, "%d", &n); err != nil {
+		return err
+	}
+	c.vars, c.k, c.pa = make([]int, n), make([]int, n), make([]int, n)
+	for i := 0; i < n; i++ {
+		if _, err := fmt.Fscanf(b, " %d %d %d", &c.vars[i], &c.k[i], &c.pa[i]); err != nil {
+			return err
+		}
+	}
+	c.pr = make([][][]float64, n)
+	for i := range c.pr {
+		m := 1
+		if c.pa[i] >= 0 {
+			m = c.k[c.pa[i]]
+		}
+		c.pr[i] = make([][]float64, m)
+		for j := range c.pr[i] {
+			c.pr[i][j] = make([]float64, c.k[i])
+			for x := range c.pr[i][j] {
+				if _, err := fmt.Fscanf(b, " %f", &c.pr[i][j][x]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	c.init()
+	return nil
+}
@@ -0,0 +1,189 @@
+package spn
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+
+	"github.com/RenatoGeh/gospn/learn/parameters"
+)
+
+// Clone returns a deep copy of SPN S. The copy preserves the DAG structure of S: a node shared by
+// many parents in S is copied once, and the copy is shared by the copies of the same parents.
+// Weights, scopes, leaf parameters and bound learning parameters (see Parameters) are copied, so
+// that the copy can be changed (e.g. trained) without affecting the original. Cloned nodes are
+// given new IDs.
+//
+// Nodes of types unknown to this package are copied through gob, and thus must implement
+// GobEncoder and GobDecoder and be registered through RegisterGobType. Clone panics otherwise.
+func Clone(S SPN) SPN {
+	M := make(map[SPN]SPN)
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		C := cloneNode(Z)
+		ch := Z.Ch()
+		var nch []SPN
+		if ch != nil {
+			nch = make([]SPN, len(ch))
+			for i, c := range ch {
+				nch[i] = M[c]
+			}
+		}
+		C.setCh(nch)
+		if sc := Z.rawSc(); sc != nil {
+			C.setRawSc(append([]int(nil), sc...))
+		} else {
+			C.setRawSc(nil)
+		}
+		if p, e := parameters.Retrieve(Z); e {
+			q := *p
+			parameters.Bind(C, &q)
+		}
+		M[Z] = C
+		return true
+	})
+	return M[S]
+}
+
+// cloneNode returns a copy of node Z, without its children.
+func cloneNode(Z SPN) SPN {
+	switch T := unlazy(Z).(type) {
+	case *Sum:
+		return &Sum{w: append([]float64(nil), T.w...)}
+	case *Product:
+		return &Product{}
+	case *Multinomial:
+		return &Multinomial{varid: T.varid, pr: append([]float64(nil), T.pr...), mode: T.mode}
+	case *Gaussian:
+		return &Gaussian{varid: T.varid, dist: T.dist}
+	case *Indicator:
+		return &Indicator{varid: T.varid, v: T.v}
+	}
+	var b bytes.Buffer
+	if err := encodeSPN(gob.NewEncoder(&b), unlazy(Z)); err != nil {
+		panic(err)
+	}
+	C, err := decodeSPN(gob.NewDecoder(&b))
+	if err != nil {
+		panic(err)
+	}
+	return C
+}
+
+// Equal returns whether SPNs A and B are structurally equal up to tolerance eps. Two SPNs are
+// equal if there is a one-to-one mapping between their nodes that preserves types, subtypes,
+// scopes, children (in order) and sharing, and such that mapped sum nodes have weights equal up to
+// eps and mapped leaves have parameters equal up to eps. Node IDs are not compared.
+//
+// Leaves of types unknown to this package are compared through their GobEncode output, and are
+// considered different if they do not implement gob.GobEncoder.
+func Equal(A, B SPN, eps float64) bool {
+	F := make(map[SPN]SPN)
+	G := make(map[SPN]SPN)
+	Sa := make(map[SPN][]int)
+	Sb := make(map[SPN][]int)
+	var eq func(a, b SPN) bool
+	eq = func(a, b SPN) bool {
+		if m, e := F[a]; e {
+			return m == b
+		}
+		if m, e := G[b]; e {
+			return m == a
+		}
+		if a.Type() != b.Type() || a.SubType() != b.SubType() {
+			return false
+		}
+		ca, cb := a.Ch(), b.Ch()
+		if len(ca) != len(cb) {
+			return false
+		}
+		F[a], G[b] = b, a
+		for i := range ca {
+			if !eq(ca[i], cb[i]) {
+				return false
+			}
+		}
+		if !equalInts(scopeMemo(a, Sa), scopeMemo(b, Sb)) {
+			return false
+		}
+		if len(ca) == 0 {
+			return equalLeaves(a, b, eps)
+		}
+		if a.Type() == "sum" {
+			return equalFloats(a.(*Sum).w, b.(*Sum).w, eps)
+		}
+		return true
+	}
+	return eq(A, B)
+}
+
+// scopeMemo computes the scope of S from its leaves, ignoring cached scopes, and memoizes it in M.
+func scopeMemo(S SPN, M map[SPN][]int) []int {
+	if sc, e := M[S]; e {
+		return sc
+	}
+	ch := S.Ch()
+	var sc []int
+	if len(ch) == 0 {
+		sc = uniqueSorted(S.Sc())
+	} else {
+		var u []int
+		for _, c := range ch {
+			u = append(u, scopeMemo(c, M)...)
+		}
+		sc = uniqueSorted(u)
+	}
+	M[S] = sc
+	return sc
+}
+
+// equalLeaves returns whether leaves a and b, of same subtype, have equal parameters up to eps.
+func equalLeaves(a, b SPN, eps float64) bool {
+	a, b = unlazy(a), unlazy(b)
+	switch T := a.(type) {
+	case *Multinomial:
+		return equalFloats(T.pr, b.(*Multinomial).pr, eps)
+	case *Gaussian:
+		U := b.(*Gaussian)
+		return math.Abs(T.dist.Mu-U.dist.Mu) <= eps && math.Abs(T.dist.Sigma-U.dist.Sigma) <= eps
+	case *Indicator:
+		return T.v == b.(*Indicator).v
+	}
+	ea, oa := a.(gob.GobEncoder)
+	eb, ob := b.(gob.GobEncoder)
+	if !oa || !ob {
+		return false
+	}
+	pa, err := ea.GobEncode()
+	if err != nil {
+		return false
+	}
+	pb, err := eb.GobEncode()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(pa, pb)
+}
+
+func equalFloats(a, b []float64, eps float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > eps {
+			return false
+		}
+	}
+	return true
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package spn
+
+import (
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	S := sampleSPN()
+	C := Clone(S)
+	if !Equal(S, C, 0) {
+		t.Fatalf("Expected clone to be equal to the original.")
+	}
+	if n, m := countNodes(S), countNodes(C); n != m {
+		t.Errorf("Expected sharing to be preserved, got %d nodes from %d.", m, n)
+	}
+	sameValues(t, S, C, allInstances())
+	G, H := Index(S), Index(C)
+	for _, Z := range H.Nodes() {
+		if _, e := G.Node(Z.ID()); e {
+			t.Errorf("Expected cloned nodes to have new IDs.")
+		}
+	}
+	// Changing the clone must not affect the original.
+	C.(*Sum).w[0] = 0.5
+	C.Ch()[1].Ch()[0].(*Sum).w[0] = 0.1
+	if Equal(S, C, 1e-3) {
+		t.Errorf("Expected changed clone to differ from the original.")
+	}
+	if w := S.(*Sum).w[0]; w != 0.3 {
+		t.Errorf("Expected original weight 0.3, got %f.", w)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	S := sampleSPN()
+	if !Equal(S, Unmarshal(Marshal(S)), 1e-6) {
+		t.Errorf("Expected SPN to be equal after a Marshal/Unmarshal round trip.")
+	}
+	T := sampleSPN()
+	T.Ch()[0].Ch()[0].(*Multinomial).pr[0] = 0.89
+	if Equal(S, T, 1e-3) || !Equal(S, T, 0.02) {
+		t.Errorf("Expected leaf parameters to be compared up to tolerance.")
+	}
+	// Same values, but without sharing.
+	U := sampleSPN()
+	Y11 := U.Ch()[1].Ch()[0].Ch()[0]
+	U.Ch()[1].Ch()[0].Ch()[0] = Clone(Y11)
+	if Equal(S, U, 0) || Equal(U, S, 0) {
+		t.Errorf("Expected sharing to be compared.")
+	}
+}
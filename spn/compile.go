@@ -0,0 +1,306 @@
+package spn
+
+import (
+	"math"
+	"sync"
+
+	"github.com/RenatoGeh/gospn/sys"
+	"github.com/RenatoGeh/gospn/utils"
+)
+
+// Node kinds as stored in a Plan.
+const (
+	kLeaf = iota
+	kSum
+	kProduct
+)
+
+// Plan is a compiled, immutable and index-based evaluation plan of an SPN. Nodes are laid out in a
+// contiguous array in dependency order (children always come before their parents, the root being
+// the last node). Children of node i are ch[off[i]:off[i+1]], and for sum nodes lw[off[i]+j] is
+// the log-weight of the j-th child edge.
+//
+// A Plan takes a snapshot of the graph and its weights at compile time. Any change to the
+// structure or weights of the original SPN requires compiling a new Plan. Leaves are not copied,
+// which means leaf parameters are read from the original nodes at evaluation time.
+//
+// All evaluation methods are safe for concurrent use.
+type Plan struct {
+	// Nodes in dependency order.
+	nodes []SPN
+	// Node kinds.
+	kind []byte
+	// Child offset table.
+	off []int
+	// Children indices.
+	ch []int
+	// Log-weights aligned with ch. Zero for product edges.
+	lw []float64
+	// Node -> index lookup.
+	index map[SPN]int
+	// Scratch buffers of size len(nodes).
+	pool sync.Pool
+}
+
+// Compile compiles SPN S into an evaluation Plan.
+func Compile(S SPN) *Plan {
+	p := &Plan{index: make(map[SPN]int)}
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		p.index[Z] = len(p.nodes)
+		p.nodes = append(p.nodes, Z)
+		return true
+	})
+	n := len(p.nodes)
+	p.kind = make([]byte, n)
+	p.off = make([]int, n+1)
+	for i, Z := range p.nodes {
+		ch := Z.Ch()
+		switch Z.Type() {
+		case "sum":
+			p.kind[i] = kSum
+			W := Z.(*Sum).Weights()
+			for j, c := range ch {
+				p.ch = append(p.ch, p.index[c])
+				p.lw = append(p.lw, math.Log(W[j]))
+			}
+		case "product":
+			p.kind[i] = kProduct
+			for _, c := range ch {
+				p.ch = append(p.ch, p.index[c])
+				p.lw = append(p.lw, 0)
+			}
+		default:
+			p.kind[i] = kLeaf
+		}
+		p.off[i+1] = len(p.ch)
+	}
+	p.pool.New = func() interface{} {
+		b := make([]float64, n)
+		return &b
+	}
+	return p
+}
+
+// Len returns the number of nodes in this plan.
+func (p *Plan) Len() int { return len(p.nodes) }
+
+// Root returns the index of the root node.
+func (p *Plan) Root() int { return len(p.nodes) - 1 }
+
+// Node returns the i-th node in dependency order.
+func (p *Plan) Node(i int) SPN { return p.nodes[i] }
+
+// IndexOf returns the index of node S in this plan and whether S is part of it.
+func (p *Plan) IndexOf(S SPN) (int, bool) {
+	i, e := p.index[S]
+	return i, e
+}
+
+// Ch returns the indices of the children of the i-th node.
+func (p *Plan) Ch(i int) []int { return p.ch[p.off[i]:p.off[i+1]] }
+
+func (p *Plan) buffer(V []float64) []float64 {
+	if len(V) < len(p.nodes) {
+		return make([]float64, len(p.nodes))
+	}
+	return V
+}
+
+// lse computes the log-sum-exp of the weighted children values of the i-th node. It follows the
+// exact same operations as utils.LogSumExp, so that results are identical to Sum.Compute.
+func (p *Plan) lse(i int, V []float64) float64 {
+	a, b := p.off[i], p.off[i+1]
+	if a == b {
+		return utils.LogZero
+	}
+	max := V[p.ch[a]] + p.lw[a]
+	for j := a; j < b; j++ {
+		if v := V[p.ch[j]] + p.lw[j]; v > max {
+			max = v
+		}
+	}
+	if math.IsInf(max, 0) {
+		return max
+	}
+	var l float64
+	for j := a; j < b; j++ {
+		l += math.Exp(V[p.ch[j]] + p.lw[j] - max)
+	}
+	return math.Log(l) + max
+}
+
+// Values computes the value of every node given the valuation I, storing node i's value in V[i].
+// If V is shorter than Len(), a new slice is allocated. Returns V.
+func (p *Plan) Values(I VarSet, V []float64) []float64 {
+	return p.values(func(Z SPN) float64 { return Z.Value(I) }, V)
+}
+
+// values computes the value of every node, evaluating leaves with leaf.
+func (p *Plan) values(leaf func(SPN) float64, V []float64) []float64 {
+	V = p.buffer(V)
+	for i, Z := range p.nodes {
+		switch p.kind[i] {
+		case kLeaf:
+			V[i] = leaf(Z)
+		case kSum:
+			V[i] = p.lse(i, V)
+		case kProduct:
+			var r float64
+			for _, c := range p.Ch(i) {
+				r += V[c]
+			}
+			V[i] = r
+		}
+	}
+	return V
+}
+
+// Eval returns the value of the compiled SPN given the valuation I.
+func (p *Plan) Eval(I VarSet) float64 {
+	b := p.pool.Get().(*[]float64)
+	V := p.Values(I, *b)
+	v := V[p.Root()]
+	p.pool.Put(b)
+	return v
+}
+
+// MaxValues computes the max-product value of every node given the evidence I, storing node i's
+// value in V[i]. If V is shorter than Len(), a new slice is allocated. Returns V.
+func (p *Plan) MaxValues(I VarSet, V []float64) []float64 {
+	return p.maxValues(func(Z SPN) float64 { return Z.Max(I) }, V)
+}
+
+// maxValues computes the max-product value of every node, evaluating leaves with leaf.
+func (p *Plan) maxValues(leaf func(SPN) float64, V []float64) []float64 {
+	V = p.buffer(V)
+	for i, Z := range p.nodes {
+		switch p.kind[i] {
+		case kLeaf:
+			V[i] = leaf(Z)
+		case kSum:
+			mv := math.Inf(-1)
+			for j := p.off[i]; j < p.off[i+1]; j++ {
+				if u := p.lw[j] + V[p.ch[j]]; u > mv {
+					mv = u
+				}
+			}
+			V[i] = mv
+		case kProduct:
+			var r float64
+			for _, c := range p.Ch(i) {
+				r += V[c]
+			}
+			V[i] = r
+		}
+	}
+	return V
+}
+
+// trace follows the max children of each sum node given the max-product values V, breaking ties
+// at random, and returns the MAP state found at the leaves.
+func (p *Plan) trace(I VarSet, V []float64) VarSet {
+	M := make(VarSet)
+	p.traceLeaves(V, func(L SPN) {
+		N, _ := L.ArgMax(I)
+		for k, v := range N {
+			M[k] = v
+		}
+	})
+	return M
+}
+
+// traceLeaves follows the max children of each sum node given the max-product values V, breaking
+// ties at random, and calls leaf on every leaf reached.
+func (p *Plan) traceLeaves(V []float64, leaf func(SPN)) {
+	n := len(p.nodes)
+	Q := make([]int, 0, n)
+	vis := make([]bool, n)
+	r := p.Root()
+	Q = append(Q, r)
+	vis[r] = true
+	var mv []int
+	for len(Q) > 0 {
+		i := Q[0]
+		Q = Q[1:]
+		switch p.kind[i] {
+		case kLeaf:
+			leaf(p.nodes[i])
+		case kSum:
+			m := math.Inf(-1)
+			mv = mv[:0]
+			for j := p.off[i]; j < p.off[i+1]; j++ {
+				c := p.ch[j]
+				if u := p.lw[j] + V[c]; u > m {
+					mv, m = append(mv[:0], c), u
+				} else if u == m {
+					mv = append(mv, c)
+				}
+			}
+			if len(mv) == 0 {
+				continue
+			}
+			// Randomly break ties.
+			if c := mv[sys.RandIntn(len(mv))]; !vis[c] {
+				Q = append(Q, c)
+				vis[c] = true
+			}
+		case kProduct:
+			for _, c := range p.Ch(i) {
+				if !vis[c] {
+					Q = append(Q, c)
+					vis[c] = true
+				}
+			}
+		}
+	}
+}
+
+// EvalMAP returns the max-product approximation of the MAP state given evidence I and its
+// max-product value. Ties are broken at random.
+func (p *Plan) EvalMAP(I VarSet) (VarSet, float64) {
+	b := p.pool.Get().(*[]float64)
+	V := p.MaxValues(I, *b)
+	M := p.trace(I, V)
+	v := V[p.Root()]
+	p.pool.Put(b)
+	return M, v
+}
+
+// Derivatives computes the derivative dS/dS_i of the root with respect to every node i, given the
+// node values V computed by Values. Derivatives are in logspace, just like in learn.DeriveSPN. The
+// derivative of node i is stored in D[i]. If D is shorter than Len(), a new slice is allocated.
+// Returns D.
+func (p *Plan) Derivatives(V, D []float64) []float64 {
+	D = p.buffer(D)
+	r := p.Root()
+	for i := range p.nodes {
+		D[i] = utils.LogZero
+	}
+	D[r] = 0
+	for i := r; i >= 0; i-- {
+		pv := D[i]
+		if math.IsInf(pv, -1) {
+			continue
+		}
+		switch p.kind[i] {
+		case kSum:
+			for j := p.off[i]; j < p.off[i+1]; j++ {
+				c := p.ch[j]
+				D[c] = utils.LogSumExpPair(D[c], p.lw[j]+pv)
+			}
+		case kProduct:
+			a, b := p.off[i], p.off[i+1]
+			for j := a; j < b; j++ {
+				t := pv
+				for k := a; k < b; k++ {
+					if k != j {
+						t += V[p.ch[k]]
+					}
+				}
+				c := p.ch[j]
+				D[c] = utils.LogSumExpPair(D[c], t)
+			}
+		}
+	}
+	return D
+}
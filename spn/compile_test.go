@@ -0,0 +1,98 @@
+package spn
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func allInstances() []VarSet {
+	var D []VarSet
+	for i := 0; i < 16; i++ {
+		D = append(D, VarSet{0: i & 1, 1: (i >> 1) & 1, 2: (i >> 2) & 1, 3: (i >> 3) & 1})
+	}
+	return D
+}
+
+func TestPlanEval(t *testing.T) {
+	S := sampleSPN()
+	P := Compile(S)
+	for _, I := range append(allInstances(), VarSet{0: 1, 2: 0}, VarSet{}) {
+		if v, u := S.Value(I), P.Eval(I); v != u {
+			t.Errorf("Expected (Value) %f == %f (Eval), got different for %v.", v, u, I)
+		}
+		V := P.Values(I, nil)
+		for i := 0; i < P.Len(); i++ {
+			if v := P.Node(i).Value(I); v != V[i] {
+				t.Errorf("Expected node %d value %f, got %f.", i, v, V[i])
+			}
+		}
+	}
+}
+
+func TestPlanEvalAllocs(t *testing.T) {
+	S := sampleSPN()
+	P := Compile(S)
+	I := VarSet{0: 1, 2: 0}
+	P.Eval(I)
+	if n := testing.AllocsPerRun(100, func() { P.Eval(I) }); n > 0 {
+		t.Errorf("Expected Eval to allocate nothing, got %.1f allocations per run.", n)
+	}
+}
+
+func TestPlanEvalMAP(t *testing.T) {
+	S := sampleSPN()
+	P := Compile(S)
+	I := VarSet{0: 1, 2: 0}
+	M, v := P.EvalMAP(I)
+	N, u := S.ArgMax(I)
+	if !reflect.DeepEqual(M, N) {
+		t.Errorf("Expected MAP states to be equal, got different.\n  M=%v\n  N=%v", M, N)
+	}
+	if math.Abs(v-u) > 1e-12 {
+		t.Errorf("Expected MAP values to be equal, got %f and %f.", v, u)
+	}
+}
+
+func TestPlanDerivatives(t *testing.T) {
+	S := sampleSPN()
+	P := Compile(S)
+	for _, I := range allInstances() {
+		V := P.Values(I, nil)
+		D := P.Derivatives(V, nil)
+		if D[P.Root()] != 0 {
+			t.Errorf("Expected dS/dS = 0 (logspace), got %f.", D[P.Root()])
+		}
+		// dS/dS_i * S_i for every child i of the root sums to S.
+		var s float64
+		for _, c := range P.Ch(P.Root()) {
+			s += math.Exp(D[c] + V[c])
+		}
+		if e := math.Exp(V[P.Root()]); math.Abs(s-e) > 1e-9 {
+			t.Errorf("Expected %f, got %f.", e, s)
+		}
+		// Derivatives of the root's children are their weights.
+		W := S.(*Sum).Weights()
+		for j, c := range P.Ch(P.Root()) {
+			if w := math.Log(W[j]); math.Abs(D[c]-w) > 1e-12 {
+				t.Errorf("Expected dS/dS_%d = %f, got %f.", j, w, D[c])
+			}
+		}
+	}
+}
+
+func TestInferenceBatch(t *testing.T) {
+	S := sampleSPN()
+	var D Dataset
+	for _, I := range allInstances() {
+		D = append(D, I)
+	}
+	D = append(D, map[int]int{0: 1, 2: 0}, map[int]int{})
+	R, Q := InferenceBatch(S, D), InferenceBatchConc(S, D, 3)
+	for j, I := range D {
+		if v := Inference(S, I); v != R[j] || v != Q[j] {
+			t.Errorf("Expected (Inference) %f == %f (InferenceBatch) == %f (InferenceBatchConc).", v, R[j],
+				Q[j])
+		}
+	}
+}
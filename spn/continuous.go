@@ -0,0 +1,158 @@
+package spn
+
+import (
+	"math"
+
+	"github.com/RenatoGeh/gospn/sys"
+)
+
+// LeafF is a leaf that can be evaluated on real-valued instantiations. Leaves that do not
+// implement LeafF (e.g. Multinomial and Indicator) are evaluated on a VarSetF by rounding the
+// values of their variables to the nearest integer.
+type LeafF interface {
+	// ValueF returns the value of this leaf given a real-valued instantiation.
+	ValueF(val VarSetF) float64
+	// MaxF returns the MAP value of this leaf given a real-valued evidence.
+	MaxF(val VarSetF) float64
+	// ArgMaxF returns both the arguments and the value of the MAP state given a real-valued
+	// evidence.
+	ArgMaxF(val VarSetF) (VarSetF, float64)
+}
+
+// Discrete returns the VarSet that results from rounding the values of I to the nearest integer.
+func (I VarSetF) Discrete() VarSet {
+	J := make(VarSet, len(I))
+	for k, v := range I {
+		J[k] = int(math.Round(v))
+	}
+	return J
+}
+
+// Continuous returns I as a VarSetF.
+func (I VarSet) Continuous() VarSetF {
+	J := make(VarSetF, len(I))
+	for k, v := range I {
+		J[k] = float64(v)
+	}
+	return J
+}
+
+// discrete returns the VarSet of the variables in sc that are set in I, rounded to the nearest
+// integer.
+func (I VarSetF) discrete(sc []int) VarSet {
+	J := make(VarSet, len(sc))
+	for _, k := range sc {
+		if v, e := I[k]; e {
+			J[k] = int(math.Round(v))
+		}
+	}
+	return J
+}
+
+// valueF returns the value of leaf L given the real-valued instantiation I.
+func valueF(L SPN, I VarSetF) float64 {
+	if f, ok := L.(LeafF); ok {
+		return f.ValueF(I)
+	}
+	return L.Value(I.discrete(L.Sc()))
+}
+
+// maxF returns the MAP value of leaf L given the real-valued evidence I.
+func maxF(L SPN, I VarSetF) float64 {
+	if f, ok := L.(LeafF); ok {
+		return f.MaxF(I)
+	}
+	return L.Max(I.discrete(L.Sc()))
+}
+
+// argMaxF returns the MAP state and value of leaf L given the real-valued evidence I.
+func argMaxF(L SPN, I VarSetF) (VarSetF, float64) {
+	if f, ok := L.(LeafF); ok {
+		return f.ArgMaxF(I)
+	}
+	M, v := L.ArgMax(I.discrete(L.Sc()))
+	return M.Continuous(), v
+}
+
+// ValuesF computes the value of every node given the real-valued instantiation I, storing node
+// i's value in V[i]. If V is shorter than Len(), a new slice is allocated. Returns V.
+func (p *Plan) ValuesF(I VarSetF, V []float64) []float64 {
+	return p.values(func(Z SPN) float64 { return valueF(Z, I) }, V)
+}
+
+// EvalF returns the value of the compiled SPN given the real-valued instantiation I.
+func (p *Plan) EvalF(I VarSetF) float64 {
+	b := p.pool.Get().(*[]float64)
+	V := p.ValuesF(I, *b)
+	v := V[p.Root()]
+	p.pool.Put(b)
+	return v
+}
+
+// MaxValuesF computes the max-product value of every node given the real-valued evidence I,
+// storing node i's value in V[i]. If V is shorter than Len(), a new slice is allocated. Returns V.
+func (p *Plan) MaxValuesF(I VarSetF, V []float64) []float64 {
+	return p.maxValues(func(Z SPN) float64 { return maxF(Z, I) }, V)
+}
+
+// traceF is trace for real-valued evidence.
+func (p *Plan) traceF(I VarSetF, V []float64) VarSetF {
+	M := make(VarSetF)
+	p.traceLeaves(V, func(L SPN) {
+		N, _ := argMaxF(L, I)
+		for k, v := range N {
+			M[k] = v
+		}
+	})
+	return M
+}
+
+// EvalMAPF returns the max-product approximation of the MAP state given the real-valued evidence
+// I and its max-product value. Ties are broken at random.
+func (p *Plan) EvalMAPF(I VarSetF) (VarSetF, float64) {
+	b := p.pool.Get().(*[]float64)
+	V := p.MaxValuesF(I, *b)
+	M := p.traceF(I, V)
+	v := V[p.Root()]
+	p.pool.Put(b)
+	return M, v
+}
+
+// InferenceF returns the value of S(I) given the real-valued instantiation I (see Inference).
+func InferenceF(S SPN, I VarSetF) float64 {
+	return Compile(S).EvalF(I)
+}
+
+// StoreInferenceF is StoreInference for real-valued instantiations.
+func StoreInferenceF(S SPN, I VarSetF, tk int, storage *Storer) (SPN, int) {
+	if tk < 0 {
+		tk = storage.NewTicket()
+	}
+
+	P := Compile(S)
+	V := P.ValuesF(I, nil)
+
+	table, _ := storage.Table(tk)
+	for i, v := range V {
+		table.StoreSingle(P.Node(i), v)
+	}
+	sys.Free()
+	return S, tk
+}
+
+// StoreMAPF is StoreMAP for real-valued evidence.
+func StoreMAPF(S SPN, I VarSetF, tk int, storage *Storer) (SPN, int, VarSetF) {
+	if tk < 0 {
+		tk = storage.NewTicket()
+	}
+
+	P := Compile(S)
+	V := P.MaxValuesF(I, nil)
+
+	tab, _ := storage.Table(tk)
+	for i, v := range V {
+		tab.StoreSingle(P.Node(i), v)
+	}
+
+	return S, tk, P.traceF(I, V)
+}
@@ -0,0 +1,50 @@
+package spn
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInferenceF(t *testing.T) {
+	S := sampleSPN()
+	for _, I := range allInstances() {
+		if u, v := Inference(S, I), InferenceF(S, I.Continuous()); u != v {
+			t.Errorf("Expected %v, got %v for %v.", u, v, I)
+		}
+	}
+	R := NewSum()
+	P := NewProduct()
+	R.AddChildW(P, 0.4)
+	R.AddChildW(NewGaussianParams(0, 1, 2), 0.6)
+	P.AddChild(NewGaussianParams(0, -0.5, 0.25))
+	I := VarSetF{0: 0.3}
+	g := func(x, mu, sigma float64) float64 {
+		return math.Exp(-(x-mu)*(x-mu)/(2*sigma*sigma)) / (sigma * math.Sqrt(2*math.Pi))
+	}
+	e := math.Log(0.4*g(0.3, -0.5, 0.25) + 0.6*g(0.3, 1, 2))
+	if v := InferenceF(R, I); math.Abs(v-e) > 1e-12 {
+		t.Errorf("Expected %v, got %v.", e, v)
+	}
+	st := NewStorer()
+	_, tk := StoreInferenceF(R, I, -1, st)
+	if v, _ := st.Single(tk, R); math.Abs(v-e) > 1e-12 {
+		t.Errorf("Expected stored value %v, got %v.", e, v)
+	}
+	_, _, M := StoreMAPF(R, VarSetF{}, -1, st)
+	if M[0] != -0.5 {
+		t.Errorf("Expected MAP state 0=-0.5, got %v.", M)
+	}
+	// A single leaf is evaluated as well.
+	G := NewGaussianParams(0, 1, 2)
+	if v := InferenceF(G, I); math.Abs(v-math.Log(g(0.3, 1, 2))) > 1e-12 {
+		t.Errorf("Expected %v, got %v.", math.Log(g(0.3, 1, 2)), v)
+	}
+	if _, tk = StoreInferenceF(G, I, -1, st); tk < 0 {
+		t.Errorf("Expected a valid ticket, got %d.", tk)
+	} else if v, _ := st.Single(tk, G); math.Abs(v-math.Log(g(0.3, 1, 2))) > 1e-12 {
+		t.Errorf("Expected stored value %v, got %v.", math.Log(g(0.3, 1, 2)), v)
+	}
+	if _, _, M = StoreMAPF(G, VarSetF{}, -1, st); M[0] != 1 {
+		t.Errorf("Expected MAP state 0=1, got %v.", M)
+	}
+}
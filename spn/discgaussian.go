@@ -0,0 +1,167 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"gonum.org/v1/gonum/stat/distuv"
+	"math"
+	"math/rand"
+)
+
+// DiscreteGaussian represents a gaussian of mean mu and standard deviation sigma discretized over
+// the integers, where the probability of an integer x is the gaussian mass over the unit bin
+// [x-1/2, x+1/2]. The support may be truncated to the integers {lo,...,hi}, in which case
+// probabilities are renormalized over the support. Unlike Gaussian, which is a density, values of
+// a DiscreteGaussian are probabilities, and so it is suited for integer data such as pixel
+// intensities.
+type DiscreteGaussian struct {
+	Node
+	// Variable ID
+	varid int
+	// Mean
+	mu float64
+	// Standard deviation
+	sigma float64
+	// Support bounds (possibly infinite)
+	lo, hi float64
+	// Log-mass of the support
+	z float64
+}
+
+// NewDiscreteGaussian constructs a new DiscreteGaussian with mean mu and standard deviation sigma
+// over all integers.
+func NewDiscreteGaussian(varid int, mu, sigma float64) *DiscreteGaussian {
+	return newDiscreteGaussian(varid, mu, sigma, math.Inf(-1), math.Inf(1))
+}
+
+// NewTruncatedGaussian constructs a new DiscreteGaussian with mean mu and standard deviation sigma
+// truncated to the integers {lo,...,hi}.
+func NewTruncatedGaussian(varid int, mu, sigma float64, lo, hi int) *DiscreteGaussian {
+	return newDiscreteGaussian(varid, mu, sigma, float64(lo), float64(hi))
+}
+
+// NewTruncatedGaussianRaw constructs a new DiscreteGaussian truncated to the integers {lo,...,hi}
+// from the mean and standard deviation of vals. The variance is floored by MinGaussianVariance.
+func NewTruncatedGaussianRaw(varid, lo, hi int, vals []float64) *DiscreteGaussian {
+	g := NewGaussianRaw(varid, vals)
+	return NewTruncatedGaussian(varid, g.dist.Mu, g.dist.Sigma, lo, hi)
+}
+
+func newDiscreteGaussian(varid int, mu, sigma, lo, hi float64) *DiscreteGaussian {
+	g := &DiscreteGaussian{Node{sc: []int{varid}}, varid, mu, sigma, lo, hi, 0}
+	g.normalize()
+	return g
+}
+
+// normalize computes the log-mass of the support.
+func (g *DiscreteGaussian) normalize() {
+	if g.sigma == 0 {
+		g.z = 0
+		return
+	}
+	g.z = normalMass(g.mu, g.sigma, g.lo-0.5, g.hi+0.5)
+}
+
+// Type returns the type of this node.
+func (g *DiscreteGaussian) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (g *DiscreteGaussian) SubType() string { return "discretegaussian" }
+
+// mode returns the integer of highest probability, namely the mean rounded to the nearest integer
+// and clamped to the support.
+func (g *DiscreteGaussian) mode() int {
+	return int(math.Max(g.lo, math.Min(g.hi, math.Round(g.mu))))
+}
+
+// Interval returns the probability Pr(a <= X <= b), in logspace.
+func (g *DiscreteGaussian) Interval(a, b float64) float64 {
+	a, b = math.Max(math.Ceil(a), g.lo), math.Min(math.Floor(b), g.hi)
+	if a > b {
+		return math.Inf(-1)
+	}
+	if g.sigma == 0 {
+		if m := float64(g.mode()); a <= m && m <= b {
+			return 0
+		}
+		return math.Inf(-1)
+	}
+	return normalMass(g.mu, g.sigma, a-0.5, b+0.5) - g.z
+}
+
+// logProb returns ln(Pr(X=x)).
+func (g *DiscreteGaussian) logProb(x int) float64 {
+	return g.Interval(float64(x), float64(x))
+}
+
+// Value returns the probability of a certain valuation. That is Pr(X=val[varid]), or 1 if the
+// variable is not set.
+func (g *DiscreteGaussian) Value(val VarSet) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (g *DiscreteGaussian) Max(val VarSet) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
+	}
+	return g.logProb(g.mode())
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+func (g *DiscreteGaussian) ArgMax(val VarSet) (VarSet, float64) {
+	v, ok := val[g.varid]
+	if !ok {
+		v = g.mode()
+	}
+	return VarSet{g.varid: v}, g.logProb(v)
+}
+
+// Sample draws a value from this distribution if the variable is not set in val. A real value is
+// drawn from the gaussian truncated to [lo-1/2, hi+1/2] by inverting its cumulative distribution,
+// and then rounded to the nearest integer.
+func (g *DiscreteGaussian) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[g.varid]; ok {
+		return
+	}
+	if g.sigma == 0 {
+		val[g.varid] = g.mode()
+		return
+	}
+	N := distuv.Normal{Mu: g.mu, Sigma: g.sigma}
+	a, b := N.CDF(g.lo-0.5), N.CDF(g.hi+0.5)
+	x := math.Round(N.Quantile(a + rng.Float64()*(b-a)))
+	val[g.varid] = int(math.Max(g.lo, math.Min(g.hi, x)))
+}
+
+// Params returns the mean and standard deviation of the underlying gaussian.
+func (g *DiscreteGaussian) Params() (float64, float64) { return g.mu, g.sigma }
+
+// Support returns the bounds of the support, which are infinite if it is not truncated.
+func (g *DiscreteGaussian) Support() (float64, float64) { return g.lo, g.hi }
+
+// Sc returns the scope of this node.
+func (g *DiscreteGaussian) Sc() []int {
+	if len(g.sc) == 0 {
+		g.sc = []int{g.varid}
+	}
+	return g.sc
+}
+
+// GobEncode serializes this discrete gaussian node.
+func (g *DiscreteGaussian) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, g.varid, g.mu, g.sigma, g.lo, g.hi)
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this discrete gaussian node.
+func (g *DiscreteGaussian) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &g.varid, &g.mu, &g.sigma, &g.lo, &g.hi)
+	g.sc = []int{g.varid}
+	g.normalize()
+	return err
+}
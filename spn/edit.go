@@ -0,0 +1,250 @@
+package spn
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sort"
+)
+
+// Graph editing operations. All operations change the graph in place. Operations that may change
+// the root return the new root, which should be used from then on.
+
+// RemoveChild removes the i-th child of node P, together with its weight if P is a sum node, and
+// returns the removed child. The scope of P is recomputed, but the scopes of P's ancestors are
+// not (see ComputeScope).
+func RemoveChild(P SPN, i int) SPN {
+	ch := P.Ch()
+	c := ch[i]
+	nch := make([]SPN, 0, len(ch)-1)
+	nch = append(append(nch, ch[:i]...), ch[i+1:]...)
+	P.setCh(nch)
+	if s, ok := P.(*Sum); ok && i < len(s.w) {
+		w := make([]float64, 0, len(s.w)-1)
+		s.w = append(append(w, s.w[:i]...), s.w[i+1:]...)
+	}
+	P.setRawSc(localScope(nch))
+	return c
+}
+
+// Replace replaces every edge to node O in the SPN rooted at S with an edge to node N, and returns
+// the new root (N if S is O). Edges that belong to the subgraph rooted at N are kept, so that no
+// cycles are introduced. Scopes are recomputed.
+func Replace(S, O, N SPN) SPN {
+	if S == O {
+		return N
+	}
+	keep := make(map[SPN]bool)
+	TopSortTarjanFunc(N, nil, func(Z SPN) bool {
+		keep[Z] = true
+		return true
+	})
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		if keep[Z] {
+			return true
+		}
+		for i, c := range Z.Ch() {
+			if c == O {
+				Z.Ch()[i] = N
+			}
+		}
+		return true
+	})
+	ComputeScope(S)
+	return S
+}
+
+// Prune removes, from every sum node of S, the children whose normalized weight is below eps,
+// and renormalizes the remaining weights. The child with the highest weight is never removed, so
+// that no sum node is left without children. Returns S.
+func Prune(S SPN, eps float64) SPN {
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		s, ok := Z.(*Sum)
+		if !ok || len(s.w) == 0 {
+			return true
+		}
+		var z float64
+		m := 0
+		for i, w := range s.w {
+			z += w
+			if w > s.w[m] {
+				m = i
+			}
+		}
+		if z <= 0 {
+			return true
+		}
+		var ch []SPN
+		var W []float64
+		for i, w := range s.w {
+			if i == m || w/z >= eps {
+				ch = append(ch, s.ch[i])
+				W = append(W, w)
+			}
+		}
+		norm(W)
+		s.ch, s.w = ch, W
+		return true
+	})
+	return S
+}
+
+// Collapse simplifies the structure of S without changing the distribution it represents. Inner
+// nodes with a single child are replaced by that child, sum nodes absorb the children of their sum
+// children (multiplying weights along the way) and product nodes absorb the children of their
+// product children. Repeated children of a sum node are merged by adding their weights. Collapse
+// assumes S is normalized, as single-child sum nodes are removed regardless of their weight.
+// Returns the new root.
+func Collapse(S SPN) SPN {
+	R := make(map[SPN]SPN)
+	get := func(c SPN) SPN {
+		if r, e := R[c]; e {
+			return r
+		}
+		return c
+	}
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		switch Z.Type() {
+		case "sum":
+			s := Z.(*Sum)
+			var ch []SPN
+			var W []float64
+			P := make(map[SPN]int)
+			add := func(c SPN, w float64) {
+				if j, e := P[c]; e {
+					W[j] += w
+					return
+				}
+				P[c] = len(ch)
+				ch, W = append(ch, c), append(W, w)
+			}
+			for i, c := range s.ch {
+				c = get(c)
+				if t, ok := c.(*Sum); ok {
+					for j, d := range t.ch {
+						add(d, s.w[i]*t.w[j])
+					}
+				} else {
+					add(c, s.w[i])
+				}
+			}
+			s.ch, s.w = ch, W
+		case "product":
+			var ch []SPN
+			for _, c := range Z.Ch() {
+				c = get(c)
+				if c.Type() == "product" {
+					ch = append(ch, c.Ch()...)
+				} else {
+					ch = append(ch, c)
+				}
+			}
+			Z.setCh(ch)
+		default:
+			return true
+		}
+		if ch := Z.Ch(); len(ch) == 1 {
+			R[Z] = ch[0]
+		}
+		return true
+	})
+	return get(S)
+}
+
+// MergeIdentical merges structurally identical subgraphs of S into a single shared subgraph. Two
+// leaves are identical if they have the same subtype, scope and parameters. Two inner nodes are
+// identical if they have the same type and the same (merged) children, and, for sum nodes, the
+// same weight for each child. The order of children is not taken into account. Leaves of types
+// unknown to this package are compared through their GobEncode output, and are never merged if
+// they do not implement gob.GobEncoder. Returns the new root.
+func MergeIdentical(S SPN) SPN {
+	R := make(map[SPN]SPN)
+	K := make(map[string]SPN)
+	id := make(map[SPN]int)
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		ch := Z.Ch()
+		for i, c := range ch {
+			if r, e := R[c]; e {
+				ch[i] = r
+			}
+		}
+		var k string
+		var ok bool
+		if len(ch) == 0 {
+			k, ok = leafKey(Z)
+		} else {
+			k, ok = innerKey(Z, id), true
+		}
+		if ok {
+			if r, e := K[k]; e {
+				R[Z] = r
+				return true
+			}
+			K[k] = Z
+		}
+		id[Z] = len(id)
+		return true
+	})
+	if r, e := R[S]; e {
+		return r
+	}
+	return S
+}
+
+// leafKey returns a key identifying the parameters of leaf L, and whether such a key exists.
+func leafKey(L SPN) (string, bool) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %v ", L.SubType(), L.Sc())
+	switch T := unlazy(L).(type) {
+	case *Multinomial:
+		fmt.Fprintf(&b, "%v", T.pr)
+	case *Gaussian:
+		fmt.Fprintf(&b, "%v %v", T.dist.Mu, T.dist.Sigma)
+	case *Indicator:
+		fmt.Fprintf(&b, "%d", T.v)
+	case gob.GobEncoder:
+		p, err := T.GobEncode()
+		if err != nil {
+			return "", false
+		}
+		b.Write(p)
+	default:
+		return "", false
+	}
+	return b.String(), true
+}
+
+// innerKey returns a key identifying inner node Z by its type, children and weights. Children
+// must have already been merged, and id must hold an identifier for each of them.
+func innerKey(Z SPN, id map[SPN]int) string {
+	ch := Z.Ch()
+	E := make([]string, len(ch))
+	var W []float64
+	if s, ok := Z.(*Sum); ok {
+		W = s.w
+	}
+	for i, c := range ch {
+		if W != nil {
+			E[i] = fmt.Sprintf("%d:%v", id[c], W[i])
+		} else {
+			E[i] = fmt.Sprintf("%d", id[c])
+		}
+	}
+	sort.Strings(E)
+	return fmt.Sprintf("%s %v", Z.Type(), E)
+}
+
+// localScope returns the union of the scopes of nodes ch.
+func localScope(ch []SPN) []int {
+	M := make(map[int]bool)
+	var sc []int
+	for _, c := range ch {
+		for _, v := range c.Sc() {
+			if !M[v] {
+				M[v] = true
+				sc = append(sc, v)
+			}
+		}
+	}
+	return sc
+}
@@ -0,0 +1,141 @@
+package spn
+
+import (
+	"math"
+	"testing"
+)
+
+func countNodes(S SPN) int {
+	var n int
+	TopSortTarjanFunc(S, nil, func(SPN) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func sameValues(t *testing.T, S, T SPN, D []VarSet) {
+	for _, I := range D {
+		if v, u := Inference(S, I), Inference(T, I); math.Abs(v-u) > 1e-9 {
+			t.Errorf("Expected %f, got %f for %v.", v, u, I)
+		}
+	}
+}
+
+func TestRemoveChild(t *testing.T) {
+	S := NewSum()
+	a, b, c := NewIndicator(0, 0), NewIndicator(0, 1), NewIndicator(0, 2)
+	S.AddChildW(a, 0.2)
+	S.AddChildW(b, 0.3)
+	S.AddChildW(c, 0.5)
+	if r := RemoveChild(S, 1); r != b {
+		t.Errorf("Expected removed child to be the second one.")
+	}
+	if ch, W := S.Ch(), S.Weights(); len(ch) != 2 || ch[1] != c || W[0] != 0.2 || W[1] != 0.5 {
+		t.Errorf("Expected children [a c] with weights [0.2 0.5], got %v.", W)
+	}
+	P := NewProduct()
+	P.AddChild(a)
+	P.AddChild(NewIndicator(1, 0))
+	RemoveChild(P, 0)
+	if sc := P.Sc(); len(sc) != 1 || sc[0] != 1 {
+		t.Errorf("Expected scope [1], got %v.", sc)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	S := sampleSPN()
+	Y11 := S.Ch()[0].Ch()[1].Ch()[0]
+	N := NewMultinomial(2, []float64{0.8, 0.2})
+	R := Replace(S, Y11, N)
+	if R != S {
+		t.Errorf("Expected root to be kept.")
+	}
+	if G := Index(S); G.Rank(Y11) != -1 || len(G.Parents(N)) != 2 {
+		t.Errorf("Expected every edge to the old node to be replaced.")
+	}
+	sameValues(t, sampleSPN(), S, allInstances())
+	// Wrapping a node must not create a cycle.
+	W := NewSum()
+	W.AddChildW(N, 1)
+	Replace(S, N, W)
+	if Validate(S).Has(Cycle) || W.Ch()[0] != N {
+		t.Errorf("Expected wrapped node to remain a child of its wrapper.")
+	}
+	if Replace(S, S, W) != W {
+		t.Errorf("Expected replacing the root to return the new root.")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	S := NewSum()
+	S.AddChildW(NewIndicator(0, 0), 0.05)
+	S.AddChildW(NewIndicator(0, 1), 0.55)
+	S.AddChildW(NewIndicator(0, 2), 0.4)
+	Prune(S, 0.1)
+	if W := S.Weights(); len(S.Ch()) != 2 || math.Abs(W[0]-0.55/0.95) > 1e-12 {
+		t.Errorf("Expected 2 renormalized children, got %v.", W)
+	}
+	Prune(S, 0.9)
+	if len(S.Ch()) != 1 || S.Weights()[0] != 1 {
+		t.Errorf("Expected only the heaviest child to be kept, got %v.", S.Weights())
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	// S -> {S1 -> {Y11, Y12}, P1 -> P2 -> {Y11', S2 -> Y12}}
+	Y11, Y12 := NewMultinomial(0, []float64{0.8, 0.2}), NewMultinomial(0, []float64{0.3, 0.7})
+	S, S1, S2 := NewSum(), NewSum(), NewSum()
+	P1, P2 := NewProduct(), NewProduct()
+	S.AddChildW(S1, 0.6)
+	S.AddChildW(P1, 0.4)
+	S1.AddChildW(Y11, 0.5)
+	S1.AddChildW(Y12, 0.5)
+	P1.AddChild(P2)
+	P2.AddChild(S2)
+	S2.AddChildW(Y12, 1)
+	D := []VarSet{{0: 0}, {0: 1}, {}}
+	V := make([]float64, len(D))
+	for i, I := range D {
+		V[i] = Inference(S, I)
+	}
+	R := Collapse(S)
+	for i, I := range D {
+		if v := Inference(R, I); math.Abs(v-V[i]) > 1e-9 {
+			t.Errorf("Expected %f, got %f.", V[i], v)
+		}
+	}
+	if ch, W := R.Ch(), R.(*Sum).Weights(); len(ch) != 2 || math.Abs(W[0]-0.3) > 1e-12 ||
+		math.Abs(W[1]-0.7) > 1e-12 {
+		t.Errorf("Expected a single sum over Y11 and Y12 with weights [0.3 0.7], got %v.", W)
+	}
+	if n := countNodes(R); n != 3 {
+		t.Errorf("Expected 3 nodes, got %d.", n)
+	}
+}
+
+func TestMergeIdentical(t *testing.T) {
+	build := func() SPN {
+		S := NewSum()
+		for i := 0; i < 2; i++ {
+			P := NewProduct()
+			P.AddChild(NewMultinomial(0, []float64{0.8, 0.2}))
+			P.AddChild(NewMultinomial(1, []float64{0.4, 0.6}))
+			S.AddChildW(P, 0.5)
+		}
+		return S
+	}
+	S := build()
+	R := MergeIdentical(S)
+	if n := countNodes(R); n != 4 {
+		t.Errorf("Expected 4 nodes, got %d.", n)
+	}
+	if ch := R.Ch(); ch[0] != ch[1] {
+		t.Errorf("Expected identical products to be merged.")
+	}
+	sameValues(t, build(), R, []VarSet{{0: 0, 1: 1}, {0: 1}, {}})
+	T := sampleSPN()
+	if n, m := countNodes(T), countNodes(MergeIdentical(T)); n != m {
+		t.Errorf("Expected no merges, got %d nodes from %d.", m, n)
+	}
+}
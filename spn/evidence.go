@@ -0,0 +1,173 @@
+package spn
+
+import (
+	"github.com/RenatoGeh/gospn/sys"
+	"github.com/RenatoGeh/gospn/utils"
+	"math"
+)
+
+// Interval is the evidence that a variable lies in the closed interval [Lo, Hi]. Bounds may be
+// infinite.
+type Interval struct {
+	Lo, Hi float64
+}
+
+// Evidence is a set of hard, soft and interval evidence over variables. Each variable should
+// appear in at most one of Hard, Soft and Intervals. If it appears in more than one, hard evidence
+// takes precedence over soft evidence, which takes precedence over intervals.
+type Evidence struct {
+	// Hard evidence X=x, just like a VarSet.
+	Hard VarSet
+	// Soft (likelihood) evidence: Soft[X][x] is the likelihood of the observation given X=x, e.g.
+	// the confusion of a noisy sensor. Values of X beyond the vector have likelihood zero.
+	Soft map[int][]float64
+	// Interval evidence Lo <= X <= Hi.
+	Intervals map[int]Interval
+}
+
+// LeafSoft is a univariate leaf that can be evaluated on soft evidence.
+type LeafSoft interface {
+	// Soft returns ln \sum_x Pr(X=x) * L[x], where L is a likelihood vector over the leaf's
+	// variable.
+	Soft(L []float64) float64
+}
+
+// LeafInterval is a univariate leaf that can be evaluated on interval evidence.
+type LeafInterval interface {
+	// Interval returns ln Pr(a <= X <= b).
+	Interval(a, b float64) float64
+}
+
+// LogLikelihood returns the log-likelihood of evidence E on variable v given v=x: 0 if v has no
+// evidence in E, or if x agrees with it, and -Inf if it does not. For soft evidence, it is the
+// log of the likelihood of x.
+func (E *Evidence) LogLikelihood(v, x int) float64 {
+	if u, ok := E.Hard[v]; ok {
+		if u == x {
+			return 0
+		}
+		return math.Inf(-1)
+	}
+	if L, ok := E.Soft[v]; ok {
+		if x < 0 || x >= len(L) {
+			return math.Inf(-1)
+		}
+		return math.Log(L[x])
+	}
+	if I, ok := E.Intervals[v]; ok {
+		if f := float64(x); f < I.Lo || f > I.Hi {
+			return math.Inf(-1)
+		}
+	}
+	return 0
+}
+
+// Observed returns whether variable v has any evidence in E.
+func (E *Evidence) Observed(v int) bool {
+	_, h := E.Hard[v]
+	_, s := E.Soft[v]
+	_, i := E.Intervals[v]
+	return h || s || i
+}
+
+// intervalSum returns ln Pr(a <= X <= b) for a discrete variable X with support {lo,...,hi} by
+// summing the probabilities logProb(x) of the integers in [a, b]. The clamped bounds must be
+// finite.
+func intervalSum(a, b, lo, hi float64, logProb func(int) float64) float64 {
+	a, b = math.Max(math.Ceil(a), lo), math.Min(math.Floor(b), hi)
+	if a > b {
+		return math.Inf(-1)
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		panic("spn: cannot sum a leaf over an unbounded interval; implement LeafInterval instead")
+	}
+	T := make([]float64, 0, int(b-a)+1)
+	for x := a; x <= b; x++ {
+		T = append(T, logProb(int(x)))
+	}
+	return utils.LogSumExp(T)
+}
+
+// valueE returns the value of leaf L given evidence E. Univariate leaves that implement neither
+// LeafSoft nor LeafInterval are summed over the values of their variable: over the indices of the
+// likelihood vector for soft evidence, and over the integers in the interval for interval
+// evidence. Since the support of such leaves is unknown, valueE panics on intervals with an
+// infinite bound over them. Soft and interval evidence over multivariate leaves is ignored (i.e.
+// the variable is taken as unset).
+func valueE(L SPN, E *Evidence) float64 {
+	sc := L.Sc()
+	if len(sc) != 1 {
+		H := make(VarSet)
+		for _, v := range sc {
+			if x, ok := E.Hard[v]; ok {
+				H[v] = x
+			}
+		}
+		return L.Value(H)
+	}
+	v := sc[0]
+	if x, ok := E.Hard[v]; ok {
+		return L.Value(VarSet{v: x})
+	}
+	if S, ok := E.Soft[v]; ok {
+		if f, ok := unlazy(L).(LeafSoft); ok {
+			return f.Soft(S)
+		}
+		if len(S) == 0 {
+			return math.Inf(-1)
+		}
+		T := make([]float64, len(S))
+		for x, l := range S {
+			T[x] = L.Value(VarSet{v: x}) + math.Log(l)
+		}
+		return utils.LogSumExp(T)
+	}
+	if I, ok := E.Intervals[v]; ok {
+		if f, ok := unlazy(L).(LeafInterval); ok {
+			return f.Interval(I.Lo, I.Hi)
+		}
+		return intervalSum(I.Lo, I.Hi, math.Inf(-1), math.Inf(1), func(x int) float64 {
+			return L.Value(VarSet{v: x})
+		})
+	}
+	return L.Value(VarSet{})
+}
+
+// ValuesE computes the value of every node given evidence E, storing node i's value in V[i]. If V
+// is shorter than Len(), a new slice is allocated. Returns V.
+func (p *Plan) ValuesE(E *Evidence, V []float64) []float64 {
+	return p.values(func(Z SPN) float64 { return valueE(Z, E) }, V)
+}
+
+// EvalE returns the value of the compiled SPN given evidence E.
+func (p *Plan) EvalE(E *Evidence) float64 {
+	b := p.pool.Get().(*[]float64)
+	V := p.ValuesE(E, *b)
+	v := V[p.Root()]
+	p.pool.Put(b)
+	return v
+}
+
+// InferenceE returns the value of S given soft and interval evidence E (see Inference). For a
+// complete and decomposable SPN, this is the probability of the evidence.
+func InferenceE(S SPN, E *Evidence) float64 {
+	return Compile(S).EvalE(E)
+}
+
+// StoreInferenceE is StoreInference for soft and interval evidence. Derivatives computed from the
+// stored values (e.g. learn.DeriveSPN) are thus derivatives given E.
+func StoreInferenceE(S SPN, E *Evidence, tk int, storage *Storer) (SPN, int) {
+	if tk < 0 {
+		tk = storage.NewTicket()
+	}
+
+	P := Compile(S)
+	V := P.ValuesE(E, nil)
+
+	table, _ := storage.Table(tk)
+	for i, v := range V {
+		table.StoreSingle(P.Node(i), v)
+	}
+	sys.Free()
+	return S, tk
+}
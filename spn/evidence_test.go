@@ -0,0 +1,137 @@
+package spn
+
+import (
+	"github.com/RenatoGeh/gospn/utils"
+	"math"
+	"testing"
+)
+
+func TestInferenceE(t *testing.T) {
+	S := sampleSPN()
+	// Hard evidence agrees with Inference.
+	for _, I := range append(allInstances(), VarSet{0: 1, 2: 0}) {
+		if u, v := Inference(S, I), InferenceE(S, &Evidence{Hard: I}); u != v {
+			t.Errorf("Expected %v, got %v for %v.", u, v, I)
+		}
+	}
+	// Soft evidence on X_0 and X_2 of a complete SPN is the likelihood-weighted sum of hard
+	// evidence.
+	C := NewSum()
+	for i, w := range []float64{0.3, 0.7} {
+		P := NewProduct()
+		P.AddChild(NewMultinomial(0, []float64{0.9 - 0.5*float64(i), 0.1 + 0.5*float64(i)}))
+		P.AddChild(NewMultinomial(1, []float64{0.6, 0.4}))
+		P.AddChild(NewMultinomial(2, []float64{0.2 + 0.6*float64(i), 0.8 - 0.6*float64(i)}))
+		C.AddChildW(P, w)
+	}
+	L0, L2 := []float64{0.2, 0.9}, []float64{0.5, 0.1}
+	var e float64
+	for x0 := 0; x0 < 2; x0++ {
+		for x2 := 0; x2 < 2; x2++ {
+			e += L0[x0] * L2[x2] * math.Exp(Inference(C, VarSet{0: x0, 1: 1, 2: x2}))
+		}
+	}
+	E := &Evidence{Hard: VarSet{1: 1}, Soft: map[int][]float64{0: L0, 2: L2}}
+	if v := InferenceE(C, E); math.Abs(v-math.Log(e)) > 1e-12 {
+		t.Errorf("Expected %v, got %v.", math.Log(e), v)
+	}
+	st := NewStorer()
+	_, tk := StoreInferenceE(C, E, -1, st)
+	if v, _ := st.Single(tk, C); math.Abs(v-math.Log(e)) > 1e-12 {
+		t.Errorf("Expected stored value %v, got %v.", math.Log(e), v)
+	}
+	// Interval evidence on a categorical variable.
+	F := &Evidence{Intervals: map[int]Interval{3: {0.5, math.Inf(1)}}}
+	if u, v := Inference(S, VarSet{3: 1}), InferenceE(S, F); math.Abs(u-v) > 1e-12 {
+		t.Errorf("Expected %v, got %v.", u, v)
+	}
+	if v := InferenceE(S, &Evidence{Intervals: map[int]Interval{3: {2, 3}}}); !math.IsInf(v, -1) {
+		t.Errorf("Expected empty interval to have zero probability, got %v.", v)
+	}
+	// A single leaf is evaluated as well.
+	L := NewMultinomial(0, []float64{0.2, 0.3, 0.5})
+	G := &Evidence{Soft: map[int][]float64{0: {1, 0.5, 0}}}
+	if v := InferenceE(L, G); math.Abs(v-math.Log(0.35)) > 1e-12 {
+		t.Errorf("Expected %v, got %v.", math.Log(0.35), v)
+	}
+	G = &Evidence{Intervals: map[int]Interval{0: {1, math.Inf(1)}}}
+	if _, tk = StoreInferenceE(L, G, -1, st); tk < 0 {
+		t.Errorf("Expected a valid ticket, got %d.", tk)
+	} else if v, _ := st.Single(tk, L); math.Abs(v-math.Log(0.8)) > 1e-12 {
+		t.Errorf("Expected stored value %v, got %v.", math.Log(0.8), v)
+	}
+}
+
+func TestLeavesE(t *testing.T) {
+	I := NewIndicator(0, 2)
+	v := valueE(I, &Evidence{Soft: map[int][]float64{0: {0.1, 0.2, 0.3}}})
+	if math.Abs(v-math.Log(0.3)) > 1e-15 {
+		t.Errorf("Expected indicator likelihood 0.3, got %v.", math.Exp(v))
+	}
+	if v := valueE(I, &Evidence{Intervals: map[int]Interval{0: {0, 1}}}); !math.IsInf(v, -1) {
+		t.Errorf("Expected indicator outside the interval to be 0, got %v.", math.Exp(v))
+	}
+	G := NewGaussianParams(0, 100, 20)
+	v, u := valueE(G, &Evidence{Intervals: map[int]Interval{0: {80, 120}}}), G.Interval(80, 120)
+	if v != u {
+		t.Errorf("Expected gaussian interval %v, got %v.", u, v)
+	}
+	// Fractional bounds select the integers in the interval.
+	P := NewPoisson(0, 3)
+	var p float64
+	for x := 2; x <= 5; x++ {
+		p += math.Exp(P.Value(VarSet{0: x}))
+	}
+	if v = valueE(P, &Evidence{Intervals: map[int]Interval{0: {1.5, 5}}}); math.Abs(v-math.Log(p)) > 1e-12 {
+		t.Errorf("Expected poisson interval %v, got %v.", math.Log(p), v)
+	}
+	// Soft evidence over {1,...,5}.
+	p += math.Exp(P.Value(VarSet{0: 1}))
+	v = valueE(P, &Evidence{Soft: map[int][]float64{0: {0, 1, 1, 1, 1, 1}}})
+	if math.Abs(v-math.Log(p)) > 1e-12 {
+		t.Errorf("Expected poisson soft evidence %v, got %v.", math.Log(p), v)
+	}
+}
+
+func TestLeavesInterval(t *testing.T) {
+	inf := math.Inf(1)
+	// Discrete leaves against brute force sums over their support.
+	D := []SPN{NewPoisson(0, 2), NewPoisson(0, 40), NewGeometric(0, 0.3), NewBinomial(0, 5, 0.5),
+		NewBernoulli(0, 0.8), NewPoisson(0, 0)}
+	for _, L := range D {
+		for _, I := range []Interval{{-inf, 3}, {3, inf}, {-inf, inf}, {1.5, 4}, {45, inf}, {6, 5}} {
+			var T []float64
+			for x := 0; x <= 400; x++ {
+				if l := float64(x); l >= I.Lo && l <= I.Hi {
+					T = append(T, L.Value(VarSet{0: x}))
+				}
+			}
+			u := math.Inf(-1)
+			if len(T) > 0 {
+				u = utils.LogSumExp(T)
+			}
+			v := valueE(L, &Evidence{Intervals: map[int]Interval{0: I}})
+			if math.IsInf(u, -1) != math.IsInf(v, -1) || (!math.IsInf(u, -1) && math.Abs(u-v) > 1e-9) {
+				t.Errorf("Expected %s interval %v to be %v, got %v.", L.SubType(), I, u, v)
+			}
+		}
+	}
+	for _, c := range []struct {
+		L    LeafInterval
+		I    Interval
+		want float64
+	}{
+		{NewPoisson(0, 2), Interval{-inf, 3}, 0.857123460498547},
+		{NewBinomial(0, 5, 0.5), Interval{3, inf}, 0.5},
+		{NewExponential(0, 1), Interval{1, inf}, math.Exp(-1)},
+		{NewGamma(0, 1, 2), Interval{-inf, 2}, 1 - math.Exp(-1)},
+		{NewGamma(0, 3, 1), Interval{10, inf}, math.Exp(-10) * (1 + 10 + 50)},
+		{NewLogNormal(0, 0, 1), Interval{0, 1}, 0.5},
+		{NewHistogram(0, []float64{0, 1, 3}, []float64{0.4, 0.6}), Interval{0.5, 2}, 0.2 + 0.3},
+		{NewPiecewiseLinear(0, []float64{0, 1, 2}, []float64{0, 1, 0}), Interval{-inf, 0.5}, 0.125},
+	} {
+		if v := math.Exp(c.L.Interval(c.I.Lo, c.I.Hi)); math.Abs(v-c.want) > 1e-12 {
+			t.Errorf("Expected %s interval %v to be %v, got %v.", c.L.(SPN).SubType(), c.I, c.want, v)
+		}
+	}
+}
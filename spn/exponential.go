@@ -0,0 +1,134 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Exponential represents an exponential distribution with rate lambda over a non-negative
+// continuous variable.
+type Exponential struct {
+	Node
+	// Variable ID
+	varid int
+	// Rate
+	lambda float64
+}
+
+// NewExponential constructs a new Exponential with rate lambda.
+func NewExponential(varid int, lambda float64) *Exponential {
+	return &Exponential{Node{sc: []int{varid}}, varid, lambda}
+}
+
+// NewExponentialML constructs a new Exponential from the maximum likelihood estimate of data X, a
+// slice of non-negative values.
+func NewExponentialML(varid int, X []float64) *Exponential {
+	return NewExponential(varid, 1/meanFloats(X))
+}
+
+// Type returns the type of this node.
+func (e *Exponential) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (e *Exponential) SubType() string { return "exponential" }
+
+// logProb returns the log-density at x.
+func (e *Exponential) logProb(x float64) float64 {
+	if x < 0 {
+		return math.Inf(-1)
+	}
+	return math.Log(e.lambda) - e.lambda*x
+}
+
+// Interval returns the probability Pr(a <= X <= b) = exp(-lambda*a) - exp(-lambda*b), in logspace.
+func (e *Exponential) Interval(a, b float64) float64 {
+	if a = math.Max(a, 0); a > b {
+		return math.Inf(-1)
+	}
+	if math.IsInf(b, 1) {
+		return -e.lambda * a
+	}
+	return -e.lambda*a + math.Log(-math.Expm1(-e.lambda*(b-a)))
+}
+
+// Value returns the density of a certain valuation. That is p(X=val[varid]), or 1 if the variable
+// is not set.
+func (e *Exponential) Value(val VarSet) float64 {
+	if v, ok := val[e.varid]; ok {
+		return e.logProb(float64(v))
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation. The mode of an exponential distribution is 0.
+func (e *Exponential) Max(val VarSet) float64 {
+	if v, ok := val[e.varid]; ok {
+		return e.logProb(float64(v))
+	}
+	return e.logProb(0)
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+func (e *Exponential) ArgMax(val VarSet) (VarSet, float64) {
+	v := val[e.varid]
+	return VarSet{e.varid: v}, e.logProb(float64(v))
+}
+
+// ValueF returns the density of a certain real-valued valuation.
+func (e *Exponential) ValueF(val VarSetF) float64 {
+	if v, ok := val[e.varid]; ok {
+		return e.logProb(v)
+	}
+	return 0
+}
+
+// MaxF returns the MAP value given a real-valued valuation.
+func (e *Exponential) MaxF(val VarSetF) float64 {
+	if v, ok := val[e.varid]; ok {
+		return e.logProb(v)
+	}
+	return e.logProb(0)
+}
+
+// ArgMaxF returns both the arguments and the value of the MAP state given a certain real-valued
+// valuation.
+func (e *Exponential) ArgMaxF(val VarSetF) (VarSetF, float64) {
+	v := val[e.varid]
+	return VarSetF{e.varid: v}, e.logProb(v)
+}
+
+// Sample draws a value from this distribution, rounded to the nearest integer, if the variable is
+// not set in val.
+func (e *Exponential) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[e.varid]; ok {
+		return
+	}
+	val[e.varid] = int(math.Round(rng.ExpFloat64() / e.lambda))
+}
+
+// Params returns the rate.
+func (e *Exponential) Params() float64 { return e.lambda }
+
+// Sc returns the scope of this node.
+func (e *Exponential) Sc() []int {
+	if len(e.sc) == 0 {
+		e.sc = []int{e.varid}
+	}
+	return e.sc
+}
+
+// GobEncode serializes this exponential node.
+func (e *Exponential) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, e.varid, e.lambda)
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this exponential node.
+func (e *Exponential) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &e.varid, &e.lambda)
+	e.sc = []int{e.varid}
+	return err
+}
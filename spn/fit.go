@@ -0,0 +1,57 @@
+package spn
+
+import (
+	"math"
+)
+
+// Helper functions for fitting leaf parameters to data.
+
+// meanInts returns the mean of X, or 0 if X is empty.
+func meanInts(X []int) float64 {
+	if len(X) == 0 {
+		return 0
+	}
+	var s float64
+	for _, x := range X {
+		s += float64(x)
+	}
+	return s / float64(len(X))
+}
+
+// meanFloats returns the mean of X, or 0 if X is empty.
+func meanFloats(X []float64) float64 {
+	if len(X) == 0 {
+		return 0
+	}
+	var s float64
+	for _, x := range X {
+		s += x
+	}
+	return s / float64(len(X))
+}
+
+// logFactorial returns ln(n!).
+func logFactorial(n int) float64 {
+	l, _ := math.Lgamma(float64(n) + 1)
+	return l
+}
+
+// digamma returns the digamma function (the derivative of ln(Gamma(x))) at x > 0.
+func digamma(x float64) float64 {
+	var r float64
+	for ; x < 6; x++ {
+		r -= 1 / x
+	}
+	f := 1 / (x * x)
+	return r + math.Log(x) - 0.5/x - f*(1.0/12-f*(1.0/120-f*(1.0/252-f*(1.0/240-f/132))))
+}
+
+// trigamma returns the trigamma function (the derivative of digamma) at x > 0.
+func trigamma(x float64) float64 {
+	var r float64
+	for ; x < 6; x++ {
+		r += 1 / (x * x)
+	}
+	f := 1 / (x * x)
+	return r + 1/x + f/2 + f/x*(1.0/6-f*(1.0/30-f*(1.0/42-f/30)))
+}
@@ -0,0 +1,211 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"gonum.org/v1/gonum/mathext"
+	"math"
+	"math/rand"
+)
+
+// Gamma represents a gamma distribution with shape k and scale theta over a positive continuous
+// variable.
+type Gamma struct {
+	Node
+	// Variable ID
+	varid int
+	// Shape
+	k float64
+	// Scale
+	theta float64
+}
+
+// NewGamma constructs a new Gamma with shape k and scale theta.
+func NewGamma(varid int, k, theta float64) *Gamma {
+	return &Gamma{Node{sc: []int{varid}}, varid, k, theta}
+}
+
+// NewGammaML constructs a new Gamma from the maximum likelihood estimate of data X, a slice of
+// positive values. The shape has no closed form estimate, and is found through Newton's method,
+// starting from Minka's approximation.
+func NewGammaML(varid int, X []float64) *Gamma {
+	m := meanFloats(X)
+	var l float64
+	for _, x := range X {
+		l += math.Log(x)
+	}
+	s := math.Log(m) - l/float64(len(X))
+	if s <= 0 || math.IsNaN(s) || math.IsInf(s, 0) {
+		// All values are equal (or invalid): any shape fits; fall back to a peaked gamma.
+		return NewGamma(varid, 1e6, m/1e6)
+	}
+	k := (3 - s + math.Sqrt((s-3)*(s-3)+24*s)) / (12 * s)
+	for i := 0; i < 100; i++ {
+		d := (math.Log(k) - digamma(k) - s) / (1/k - trigamma(k))
+		k -= d
+		if math.Abs(d) < 1e-12*k {
+			break
+		}
+	}
+	return NewGamma(varid, k, m/k)
+}
+
+// Type returns the type of this node.
+func (g *Gamma) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (g *Gamma) SubType() string { return "gamma" }
+
+// logProb returns the log-density at x.
+func (g *Gamma) logProb(x float64) float64 {
+	if x < 0 || (x == 0 && g.k > 1) {
+		return math.Inf(-1)
+	}
+	if x == 0 && g.k == 1 {
+		return -math.Log(g.theta)
+	}
+	lg, _ := math.Lgamma(g.k)
+	return (g.k-1)*math.Log(x) - x/g.theta - lg - g.k*math.Log(g.theta)
+}
+
+// Interval returns the probability Pr(a <= X <= b), in logspace. The cumulative distribution is
+// the regularized lower incomplete gamma function, and its complement is used above the mean so
+// that upper tails do not vanish to zero.
+func (g *Gamma) Interval(a, b float64) float64 {
+	a, b = math.Max(a, 0)/g.theta, b/g.theta
+	if a > b {
+		return math.Inf(-1)
+	}
+	if a > g.k {
+		u := mathext.GammaIncComp(g.k, a)
+		if !math.IsInf(b, 1) {
+			u -= mathext.GammaIncComp(g.k, b)
+		}
+		return math.Log(u)
+	}
+	u := 1.0
+	if !math.IsInf(b, 1) {
+		u = mathext.GammaInc(g.k, b)
+	}
+	if a > 0 {
+		u -= mathext.GammaInc(g.k, a)
+	}
+	return math.Log(u)
+}
+
+// mode returns the point of highest density. If k < 1, the density is unbounded at the mode 0.
+func (g *Gamma) mode() float64 {
+	if g.k < 1 {
+		return 0
+	}
+	return (g.k - 1) * g.theta
+}
+
+// Value returns the density of a certain valuation. That is p(X=val[varid]), or 1 if the variable
+// is not set.
+func (g *Gamma) Value(val VarSet) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(float64(v))
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (g *Gamma) Max(val VarSet) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(float64(v))
+	}
+	return g.logProb(g.mode())
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation. The
+// mode is rounded to the nearest integer, but the returned value is the density at the mode.
+func (g *Gamma) ArgMax(val VarSet) (VarSet, float64) {
+	if v, ok := val[g.varid]; ok {
+		return VarSet{g.varid: v}, g.logProb(float64(v))
+	}
+	m := g.mode()
+	return VarSet{g.varid: int(math.Round(m))}, g.logProb(m)
+}
+
+// ValueF returns the density of a certain real-valued valuation.
+func (g *Gamma) ValueF(val VarSetF) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
+	}
+	return 0
+}
+
+// MaxF returns the MAP value given a real-valued valuation.
+func (g *Gamma) MaxF(val VarSetF) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
+	}
+	return g.logProb(g.mode())
+}
+
+// ArgMaxF returns both the arguments and the value of the MAP state given a certain real-valued
+// valuation.
+func (g *Gamma) ArgMaxF(val VarSetF) (VarSetF, float64) {
+	v, ok := val[g.varid]
+	if !ok {
+		v = g.mode()
+	}
+	return VarSetF{g.varid: v}, g.logProb(v)
+}
+
+// Sample draws a value from this distribution, rounded to the nearest integer, if the variable is
+// not set in val.
+func (g *Gamma) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[g.varid]; ok {
+		return
+	}
+	val[g.varid] = int(math.Round(sampleGamma(g.k, rng) * g.theta))
+}
+
+// sampleGamma draws a value from a gamma distribution with shape k and unit scale through
+// Marsaglia and Tsang's method.
+func sampleGamma(k float64, rng *rand.Rand) float64 {
+	if k < 1 {
+		return sampleGamma(k+1, rng) * math.Pow(rng.Float64(), 1/k)
+	}
+	d := k - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < x*x/2+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// Params returns the shape and scale.
+func (g *Gamma) Params() (float64, float64) { return g.k, g.theta }
+
+// Sc returns the scope of this node.
+func (g *Gamma) Sc() []int {
+	if len(g.sc) == 0 {
+		g.sc = []int{g.varid}
+	}
+	return g.sc
+}
+
+// GobEncode serializes this gamma node.
+func (g *Gamma) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, g.varid, g.k, g.theta)
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this gamma node.
+func (g *Gamma) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &g.varid, &g.k, &g.theta)
+	g.sc = []int{g.varid}
+	return err
+}
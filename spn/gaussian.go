@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"gonum.org/v1/gonum/stat/distuv"
 	"math"
+	"math/rand"
 )
 
 const (
@@ -22,9 +23,22 @@ type Gaussian struct {
 	dist distuv.Normal
 }
 
-// NewGaussianParams constructs a new Gaussian from a mean and variance.
+// MinGaussianVariance is the variance floor of gaussians estimated from data, i.e. those built by
+// NewGaussianRaw, NewGaussian, NewGaussianMode and NewGaussianFit. A positive floor prevents
+// degenerate gaussians on constant variables. Parameters given to NewGaussianParams are taken as
+// is.
+var MinGaussianVariance = 0.0
+
+// NewGaussianParams constructs a new Gaussian from a mean and standard deviation. A gaussian of
+// zero standard deviation is a point mass on its mean.
 func NewGaussianParams(varid int, mu float64, sigma float64) *Gaussian {
-	return &Gaussian{Node{nil, []int{varid}}, varid, distuv.Normal{mu, sigma, nil}}
+	return &Gaussian{Node{sc: []int{varid}}, varid, distuv.Normal{Mu: mu, Sigma: sigma}}
+}
+
+// newGaussianFloor constructs a new Gaussian with standard deviation at least
+// sqrt(MinGaussianVariance).
+func newGaussianFloor(varid int, mu, sigma float64) *Gaussian {
+	return NewGaussianParams(varid, mu, math.Max(sigma, math.Sqrt(MinGaussianVariance)))
 }
 
 // NewGaussianRaw constructs a new Gaussian from a slice of values.
@@ -44,11 +58,11 @@ func NewGaussianRaw(varid int, vals []float64) *Gaussian {
 
 	sd = math.Sqrt(sd / float64(n))
 
-	return &Gaussian{Node{sc: []int{varid}}, varid, distuv.Normal{mean, sd, nil}}
+	return newGaussianFloor(varid, mean, sd)
 }
 
-// NewGaussian constructs a new Gaussian from a counting slice.
-func NewGaussian(varid int, counts []int) *Gaussian {
+// countMoments returns the mean and standard deviation of a counting slice.
+func countMoments(counts []int) (float64, float64) {
 	var mean, sd float64
 	var N int
 	n := len(counts)
@@ -65,39 +79,26 @@ func NewGaussian(varid int, counts []int) *Gaussian {
 		d := float64(i) - mean
 		sd += (float64(counts[i]) / float64(N)) * d * d
 	}
-	sd = math.Sqrt(sd)
+	return mean, math.Sqrt(sd)
+}
 
-	//sys.Printf("Created new gaussian with Mu: %f and StdDev: %f\n", mean, sd)
-	return &Gaussian{Node{sc: []int{varid}}, varid, distuv.Normal{mean, sd, nil}}
+// NewGaussian constructs a new Gaussian from a counting slice.
+func NewGaussian(varid int, counts []int) *Gaussian {
+	mean, sd := countMoments(counts)
+	return newGaussianFloor(varid, mean, sd)
 }
 
-// NewGaussianMode constructs a new Gaussian centered on the Mode instead of the Mean.
+// NewGaussianMode constructs a new Gaussian centered on the Mode instead of the Mean. The standard
+// deviation is still computed around the mean.
 func NewGaussianMode(varid int, counts []int) *Gaussian {
-	var mean, mode, sd float64
-	var N int
-	n := len(counts)
-
-	for i := range counts {
-		N += counts[i]
-	}
-
-	for i := 0; i < n; i++ {
-		mean += float64(counts[i]) / float64(N) * float64(i)
-	}
-
-	max := math.Inf(-1)
-	for i := 0; i < n; i++ {
-		c := float64(counts[i])
-		if c > max {
-			max = c
+	_, sd := countMoments(counts)
+	var mode int
+	for i, c := range counts {
+		if c > counts[mode] {
+			mode = i
 		}
-		d := float64(i) - mean
-		sd += (c / float64(N)) * d * d
 	}
-	sd = math.Sqrt(sd)
-
-	//sys.Printf("Created new gaussian with Mu: %f and StdDev: %f\n", mean, sd)
-	return &Gaussian{Node{sc: []int{varid}}, varid, distuv.Normal{mode, sd, nil}}
+	return newGaussianFloor(varid, float64(mode), sd)
 }
 
 // NewGaussianFit constructs a new Gaussian from GoNum's Fit function.
@@ -108,62 +109,112 @@ func NewGaussianFit(varid int, counts []float64) *Gaussian {
 		sample[i] = float64(i)
 	}
 	N.Fit(sample, counts)
-	return &Gaussian{Node{sc: []int{varid}}, varid, N}
+	return newGaussianFloor(varid, N.Mu, N.Sigma)
 }
 
 // Type returns the type of this node.
 func (g *Gaussian) Type() string { return "leaf" }
 
-func zeroSigma(v int, ok bool, mu float64) float64 {
-	if ok && v == int(mu) {
-		return 0
+// logProb returns the log-density at x. If sigma is zero, the gaussian is a point mass on its
+// mean, and so its value is 1 at the mean and 0 elsewhere.
+func (g *Gaussian) logProb(x float64) float64 {
+	if g.dist.Sigma == 0 {
+		if x == g.dist.Mu {
+			return 0
+		}
+		return math.Inf(-1)
 	}
-	return math.Inf(-1)
+	return g.dist.LogProb(x)
 }
 
-// Value returns the probability of a certain valuation. That is Pr(X=val[varid]), where
-// Pr is a probability function over a gaussian distribution.
+// Value returns the density of a certain valuation. That is p(X=val[varid]), or 1 if the variable
+// is not set, i.e. X is marginalized out.
 func (g *Gaussian) Value(val VarSet) float64 {
-	v, ok := val[g.varid]
-	var l float64
-	if g.dist.Sigma == 0 {
-		return zeroSigma(v, ok, g.dist.Mu)
-	} else if ok {
-		l = g.dist.LogProb(float64(v))
-	} else {
-		l = 0.0 // ln(1.0) = 0.0
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(float64(v))
 	}
-	//sys.Printf("Gaussian value (mu=%f, sigma=%f) for value %d (pixel %d): %f = ln(%f)\n", g.dist.Mu, g.dist.Sigma, v, g.varid, l, math.Exp(l))
-	return l
+	return 0
 }
 
 // Max returns the MAP given a valuation.
 func (g *Gaussian) Max(val VarSet) float64 {
-	v, ok := val[g.varid]
-	if g.dist.Sigma == 0 {
-		return zeroSigma(v, ok, g.dist.Mu)
-	} else if ok {
-		return g.dist.LogProb(float64(v))
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(float64(v))
 	}
-	return g.dist.LogProb(g.dist.Mu)
+	return g.logProb(g.dist.Mu)
 }
 
-// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation. The
+// mean is rounded to the nearest integer, but the returned value is the density at the mean.
 func (g *Gaussian) ArgMax(val VarSet) (VarSet, float64) {
-	retval := make(VarSet)
-	v, ok := val[g.varid]
+	if v, ok := val[g.varid]; ok {
+		return VarSet{g.varid: v}, g.logProb(float64(v))
+	}
+	return VarSet{g.varid: int(math.Round(g.dist.Mu))}, g.logProb(g.dist.Mu)
+}
 
-	if g.dist.Sigma == 0 {
-		retval[g.varid] = int(g.dist.Mu)
-		return retval, 0.0
-	} else if ok {
-		retval[g.varid] = v
-		z := g.dist.LogProb(float64(v))
-		return retval, z
+// ValueF returns the log-density of the gaussian at val[varid], or 0 if the variable is not set.
+func (g *Gaussian) ValueF(val VarSetF) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
 	}
+	return 0
+}
+
+// MaxF returns the MAP given a real-valued valuation.
+func (g *Gaussian) MaxF(val VarSetF) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
+	}
+	return g.logProb(g.dist.Mu)
+}
 
-	retval[g.varid] = int(math.Round(g.dist.Mu))
-	return retval, g.dist.LogProb(g.dist.Mu)
+// ArgMaxF returns both the arguments and the value of the MAP state given a certain real-valued
+// valuation. Unlike ArgMax, the mean is not rounded.
+func (g *Gaussian) ArgMaxF(val VarSetF) (VarSetF, float64) {
+	if v, ok := val[g.varid]; ok {
+		return VarSetF{g.varid: v}, g.logProb(v)
+	}
+	return VarSetF{g.varid: g.dist.Mu}, g.logProb(g.dist.Mu)
+}
+
+// Interval returns the probability Pr(a <= X <= b), in logspace.
+func (g *Gaussian) Interval(a, b float64) float64 {
+	return normalMass(g.dist.Mu, g.dist.Sigma, a, b)
+}
+
+// normalMass returns ln(Pr(a <= X <= b)) for a gaussian X of mean mu and standard deviation
+// sigma. Tails are computed through the complementary error function, so that probabilities far
+// from the mean do not vanish to zero.
+func normalMass(mu, sigma, a, b float64) float64 {
+	if a > b {
+		return math.Inf(-1)
+	}
+	if sigma == 0 {
+		if a <= mu && mu <= b {
+			return 0
+		}
+		return math.Inf(-1)
+	}
+	za, zb := (a-mu)/(sigma*math.Sqrt2), (b-mu)/(sigma*math.Sqrt2)
+	if za > 0 {
+		// Both bounds are on the upper tail: Pr = (erfc(za) - erfc(zb))/2.
+		return math.Log(math.Erfc(za)-math.Erfc(zb)) - math.Ln2
+	}
+	if zb < 0 {
+		// Both bounds are on the lower tail: Pr = (erfc(-zb) - erfc(-za))/2.
+		return math.Log(math.Erfc(-zb)-math.Erfc(-za)) - math.Ln2
+	}
+	return math.Log(math.Erf(zb)-math.Erf(za)) - math.Ln2
+}
+
+// Sample draws a value from this distribution, rounded to the nearest integer, if the variable is
+// not set in val.
+func (g *Gaussian) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[g.varid]; ok {
+		return
+	}
+	val[g.varid] = int(math.Round(rng.NormFloat64()*g.dist.Sigma + g.dist.Mu))
 }
 
 // Params returns mean and standard deviation.
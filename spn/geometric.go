@@ -0,0 +1,122 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Geometric represents a geometric distribution over the number of failures before the first
+// success of independent trials with probability of success p. Its support is 0, 1, 2, ...
+type Geometric struct {
+	Node
+	// Variable ID
+	varid int
+	// Probability of success
+	p float64
+}
+
+// NewGeometric constructs a new Geometric with probability of success p.
+func NewGeometric(varid int, p float64) *Geometric {
+	return &Geometric{Node{sc: []int{varid}}, varid, p}
+}
+
+// NewGeometricML constructs a new Geometric from the maximum likelihood estimate of data X, a
+// slice of non-negative counts.
+func NewGeometricML(varid int, X []int) *Geometric {
+	return NewGeometric(varid, 1/(1+meanInts(X)))
+}
+
+// Type returns the type of this node.
+func (g *Geometric) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (g *Geometric) SubType() string { return "geometric" }
+
+// logProb returns ln(Pr(X=x)).
+func (g *Geometric) logProb(x int) float64 {
+	if x < 0 {
+		return math.Inf(-1)
+	}
+	if x == 0 {
+		return math.Log(g.p)
+	}
+	return float64(x)*math.Log(1-g.p) + math.Log(g.p)
+}
+
+// Interval returns the probability Pr(a <= X <= b) = (1-p)^a - (1-p)^(b+1), in logspace.
+func (g *Geometric) Interval(a, b float64) float64 {
+	a, b = math.Max(math.Ceil(a), 0), math.Floor(b)
+	if a > b {
+		return math.Inf(-1)
+	}
+	var l, q float64
+	if q = math.Log1p(-g.p); a > 0 {
+		l = a * q
+	}
+	if !math.IsInf(b, 1) {
+		l += math.Log(-math.Expm1((b - a + 1) * q))
+	}
+	return l
+}
+
+// Value returns the probability of a certain valuation. That is Pr(X=val[varid]), or 1 if the
+// variable is not set.
+func (g *Geometric) Value(val VarSet) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation. The mode of a geometric distribution is always 0.
+func (g *Geometric) Max(val VarSet) float64 {
+	if v, ok := val[g.varid]; ok {
+		return g.logProb(v)
+	}
+	return g.logProb(0)
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+func (g *Geometric) ArgMax(val VarSet) (VarSet, float64) {
+	v := val[g.varid]
+	return VarSet{g.varid: v}, g.logProb(v)
+}
+
+// Sample draws a value from this distribution if the variable is not set in val.
+func (g *Geometric) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[g.varid]; ok {
+		return
+	}
+	if g.p >= 1 {
+		val[g.varid] = 0
+		return
+	}
+	val[g.varid] = int(math.Floor(math.Log(1-rng.Float64()) / math.Log(1-g.p)))
+}
+
+// Params returns the probability of success.
+func (g *Geometric) Params() float64 { return g.p }
+
+// Sc returns the scope of this node.
+func (g *Geometric) Sc() []int {
+	if len(g.sc) == 0 {
+		g.sc = []int{g.varid}
+	}
+	return g.sc
+}
+
+// GobEncode serializes this geometric node.
+func (g *Geometric) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, g.varid, g.p)
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this geometric node.
+func (g *Geometric) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &g.varid, &g.p)
+	g.sc = []int{g.varid}
+	return err
+}
@@ -0,0 +1,305 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Histogram represents a piecewise-constant density over a continuous variable. The support is
+// partitioned into bins [e_i, e_{i+1}) given by increasing edges e_0 < e_1 < ... < e_m, the last
+// bin also containing e_m. Each bin i holds probability mass w_i, spread uniformly over the bin.
+// Values outside [e_0, e_m] have zero density.
+type Histogram struct {
+	Node
+	// Variable ID
+	varid int
+	// Bin edges
+	edges []float64
+	// Bin masses
+	w []float64
+	// Index of the bin with highest density
+	mode int
+}
+
+// NewHistogram constructs a new Histogram with bin edges edges and bin masses w, normalized to sum
+// to one. If w has m elements, edges must have m+1.
+func NewHistogram(varid int, edges, w []float64) *Histogram {
+	h := &Histogram{Node{sc: []int{varid}}, varid, edges, nil, 0}
+	h.SetMasses(w)
+	return h
+}
+
+// NewHistogramML constructs a new Histogram with bin edges edges from the counts of data X. Bin
+// masses are Laplace smoothed, just like in NewCountingMultinomial, so that no bin is left empty.
+// Values outside the edges are ignored.
+func NewHistogramML(varid int, edges, X []float64) *Histogram {
+	w := make([]float64, len(edges)-1)
+	for i := range w {
+		w[i] = 1
+	}
+	h := &Histogram{Node{sc: []int{varid}}, varid, edges, w, 0}
+	for _, x := range X {
+		if i := h.Bin(x); i >= 0 {
+			w[i]++
+		}
+	}
+	h.SetMasses(w)
+	return h
+}
+
+// UniformEdges returns the m+1 edges of m bins of equal width over [lo, hi].
+func UniformEdges(lo, hi float64, m int) []float64 {
+	E := make([]float64, m+1)
+	for i := range E {
+		E[i] = lo + (hi-lo)*float64(i)/float64(m)
+	}
+	E[m] = hi
+	return E
+}
+
+// QuantileEdges returns the edges of at most m bins over the range of data X, each holding roughly
+// the same number of values. Repeated edges (e.g. from heavily repeated values) are merged.
+func QuantileEdges(X []float64, m int) []float64 {
+	S := append([]float64(nil), X...)
+	sort.Float64s(S)
+	n := len(S)
+	E := []float64{S[0]}
+	for i := 1; i <= m; i++ {
+		e := S[(n-1)*i/m]
+		if e > E[len(E)-1] {
+			E = append(E, e)
+		}
+	}
+	if len(E) == 1 {
+		// All values are equal: use a single unit-width bin centered on them.
+		return []float64{S[0] - 0.5, S[0] + 0.5}
+	}
+	return E
+}
+
+// Type returns the type of this node.
+func (h *Histogram) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (h *Histogram) SubType() string { return "histogram" }
+
+// Bin returns the index of the bin containing x, or -1 if x is outside the histogram.
+func (h *Histogram) Bin(x float64) int {
+	m := len(h.w)
+	if x < h.edges[0] || x > h.edges[m] || math.IsNaN(x) {
+		return -1
+	}
+	if x == h.edges[m] {
+		return m - 1
+	}
+	return sort.Search(m, func(i int) bool { return h.edges[i+1] > x })
+}
+
+// width returns the width of the i-th bin.
+func (h *Histogram) width(i int) float64 { return h.edges[i+1] - h.edges[i] }
+
+// density returns the log-density of the i-th bin.
+func (h *Histogram) density(i int) float64 { return math.Log(h.w[i]) - math.Log(h.width(i)) }
+
+// logProb returns the log-density at x.
+func (h *Histogram) logProb(x float64) float64 {
+	i := h.Bin(x)
+	if i < 0 {
+		return math.Inf(-1)
+	}
+	return h.density(i)
+}
+
+// Interval returns the probability Pr(a <= X <= b), in logspace. Each bin contributes its mass in
+// proportion to its overlap with [a, b].
+func (h *Histogram) Interval(a, b float64) float64 {
+	var s float64
+	for i, w := range h.w {
+		if l, r := math.Max(a, h.edges[i]), math.Min(b, h.edges[i+1]); r > l {
+			s += w * (r - l) / h.width(i)
+		}
+	}
+	return math.Log(s)
+}
+
+// Value returns the density of a certain valuation. That is p(X=val[varid]), or 1 if the variable
+// is not set.
+func (h *Histogram) Value(val VarSet) float64 {
+	if v, ok := val[h.varid]; ok {
+		return h.logProb(float64(v))
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (h *Histogram) Max(val VarSet) float64 {
+	if v, ok := val[h.varid]; ok {
+		return h.logProb(float64(v))
+	}
+	return h.density(h.mode)
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation. The
+// MAP state is the smallest integer in the bin with highest density, or its rounded center if the
+// bin contains no integer.
+func (h *Histogram) ArgMax(val VarSet) (VarSet, float64) {
+	if v, ok := val[h.varid]; ok {
+		return VarSet{h.varid: v}, h.logProb(float64(v))
+	}
+	x := math.Ceil(h.edges[h.mode])
+	if h.Bin(x) != h.mode {
+		x = math.Round((h.edges[h.mode] + h.edges[h.mode+1]) / 2)
+	}
+	return VarSet{h.varid: int(x)}, h.density(h.mode)
+}
+
+// ValueF returns the density of a certain real-valued valuation.
+func (h *Histogram) ValueF(val VarSetF) float64 {
+	if v, ok := val[h.varid]; ok {
+		return h.logProb(v)
+	}
+	return 0
+}
+
+// MaxF returns the MAP value given a real-valued valuation.
+func (h *Histogram) MaxF(val VarSetF) float64 {
+	if v, ok := val[h.varid]; ok {
+		return h.logProb(v)
+	}
+	return h.density(h.mode)
+}
+
+// ArgMaxF returns both the arguments and the value of the MAP state given a certain real-valued
+// valuation. The MAP state is the center of the bin with highest density.
+func (h *Histogram) ArgMaxF(val VarSetF) (VarSetF, float64) {
+	if v, ok := val[h.varid]; ok {
+		return VarSetF{h.varid: v}, h.logProb(v)
+	}
+	return VarSetF{h.varid: (h.edges[h.mode] + h.edges[h.mode+1]) / 2}, h.density(h.mode)
+}
+
+// Sample draws a value from this distribution, rounded to the nearest integer, if the variable is
+// not set in val.
+func (h *Histogram) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[h.varid]; ok {
+		return
+	}
+	i := sampleIndex(h.w, rng)
+	val[h.varid] = int(math.Round(h.edges[i] + rng.Float64()*h.width(i)))
+}
+
+// sampleIndex draws an index of W, where each index i has probability W[i].
+func sampleIndex(W []float64, rng *rand.Rand) int {
+	r := rng.Float64()
+	for i, w := range W {
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	return len(W) - 1
+}
+
+// Deriv returns the derivatives of this leaf's density with respect to each bin mass w_i given a
+// real-valued valuation, in logspace. The derivative is 1/(e_{i+1}-e_i) for the bin containing the
+// variable's value and 0 for all others. If the variable is not set, the leaf's value is the sum
+// of all masses, and so every derivative is 1. Combined with dS/dL (see learn.DeriveSPN), these
+// give the expected bin counts used by EM (see learn.HistogramEM).
+func (h *Histogram) Deriv(val VarSetF) []float64 {
+	D := make([]float64, len(h.w))
+	v, ok := val[h.varid]
+	if !ok {
+		return D
+	}
+	j := h.Bin(v)
+	for i := range D {
+		if i == j {
+			D[i] = -math.Log(h.width(i))
+		} else {
+			D[i] = math.Inf(-1)
+		}
+	}
+	return D
+}
+
+// Edges returns the bin edges.
+func (h *Histogram) Edges() []float64 { return h.edges }
+
+// Params returns the bin masses.
+func (h *Histogram) Params() []float64 { return h.w }
+
+// SetMasses sets the bin masses to W, normalized to sum to one. If W sums to zero, all bins are
+// given the same mass.
+func (h *Histogram) SetMasses(W []float64) {
+	var s float64
+	for _, w := range W {
+		s += w
+	}
+	h.w = make([]float64, len(W))
+	for i, w := range W {
+		if s > 0 {
+			h.w[i] = w / s
+		} else {
+			h.w[i] = 1 / float64(len(W))
+		}
+	}
+	h.computeMode()
+}
+
+// computeMode finds the bin with highest density.
+func (h *Histogram) computeMode() {
+	h.mode = 0
+	for i := range h.w {
+		if h.density(i) > h.density(h.mode) {
+			h.mode = i
+		}
+	}
+}
+
+// Sc returns the scope of this node.
+func (h *Histogram) Sc() []int {
+	if len(h.sc) == 0 {
+		h.sc = []int{h.varid}
+	}
+	return h.sc
+}
+
+// GobEncode serializes this histogram node.
+func (h *Histogram) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d %d", h.varid, len(h.w))
+	for _, e := range h.edges {
+		fmt.Fprintf(&b, " %v", e)
+	}
+	for _, w := range h.w {
+		fmt.Fprintf(&b, " %v", w)
+	}
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this histogram node.
+func (h *Histogram) GobDecode(data []byte) error {
+	b := bytes.NewBuffer(data)
+	var n int
+	_, err := fmt.Fscanf(b, "%d %d", &h.varid, &n)
+	if err != nil {
+		return err
+	}
+	h.sc = []int{h.varid}
+	h.edges, h.w = make([]float64, n+1), make([]float64, n)
+	for i := range h.edges {
+		if _, err = fmt.Fscanf(b, "%f", &h.edges[i]); err != nil {
+			return err
+		}
+	}
+	for i := range h.w {
+		if _, err = fmt.Fscanf(b, "%f", &h.w[i]); err != nil {
+			return err
+		}
+	}
+	h.computeMode()
+	return nil
+}
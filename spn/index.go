@@ -0,0 +1,94 @@
+package spn
+
+// GraphIndex is a snapshot of the structure of an SPN, indexed by node. It holds the parents,
+// depth and topological rank of every node, and a lookup from node IDs to nodes. Just like Plan,
+// a GraphIndex must be rebuilt after any structural change to the SPN.
+type GraphIndex struct {
+	// Nodes in topological order (parents before children, root first).
+	nodes []SPN
+	// Node -> topological rank.
+	rank map[SPN]int
+	// Node -> parents, in the order they were found.
+	pa map[SPN][]SPN
+	// Node -> depth.
+	depth map[SPN]int
+	// ID -> node.
+	ids map[int]SPN
+}
+
+// Index builds a GraphIndex of SPN S.
+func Index(S SPN) *GraphIndex {
+	G := &GraphIndex{
+		rank:  make(map[SPN]int),
+		pa:    make(map[SPN][]SPN),
+		depth: make(map[SPN]int),
+		ids:   make(map[int]SPN),
+	}
+	var O []SPN
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		O = append(O, Z)
+		return true
+	})
+	n := len(O)
+	G.nodes = make([]SPN, n)
+	for i, Z := range O {
+		G.nodes[n-i-1] = Z
+		G.rank[Z] = n - i - 1
+		G.ids[Z.ID()] = Z
+	}
+	for _, Z := range G.nodes {
+		for _, c := range Z.Ch() {
+			G.pa[c] = append(G.pa[c], Z)
+		}
+	}
+	// Depth is the length of the shortest path from the root, computed through BFS.
+	G.depth[S] = 0
+	Q := []SPN{S}
+	for len(Q) > 0 {
+		s := Q[0]
+		Q = Q[1:]
+		for _, c := range s.Ch() {
+			if _, e := G.depth[c]; !e {
+				G.depth[c] = G.depth[s] + 1
+				Q = append(Q, c)
+			}
+		}
+	}
+	return G
+}
+
+// Len returns the number of nodes in the index.
+func (G *GraphIndex) Len() int { return len(G.nodes) }
+
+// Root returns the root of the indexed SPN.
+func (G *GraphIndex) Root() SPN { return G.nodes[0] }
+
+// Nodes returns every node in topological order (parents before children). The root comes first.
+func (G *GraphIndex) Nodes() []SPN { return G.nodes }
+
+// Node returns the node with the given ID and whether it is part of the indexed SPN.
+func (G *GraphIndex) Node(id int) (SPN, bool) {
+	S, e := G.ids[id]
+	return S, e
+}
+
+// Parents returns the parents of node S.
+func (G *GraphIndex) Parents(S SPN) []SPN { return G.pa[S] }
+
+// Depth returns the length of the shortest path from the root to node S, or -1 if S is not part
+// of the indexed SPN.
+func (G *GraphIndex) Depth(S SPN) int {
+	if d, e := G.depth[S]; e {
+		return d
+	}
+	return -1
+}
+
+// Rank returns the topological rank of node S, that is its position in Nodes, or -1 if S is not
+// part of the indexed SPN. Any node has a smaller rank than its children.
+func (G *GraphIndex) Rank(S SPN) int {
+	if r, e := G.rank[S]; e {
+		return r
+	}
+	return -1
+}
@@ -0,0 +1,79 @@
+package spn
+
+import (
+	"testing"
+)
+
+func TestIndex(t *testing.T) {
+	S := sampleSPN()
+	G := Index(S)
+	if G.Len() != 13 {
+		t.Errorf("Expected 13 nodes, got %d.", G.Len())
+	}
+	if G.Root() != S || G.Rank(S) != 0 || G.Depth(S) != 0 {
+		t.Errorf("Expected root to come first with depth 0.")
+	}
+	seen := make(map[int]bool)
+	for _, Z := range G.Nodes() {
+		if seen[Z.ID()] {
+			t.Errorf("Expected unique IDs, got %d twice.", Z.ID())
+		}
+		seen[Z.ID()] = true
+		if N, e := G.Node(Z.ID()); !e || N != Z {
+			t.Errorf("Expected ID lookup of %d to return its node.", Z.ID())
+		}
+		for _, c := range Z.Ch() {
+			if G.Rank(c) <= G.Rank(Z) {
+				t.Errorf("Expected child rank %d > parent rank %d.", G.Rank(c), G.Rank(Z))
+			}
+			if G.Depth(c) > G.Depth(Z)+1 {
+				t.Errorf("Expected child depth %d <= %d.", G.Depth(c), G.Depth(Z)+1)
+			}
+			var p bool
+			for _, P := range G.Parents(c) {
+				p = p || P == Z
+			}
+			if !p {
+				t.Errorf("Expected node %d to be a parent of %d.", Z.ID(), c.ID())
+			}
+		}
+	}
+	// Shared leaves: Y11 is a child of both S1 and S2.
+	Y11 := S.Ch()[0].Ch()[1].Ch()[0]
+	if n, d := len(G.Parents(Y11)), G.Depth(Y11); n != 2 || d != 3 {
+		t.Errorf("Expected 2 parents and depth 3, got %d and %d.", n, d)
+	}
+	if G.Rank(NewSum()) != -1 || G.Depth(NewSum()) != -1 {
+		t.Errorf("Expected -1 for nodes outside the index.")
+	}
+}
+
+func TestMarshalIDs(t *testing.T) {
+	S := sampleSPN()
+	G := Index(S)
+	T := Unmarshal(Marshal(S))
+	H := Index(T)
+	if G.Len() != H.Len() {
+		t.Fatalf("Expected %d nodes, got %d.", G.Len(), H.Len())
+	}
+	for _, Z := range G.Nodes() {
+		N, e := H.Node(Z.ID())
+		if !e {
+			t.Errorf("Expected ID %d to be preserved.", Z.ID())
+			continue
+		}
+		if Z.Type() != N.Type() || len(Z.Ch()) != len(N.Ch()) {
+			t.Errorf("Expected node %d to keep its type and children.", Z.ID())
+		}
+		for i, c := range Z.Ch() {
+			if c.ID() != N.Ch()[i].ID() {
+				t.Errorf("Expected child %d of node %d to have ID %d, got %d.", i, Z.ID(), c.ID(),
+					N.Ch()[i].ID())
+			}
+		}
+	}
+	// New nodes never collide with loaded ones.
+	if _, e := G.Node(NewSum().ID()); e {
+		t.Errorf("Expected new IDs to be fresh.")
+	}
+}
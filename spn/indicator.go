@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/RenatoGeh/gospn/utils"
+	"math"
+	"math/rand"
 )
 
 // Indicator is an indicator node of a variable value X=x. Its value is 1 if X=x or is not set, and
@@ -18,12 +20,15 @@ type Indicator struct {
 
 // NewIndicator constructs a new indicator node.
 func NewIndicator(varid int, v int) *Indicator {
-	return &Indicator{Node{nil, []int{varid}}, varid, v}
+	return &Indicator{Node{sc: []int{varid}}, varid, v}
 }
 
 // Type returns the type of this node.
 func (i *Indicator) Type() string { return "leaf" }
 
+// SubType returns this leaf's subtype.
+func (i *Indicator) SubType() string { return "indicator" }
+
 // Value returns the probability of a certain valuation. In the case of an indicator node, 1 if X=x
 // or is not set and 0 otherwise.
 func (i *Indicator) Value(val VarSet) float64 {
@@ -48,12 +53,40 @@ func (i *Indicator) ArgMax(val VarSet) (VarSet, float64) {
 	retval := make(VarSet)
 	u, e := val[i.varid]
 	if !e || u == i.v {
-		retval[i.varid] = u
+		retval[i.varid] = i.v
 		return retval, 0
 	}
 	return retval, utils.LogZero
 }
 
+// Soft returns the likelihood of the indicated value under soft evidence L, in logspace.
+func (i *Indicator) Soft(L []float64) float64 {
+	if i.v < 0 || i.v >= len(L) {
+		return utils.LogZero
+	}
+	return math.Log(L[i.v])
+}
+
+// Interval returns 1 if a <= x <= b, where x is the indicated value, and 0 otherwise, in logspace.
+func (i *Indicator) Interval(a, b float64) float64 {
+	if x := float64(i.v); a <= x && x <= b {
+		return 0
+	}
+	return utils.LogZero
+}
+
+// Sample sets the variable to the value indicated by this node if it is not set in val.
+func (i *Indicator) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[i.varid]; !ok {
+		val[i.varid] = i.v
+	}
+}
+
+// Params returns the variable ID and the value indicated by this node.
+func (i *Indicator) Params() (int, int) {
+	return i.varid, i.v
+}
+
 // Sc returns the scope of this node.
 func (i *Indicator) Sc() []int {
 	if len(i.sc) == 0 {
@@ -0,0 +1,146 @@
+package spn
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// LazyLeaf is a proxy for a leaf whose parameters are only decoded on first use. LazyLeaves are
+// created by DecodeWith when DecodeOptions.Lazy is set. A LazyLeaf knows its subtype and scope
+// without decoding, and can be re-encoded without decoding. Any other use (Value, Max, ArgMax,
+// Sample and their real-valued variants) decodes the underlying leaf once, and delegates to it.
+// These methods panic if the leaf cannot be decoded; call Leaf to handle such errors instead.
+//
+// Code that type-switches on concrete leaf types (e.g. *Multinomial) will not see through a
+// LazyLeaf. Switch on the result of Unlazy instead, or use Materialize to replace every LazyLeaf
+// in a graph with its underlying leaf.
+type LazyLeaf struct {
+	Node
+	subtype string
+	data    []byte
+	once    sync.Once
+	// done is set to 1 once leaf has been successfully decoded.
+	done int32
+	leaf SPN
+	err  error
+}
+
+// Leaf decodes (if not yet decoded) and returns the underlying leaf. The underlying leaf has the
+// same ID as this proxy.
+func (l *LazyLeaf) Leaf() (SPN, error) {
+	l.once.Do(func() {
+		L, e := NewOfSubType(l.subtype)
+		if !e {
+			l.err = fmt.Errorf("spn: unregistered leaf subtype %s", l.subtype)
+			return
+		}
+		if l.err = L.(gob.GobDecoder).GobDecode(l.data); l.err != nil {
+			return
+		}
+		L.setID(l.ID())
+		l.leaf = L
+		atomic.StoreInt32(&l.done, 1)
+	})
+	return l.leaf, l.err
+}
+
+func (l *LazyLeaf) mustLeaf() SPN {
+	L, err := l.Leaf()
+	if err != nil {
+		panic(err)
+	}
+	return L
+}
+
+// Decoded returns whether the underlying leaf has already been decoded.
+func (l *LazyLeaf) Decoded() bool { return atomic.LoadInt32(&l.done) == 1 }
+
+// Type returns the type of this node.
+func (l *LazyLeaf) Type() string { return "leaf" }
+
+// SubType returns the subtype of the underlying leaf.
+func (l *LazyLeaf) SubType() string { return l.subtype }
+
+// Sc returns the scope of the underlying leaf.
+func (l *LazyLeaf) Sc() []int { return l.sc }
+
+// Value returns the value of the underlying leaf given an instantiation.
+func (l *LazyLeaf) Value(val VarSet) float64 { return l.mustLeaf().Value(val) }
+
+// Max returns the MAP value of the underlying leaf given an evidence.
+func (l *LazyLeaf) Max(val VarSet) float64 { return l.mustLeaf().Max(val) }
+
+// ArgMax returns the MAP state and value of the underlying leaf given an evidence.
+func (l *LazyLeaf) ArgMax(val VarSet) (VarSet, float64) { return l.mustLeaf().ArgMax(val) }
+
+// ValueF returns the value of the underlying leaf given a real-valued instantiation.
+func (l *LazyLeaf) ValueF(val VarSetF) float64 { return valueF(l.mustLeaf(), val) }
+
+// MaxF returns the MAP value of the underlying leaf given a real-valued evidence.
+func (l *LazyLeaf) MaxF(val VarSetF) float64 { return maxF(l.mustLeaf(), val) }
+
+// ArgMaxF returns the MAP state and value of the underlying leaf given a real-valued evidence.
+func (l *LazyLeaf) ArgMaxF(val VarSetF) (VarSetF, float64) { return argMaxF(l.mustLeaf(), val) }
+
+// Sample samples from the underlying leaf, which must implement Sampler.
+func (l *LazyLeaf) Sample(val VarSet, rng *rand.Rand) {
+	l.mustLeaf().(Sampler).Sample(val, rng)
+}
+
+// GobEncode returns the encoding of the underlying leaf, without decoding it if not yet decoded.
+func (l *LazyLeaf) GobEncode() ([]byte, error) {
+	if l.Decoded() {
+		return l.leaf.(gob.GobEncoder).GobEncode()
+	}
+	return l.data, nil
+}
+
+// Materialize replaces every LazyLeaf in S with its underlying leaf, decoding it if needed, and
+// returns the new root.
+func Materialize(S SPN) (SPN, error) {
+	var err error
+	get := func(Z SPN) SPN {
+		L, e := Unlazy(Z)
+		if e != nil {
+			err = e
+		}
+		return L
+	}
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		ch := Z.Ch()
+		for i, c := range ch {
+			ch[i] = get(c)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	S = get(S)
+	return S, err
+}
+
+// Unlazy returns the underlying leaf of S, decoding it if needed, if S is a LazyLeaf, and S
+// otherwise. If the leaf cannot be decoded, Unlazy returns S and the decoding error.
+func Unlazy(S SPN) (SPN, error) {
+	if l, ok := S.(*LazyLeaf); ok {
+		L, err := l.Leaf()
+		if err != nil {
+			return S, err
+		}
+		return L, nil
+	}
+	return S, nil
+}
+
+// unlazy is Unlazy, but panics if the leaf cannot be decoded.
+func unlazy(S SPN) SPN {
+	L, err := Unlazy(S)
+	if err != nil {
+		panic(err)
+	}
+	return L
+}
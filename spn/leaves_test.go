@@ -0,0 +1,475 @@
+package spn
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// discreteLeaves returns leaves of discrete distributions and an upper bound for their supports.
+func discreteLeaves() ([]SPN, int) {
+	return []SPN{NewBernoulli(0, 0.3), NewBinomial(0, 7, 0.4), NewPoisson(0, 3.5),
+		NewGeometric(0, 0.25)}, 200
+}
+
+// continuousLeaves returns leaves of continuous distributions over positive values.
+func continuousLeaves() []SPN {
+	return []SPN{NewExponential(0, 0.5), NewGamma(0, 2.5, 1.5), NewLogNormal(0, 0.5, 0.75)}
+}
+
+func TestDiscreteLeaves(t *testing.T) {
+	L, n := discreteLeaves()
+	for _, l := range L {
+		var z float64
+		m, mv := -1, math.Inf(-1)
+		for x := 0; x < n; x++ {
+			v := l.Value(VarSet{0: x})
+			z += math.Exp(v)
+			if v > mv {
+				m, mv = x, v
+			}
+		}
+		if math.Abs(z-1) > 1e-9 {
+			t.Errorf("Expected %s to sum to 1, got %v.", l.SubType(), z)
+		}
+		if v := l.Value(VarSet{0: -1}); !math.IsInf(v, -1) {
+			t.Errorf("Expected %s to be 0 outside its support, got %v.", l.SubType(), v)
+		}
+		if v := l.Value(VarSet{}); v != 0 {
+			t.Errorf("Expected %s to be 1 if unset, got %v.", l.SubType(), v)
+		}
+		if A, v := l.ArgMax(VarSet{}); A[0] != m || v != mv || l.Max(VarSet{}) != mv {
+			t.Errorf("Expected %s mode %d (%v), got %d (%v).", l.SubType(), m, mv, A[0], v)
+		}
+	}
+}
+
+func TestMultinomialPrior(t *testing.T) {
+	M := NewMultinomialPrior(0, []int{3, 0, 1}, 0.5)
+	if !equalFloats(M.Pr(), []float64{3.5 / 5.5, 0.5 / 5.5, 1.5 / 5.5}, 1e-15) {
+		t.Errorf("Unexpected posterior mean %v.", M.Pr())
+	}
+	if A, _ := M.ArgMax(VarSet{}); A[0] != 0 {
+		t.Errorf("Expected mode 0, got %d.", A[0])
+	}
+}
+
+func TestContinuousLeaves(t *testing.T) {
+	for _, l := range continuousLeaves() {
+		f := l.(LeafF)
+		// Trapezoidal rule over (0, 100].
+		const h = 1e-3
+		var z float64
+		m, mv := 0.0, math.Inf(-1)
+		for x := h; x < 100; x += h {
+			v := f.ValueF(VarSetF{0: x})
+			z += math.Exp(v) * h
+			if v > mv {
+				m, mv = x, v
+			}
+		}
+		if math.Abs(z-1) > 1e-3 {
+			t.Errorf("Expected %s to integrate to 1, got %v.", l.SubType(), z)
+		}
+		if A, v := f.ArgMaxF(VarSetF{}); math.Abs(A[0]-m) > 2*h || v < mv {
+			t.Errorf("Expected %s mode %v (%v), got %v (%v).", l.SubType(), m, mv, A[0], v)
+		}
+		if v, u := l.Value(VarSet{0: 2}), f.ValueF(VarSetF{0: 2}); v != u {
+			t.Errorf("Expected %s Value and ValueF to agree, got %v and %v.", l.SubType(), v, u)
+		}
+	}
+}
+
+func TestLeavesML(t *testing.T) {
+	rng := rand.New(rand.NewSource(101))
+	const n = 20000
+	close := func(name string, a, b, tol float64) {
+		if math.Abs(a-b) > tol*math.Abs(b) {
+			t.Errorf("Expected %s estimate %v, got %v.", name, b, a)
+		}
+	}
+	sample := func(l SPN) ([]int, []float64) {
+		X, F := make([]int, n), make([]float64, n)
+		for i := range X {
+			V := make(VarSet)
+			l.(Sampler).Sample(V, rng)
+			X[i], F[i] = V[0], float64(V[0])
+		}
+		return X, F
+	}
+	X, _ := sample(NewBernoulli(0, 0.3))
+	close("bernoulli", NewBernoulliML(0, X).Params(), 0.3, 0.05)
+	X, _ = sample(NewBinomial(0, 7, 0.4))
+	_, p := NewBinomialML(0, 7, X).Params()
+	close("binomial", p, 0.4, 0.05)
+	X, _ = sample(NewPoisson(0, 3.5))
+	close("poisson", NewPoissonML(0, X).Params(), 3.5, 0.05)
+	X, _ = sample(NewPoisson(0, 45))
+	close("poisson", NewPoissonML(0, X).Params(), 45, 0.05)
+	X, _ = sample(NewGeometric(0, 0.25))
+	close("geometric", NewGeometricML(0, X).Params(), 0.25, 0.05)
+	// Continuous samples are rounded, so sample from large scales.
+	_, F := sample(NewExponential(0, 0.01))
+	close("exponential", NewExponentialML(0, F).Params(), 0.01, 0.05)
+	_, F = sample(NewGamma(0, 2.5, 100))
+	for i := range F {
+		F[i] = math.Max(F[i], 1)
+	}
+	k, theta := NewGammaML(0, F).Params()
+	close("gamma shape", k, 2.5, 0.05)
+	close("gamma scale", theta, 100, 0.05)
+	_, F = sample(NewLogNormal(0, 5, 0.5))
+	mu, sigma := NewLogNormalML(0, F).Params()
+	close("lognormal mu", mu, 5, 0.05)
+	close("lognormal sigma", sigma, 0.5, 0.05)
+}
+
+func TestLeavesSerial(t *testing.T) {
+	L, _ := discreteLeaves()
+	P := NewProduct()
+	for _, l := range append(L, continuousLeaves()...) {
+		P.AddChild(l)
+	}
+	var b bytes.Buffer
+	if err := Encode(&b, P); err != nil {
+		t.Fatal(err)
+	}
+	Q, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(P, Q, 0) || !Equal(P, Clone(P), 0) {
+		t.Errorf("Expected leaves to be preserved by Encode, Decode and Clone.")
+	}
+	if st := Stats(P); st.Params != 1+1+1+1+1+2+2 {
+		t.Errorf("Expected %d parameters, got %d.", 1+1+1+1+1+2+2, st.Params)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	H := NewHistogram(0, []float64{0, 1, 3, 4}, []float64{1, 6, 1})
+	if !equalFloats(H.Params(), []float64{0.125, 0.75, 0.125}, 1e-15) {
+		t.Errorf("Unexpected masses %v.", H.Params())
+	}
+	for _, c := range []struct {
+		x   float64
+		bin int
+	}{{-0.5, -1}, {0, 0}, {0.5, 0}, {1, 1}, {2.9, 1}, {3, 2}, {4, 2}, {4.5, -1}} {
+		if b := H.Bin(c.x); b != c.bin {
+			t.Errorf("Expected %v to fall into bin %d, got %d.", c.x, c.bin, b)
+		}
+	}
+	if v := H.ValueF(VarSetF{0: 2}); math.Abs(v-math.Log(0.375)) > 1e-12 {
+		t.Errorf("Expected density %v, got %v.", math.Log(0.375), v)
+	}
+	if v := H.Value(VarSet{0: 5}); !math.IsInf(v, -1) {
+		t.Errorf("Expected zero density outside the histogram, got %v.", v)
+	}
+	if A, v := H.ArgMaxF(VarSetF{}); A[0] != 2 || math.Abs(v-math.Log(0.375)) > 1e-12 {
+		t.Errorf("Expected mode 2 (%v), got %v (%v).", math.Log(0.375), A[0], v)
+	}
+	if A, _ := H.ArgMax(VarSet{}); A[0] != 1 {
+		t.Errorf("Expected integer mode 1, got %d.", A[0])
+	}
+	D := H.Deriv(VarSetF{0: 3.5})
+	if !math.IsInf(D[0], -1) || !math.IsInf(D[1], -1) || D[2] != 0 {
+		t.Errorf("Unexpected derivatives %v.", D)
+	}
+	M := NewHistogramML(0, UniformEdges(0, 4, 2), []float64{0.5, 1, 1.5, 3, -1})
+	if !equalFloats(M.Params(), []float64{4.0 / 6, 2.0 / 6}, 1e-15) {
+		t.Errorf("Unexpected smoothed masses %v.", M.Params())
+	}
+	if E := QuantileEdges([]float64{4, 1, 3, 2, 5}, 2); !equalFloats(E, []float64{1, 3, 5}, 0) {
+		t.Errorf("Unexpected quantile edges %v.", E)
+	}
+}
+
+func TestPiecewiseLinear(t *testing.T) {
+	P := NewPiecewiseLinear(0, []float64{0, 1, 3}, []float64{0, 2, 0})
+	if !equalFloats(P.Params(), []float64{0, 2.0 / 3, 0}, 1e-15) {
+		t.Errorf("Unexpected densities %v.", P.Params())
+	}
+	if v := P.ValueF(VarSetF{0: 2}); math.Abs(v-math.Log(1.0/3)) > 1e-12 {
+		t.Errorf("Expected density %v, got %v.", math.Log(1.0/3), v)
+	}
+	if A, v := P.ArgMaxF(VarSetF{}); A[0] != 1 || math.Abs(v-math.Log(2.0/3)) > 1e-12 {
+		t.Errorf("Expected mode 1 (%v), got %v (%v).", math.Log(2.0/3), A[0], v)
+	}
+	rng := rand.New(rand.NewSource(101))
+	var m float64
+	const n = 20000
+	for i := 0; i < n; i++ {
+		V := make(VarSet)
+		P.Sample(V, rng)
+		m += float64(V[0])
+	}
+	// The mean of a triangular distribution over (0, 3) with mode 1 is 4/3; rounding shifts it
+	// slightly, so only check it is close.
+	if m /= n; math.Abs(m-4.0/3) > 0.1 {
+		t.Errorf("Expected sample mean close to %v, got %v.", 4.0/3, m)
+	}
+	H := NewHistogramML(0, UniformEdges(0, 10, 5), []float64{1, 1, 5, 5, 5, 9})
+	L := NewPiecewiseLinearHistogram(H)
+	const h = 1e-4
+	var z float64
+	for x := h / 2; x < 10; x += h {
+		z += math.Exp(L.ValueF(VarSetF{0: x})) * h
+	}
+	if math.Abs(z-1) > 1e-3 {
+		t.Errorf("Expected piecewise-linear density to integrate to 1, got %v.", z)
+	}
+	if A, _ := L.ArgMaxF(VarSetF{}); A[0] != 5 {
+		t.Errorf("Expected mode at the center of the fullest bin, got %v.", A[0])
+	}
+}
+
+func TestNonparametricSerial(t *testing.T) {
+	P := NewProduct()
+	P.AddChild(NewHistogram(0, []float64{0, 0.5, 3}, []float64{0.2, 0.8}))
+	P.AddChild(NewPiecewiseLinear(1, []float64{-1, 0, 2.5}, []float64{0.1, 0.7, 0.3}))
+	var b bytes.Buffer
+	if err := Encode(&b, P); err != nil {
+		t.Fatal(err)
+	}
+	Q, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(P, Q, 0) || !Equal(P, Clone(P), 0) {
+		t.Errorf("Expected nonparametric leaves to be preserved by Encode, Decode and Clone.")
+	}
+	V := VarSetF{0: 1, 1: 1}
+	if a, b := InferenceF(P, V), InferenceF(Q, V); a != b {
+		t.Errorf("Expected equal values, got %v and %v.", a, b)
+	}
+	if st := Stats(P); st.Params != 1+2 {
+		t.Errorf("Expected %d parameters, got %d.", 1+2, st.Params)
+	}
+}
+
+// chowLiuChain returns a Chow-Liu tree over the chain X_2 -> X_0 -> X_1.
+func chowLiuChain() *ChowLiu {
+	return NewChowLiu([]int{2, 0, 1}, []int{2, 3, 2}, []int{-1, 0, 1}, [][][]float64{
+		{{0.4, 0.6}},
+		{{0.1, 0.3, 0.6}, {0.5, 0.25, 0.25}},
+		{{0.9, 0.1}, {0.2, 0.8}, {0.5, 0.5}},
+	})
+}
+
+func TestChowLiu(t *testing.T) {
+	C := chowLiuChain()
+	joint := func(x0, x1, x2 int) float64 {
+		_, _, _, pr := C.Params()
+		return pr[0][0][x2] * pr[1][x2][x0] * pr[2][x0][x1]
+	}
+	var z, m float64
+	var M VarSet
+	for x0 := 0; x0 < 3; x0++ {
+		var p float64
+		for x1 := 0; x1 < 2; x1++ {
+			for x2 := 0; x2 < 2; x2++ {
+				j := joint(x0, x1, x2)
+				z, p = z+j, p+j
+				if j > m {
+					m, M = j, VarSet{0: x0, 1: x1, 2: x2}
+				}
+			}
+		}
+		if v := C.Value(VarSet{0: x0}); math.Abs(v-math.Log(p)) > 1e-12 {
+			t.Errorf("Expected marginal %v, got %v.", math.Log(p), v)
+		}
+	}
+	if v := C.Value(VarSet{}); math.Abs(z-1) > 1e-12 || math.Abs(v) > 1e-12 {
+		t.Errorf("Expected tree to sum to 1, got %v and %v.", z, math.Exp(v))
+	}
+	if A, v := C.ArgMax(VarSet{}); A[0] != M[0] || A[1] != M[1] || A[2] != M[2] ||
+		math.Abs(v-math.Log(m)) > 1e-12 {
+		t.Errorf("Expected MAP %v (%v), got %v (%v).", M, math.Log(m), A, v)
+	}
+	if A, _ := C.ArgMax(VarSet{1: 0}); A[1] != 0 || A[0] != 0 {
+		t.Errorf("Expected MAP to respect evidence, got %v.", A)
+	}
+	if v := C.Value(VarSet{0: 3}); !math.IsInf(v, -1) {
+		t.Errorf("Expected zero probability outside support, got %v.", v)
+	}
+	// Learn back the chain from samples.
+	rng := rand.New(rand.NewSource(101))
+	D := make(Dataset, 20000)
+	for i := range D {
+		D[i] = make(VarSet)
+		C.Sample(D[i], rng)
+	}
+	L := NewChowLiuML([]int{0, 1, 2}, []int{3, 2, 2}, D)
+	vars, _, pa, _ := L.Params()
+	edges := make(map[[2]int]bool)
+	for i, p := range pa {
+		if p >= 0 {
+			u, v := vars[i], vars[p]
+			if u > v {
+				u, v = v, u
+			}
+			edges[[2]int{u, v}] = true
+		}
+	}
+	if len(edges) != 2 || !edges[[2]int{0, 1}] || !edges[[2]int{0, 2}] {
+		t.Errorf("Expected to learn the chain's edges, got %v.", edges)
+	}
+	for _, V := range []VarSet{{0: 1}, {1: 1, 2: 0}, {0: 2, 1: 1, 2: 1}} {
+		if v, u := L.Value(V), C.Value(V); math.Abs(math.Exp(v)-math.Exp(u)) > 0.02 {
+			t.Errorf("Expected learnt probability %v, got %v.", math.Exp(u), math.Exp(v))
+		}
+	}
+}
+
+func TestMVGaussian(t *testing.T) {
+	G := NewMVGaussian([]int{3, 1}, []float64{1, -2}, [][]float64{{4, 1.2}, {1.2, 1}})
+	// The marginal of X_1 is N(-2, 1).
+	v, u := G.ValueF(VarSetF{1: 0}), NewGaussianParams(1, -2, 1).ValueF(VarSetF{1: 0})
+	if math.Abs(v-u) > 1e-12 {
+		t.Errorf("Expected marginal %v, got %v.", u, v)
+	}
+	if v := G.ValueF(VarSetF{}); v != 0 {
+		t.Errorf("Expected 1 if unset, got %v.", v)
+	}
+	// E[X_3 | X_1 = 0] = 1 + 1.2*(0+2)/1 = 3.4.
+	if A, _ := G.ArgMaxF(VarSetF{1: 0}); math.Abs(A[3]-3.4) > 1e-12 || A[1] != 0 {
+		t.Errorf("Expected conditional mean 3.4, got %v.", A)
+	}
+	A, v := G.ArgMaxF(VarSetF{})
+	if A[3] != 1 || A[1] != -2 || math.Abs(v-G.ValueF(A)) > 1e-12 || G.MaxF(VarSetF{}) != v {
+		t.Errorf("Expected mode at the mean, got %v (%v).", A, v)
+	}
+	rng := rand.New(rand.NewSource(101))
+	D := make(DatasetF, 20000)
+	for i := range D {
+		D[i] = VarSetF{3: rng.NormFloat64()*10 + 100}
+		D[i][1] = 0.5*D[i][3] + rng.NormFloat64()*5
+	}
+	_, mu, sigma := NewMVGaussianML([]int{3, 1}, D).Params()
+	if math.Abs(mu[0]-100) > 1 || math.Abs(mu[1]-50) > 1 || math.Abs(sigma[0][0]-100) > 5 ||
+		math.Abs(sigma[0][1]-50) > 5 || math.Abs(sigma[1][1]-50) > 5 {
+		t.Errorf("Unexpected estimates %v and %v.", mu, sigma)
+	}
+}
+
+func TestMultivariateSerial(t *testing.T) {
+	P := NewProduct()
+	P.AddChild(chowLiuChain())
+	P.AddChild(NewMVGaussian([]int{3, 4}, []float64{1, -2}, [][]float64{{4, 1.2}, {1.2, 1}}))
+	var b bytes.Buffer
+	if err := Encode(&b, P); err != nil {
+		t.Fatal(err)
+	}
+	Q, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(P, Q, 0) || !Equal(P, Clone(P), 0) {
+		t.Errorf("Expected multivariate leaves to be preserved by Encode, Decode and Clone.")
+	}
+	V := VarSet{0: 1, 2: 0, 4: 1}
+	if a, b := Inference(P, V), Inference(Q, V); a != b {
+		t.Errorf("Expected equal values, got %v and %v.", a, b)
+	}
+	if st := Stats(P); st.Params != 1+4+3+2+3 {
+		t.Errorf("Expected %d parameters, got %d.", 1+4+3+2+3, st.Params)
+	}
+}
+
+func TestGaussian(t *testing.T) {
+	P := NewGaussianParams(0, 2.5, 0)
+	if v := P.Value(VarSet{}); v != 0 {
+		t.Errorf("Expected point mass to be marginalized, got %v.", v)
+	}
+	if v, u := P.ValueF(VarSetF{0: 2.5}), P.Value(VarSet{0: 2}); v != 0 || !math.IsInf(u, -1) {
+		t.Errorf("Expected point mass on 2.5, got %v and %v.", v, u)
+	}
+	if A, v := P.ArgMax(VarSet{0: 1}); A[0] != 1 || !math.IsInf(v, -1) {
+		t.Errorf("Expected ArgMax to respect evidence, got %v (%v).", A, v)
+	}
+	if mu, _ := NewGaussianMode(0, []int{1, 5, 2, 2}).Params(); mu != 1 {
+		t.Errorf("Expected gaussian centered on mode 1, got %v.", mu)
+	}
+	MinGaussianVariance = 0.25
+	_, sigma := NewGaussianRaw(0, []float64{3, 3, 3}).Params()
+	MinGaussianVariance = 0
+	if sigma != 0.5 {
+		t.Errorf("Expected floored standard deviation 0.5, got %v.", sigma)
+	}
+	G := NewGaussianParams(0, 1, 2)
+	if v := G.Interval(-1, 3); math.Abs(math.Exp(v)-0.682689492137) > 1e-9 {
+		t.Errorf("Expected one sigma mass 0.6827, got %v.", math.Exp(v))
+	}
+	// Far tails must not vanish.
+	if v, u := G.Interval(41, 43), G.Interval(-41, -39); math.IsInf(v, -1) || math.Abs(v-u) > 1e-9 {
+		t.Errorf("Expected equal finite tail masses, got %v and %v.", v, u)
+	}
+	if v := G.Interval(3, 2); !math.IsInf(v, -1) {
+		t.Errorf("Expected empty interval to have zero mass, got %v.", v)
+	}
+}
+
+func TestDiscreteGaussian(t *testing.T) {
+	for _, G := range []*DiscreteGaussian{NewTruncatedGaussian(0, 200, 30, 0, 255),
+		NewTruncatedGaussian(0, -3, 2, 0, 10), NewDiscreteGaussian(0, 4.2, 1.5)} {
+		lo, hi := G.Support()
+		var z float64
+		for x := int(math.Max(lo, -100)); x <= int(math.Min(hi, 300)); x++ {
+			z += math.Exp(G.Value(VarSet{0: x}))
+		}
+		if math.Abs(z-1) > 1e-9 {
+			t.Errorf("Expected discrete gaussian to sum to 1, got %v.", z)
+		}
+		if v := G.Interval(math.Inf(-1), math.Inf(1)); math.Abs(v) > 1e-9 {
+			t.Errorf("Expected whole support to have mass 1, got %v.", math.Exp(v))
+		}
+	}
+	G := NewTruncatedGaussian(0, -3, 2, 0, 10)
+	if A, v := G.ArgMax(VarSet{}); A[0] != 0 || v != G.Value(VarSet{0: 0}) {
+		t.Errorf("Expected mode at the lower bound, got %v (%v).", A, v)
+	}
+	if v := G.Value(VarSet{0: -1}); !math.IsInf(v, -1) {
+		t.Errorf("Expected zero probability outside support, got %v.", v)
+	}
+	var s float64
+	for x := 2; x <= 4; x++ {
+		s += math.Exp(G.Value(VarSet{0: x}))
+	}
+	if v := G.Interval(1.5, 4.2); math.Abs(math.Exp(v)-s) > 1e-12 {
+		t.Errorf("Expected interval mass %v, got %v.", s, math.Exp(v))
+	}
+	rng := rand.New(rand.NewSource(101))
+	var m float64
+	const n = 20000
+	H := NewTruncatedGaussian(0, 250, 10, 0, 255)
+	for i := 0; i < n; i++ {
+		V := make(VarSet)
+		H.Sample(V, rng)
+		if V[0] < 0 || V[0] > 255 {
+			t.Fatalf("Expected samples within the support, got %d.", V[0])
+		}
+		m += float64(V[0])
+	}
+	var e float64
+	for x := 0; x <= 255; x++ {
+		e += float64(x) * math.Exp(H.Value(VarSet{0: x}))
+	}
+	if m /= n; math.Abs(m-e) > 0.2 {
+		t.Errorf("Expected sample mean %v, got %v.", e, m)
+	}
+	P := NewProduct()
+	P.AddChild(NewDiscreteGaussian(0, 4.2, 1.5))
+	P.AddChild(NewTruncatedGaussian(1, 200, 30, 0, 255))
+	var b bytes.Buffer
+	if err := Encode(&b, P); err != nil {
+		t.Fatal(err)
+	}
+	Q, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if V := (VarSet{0: 3, 1: 210}); !Equal(P, Q, 0) || Inference(P, V) != Inference(Q, V) {
+		t.Errorf("Expected discrete gaussians to be preserved by Encode and Decode.")
+	}
+}
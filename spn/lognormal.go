@@ -0,0 +1,160 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// LogNormal represents a log-normal distribution over a positive continuous variable X, where
+// ln(X) is normally distributed with mean mu and standard deviation sigma.
+type LogNormal struct {
+	Node
+	// Variable ID
+	varid int
+	// Mean of ln(X)
+	mu float64
+	// Standard deviation of ln(X)
+	sigma float64
+}
+
+// NewLogNormal constructs a new LogNormal with log-mean mu and log-standard deviation sigma.
+func NewLogNormal(varid int, mu, sigma float64) *LogNormal {
+	return &LogNormal{Node{sc: []int{varid}}, varid, mu, sigma}
+}
+
+// NewLogNormalML constructs a new LogNormal from the maximum likelihood estimate of data X, a
+// slice of positive values.
+func NewLogNormalML(varid int, X []float64) *LogNormal {
+	L := make([]float64, len(X))
+	for i, x := range X {
+		L[i] = math.Log(x)
+	}
+	mu := meanFloats(L)
+	var s float64
+	for _, l := range L {
+		s += (l - mu) * (l - mu)
+	}
+	return NewLogNormal(varid, mu, math.Sqrt(s/float64(len(L))))
+}
+
+// Type returns the type of this node.
+func (l *LogNormal) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (l *LogNormal) SubType() string { return "lognormal" }
+
+// logProb returns the log-density at x.
+func (l *LogNormal) logProb(x float64) float64 {
+	if x <= 0 {
+		return math.Inf(-1)
+	}
+	lx := math.Log(x)
+	if l.sigma == 0 {
+		if lx == l.mu {
+			return 0
+		}
+		return math.Inf(-1)
+	}
+	z := (lx - l.mu) / l.sigma
+	return -lx - math.Log(l.sigma) - 0.5*math.Log(2*math.Pi) - z*z/2
+}
+
+// Interval returns the probability Pr(a <= X <= b) = Pr(ln(a) <= ln(X) <= ln(b)), in logspace.
+func (l *LogNormal) Interval(a, b float64) float64 {
+	if b <= 0 {
+		return math.Inf(-1)
+	}
+	return normalMass(l.mu, l.sigma, math.Log(math.Max(a, 0)), math.Log(b))
+}
+
+// mode returns the point of highest density.
+func (l *LogNormal) mode() float64 { return math.Exp(l.mu - l.sigma*l.sigma) }
+
+// Value returns the density of a certain valuation. That is p(X=val[varid]), or 1 if the variable
+// is not set.
+func (l *LogNormal) Value(val VarSet) float64 {
+	if v, ok := val[l.varid]; ok {
+		return l.logProb(float64(v))
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (l *LogNormal) Max(val VarSet) float64 {
+	if v, ok := val[l.varid]; ok {
+		return l.logProb(float64(v))
+	}
+	return l.logProb(l.mode())
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation. The
+// mode is rounded to the nearest integer, but the returned value is the density at the mode.
+func (l *LogNormal) ArgMax(val VarSet) (VarSet, float64) {
+	if v, ok := val[l.varid]; ok {
+		return VarSet{l.varid: v}, l.logProb(float64(v))
+	}
+	m := l.mode()
+	return VarSet{l.varid: int(math.Round(m))}, l.logProb(m)
+}
+
+// ValueF returns the density of a certain real-valued valuation.
+func (l *LogNormal) ValueF(val VarSetF) float64 {
+	if v, ok := val[l.varid]; ok {
+		return l.logProb(v)
+	}
+	return 0
+}
+
+// MaxF returns the MAP value given a real-valued valuation.
+func (l *LogNormal) MaxF(val VarSetF) float64 {
+	if v, ok := val[l.varid]; ok {
+		return l.logProb(v)
+	}
+	return l.logProb(l.mode())
+}
+
+// ArgMaxF returns both the arguments and the value of the MAP state given a certain real-valued
+// valuation.
+func (l *LogNormal) ArgMaxF(val VarSetF) (VarSetF, float64) {
+	v, ok := val[l.varid]
+	if !ok {
+		v = l.mode()
+	}
+	return VarSetF{l.varid: v}, l.logProb(v)
+}
+
+// Sample draws a value from this distribution, rounded to the nearest integer, if the variable is
+// not set in val.
+func (l *LogNormal) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[l.varid]; ok {
+		return
+	}
+	val[l.varid] = int(math.Round(math.Exp(rng.NormFloat64()*l.sigma + l.mu)))
+}
+
+// Params returns the mean and standard deviation of ln(X).
+func (l *LogNormal) Params() (float64, float64) { return l.mu, l.sigma }
+
+// Sc returns the scope of this node.
+func (l *LogNormal) Sc() []int {
+	if len(l.sc) == 0 {
+		l.sc = []int{l.varid}
+	}
+	return l.sc
+}
+
+// GobEncode serializes this log-normal node.
+func (l *LogNormal) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, l.varid, l.mu, l.sigma)
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this log-normal node.
+func (l *LogNormal) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &l.varid, &l.mu, &l.sigma)
+	l.sc = []int{l.varid}
+	return err
+}
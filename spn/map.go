@@ -0,0 +1,348 @@
+package spn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MAPSolver finds a MAP (or MPE) state of an SPN given some evidence.
+type MAPSolver interface {
+	// MAP returns a complete state of S, consisting of the evidence I and the values found for the
+	// unobserved variables, together with its log-probability ln S(state).
+	MAP(S SPN, I VarSet) (VarSet, float64)
+}
+
+// MAP returns a MAP state of S given evidence I and its log-probability, and whether the state is
+// exact. The state is computed by ExactMPE with its default configuration when possible, and
+// approximated by LocalSearch over ArgMaxProduct otherwise.
+func MAP(S SPN, I VarSet) (VarSet, float64, bool) {
+	if X, v, err := (ExactMPE{}).Solve(S, I); err == nil {
+		return X, v, true
+	}
+	X, v := LocalSearch{Init: ArgMaxProduct{}}.MAP(S, I)
+	return X, v, false
+}
+
+// MaxProduct is the max-product approximation of the MAP, where sum nodes are replaced with max
+// nodes and the max child of each sum node is followed from the root (see StoreMAP). Ties are
+// broken at random. The approximation is exact when S is selective.
+type MaxProduct struct{}
+
+// MAP returns the max-product state and its log-probability.
+func (MaxProduct) MAP(S SPN, I VarSet) (VarSet, float64) {
+	P := Compile(S)
+	X, _ := P.EvalMAP(I)
+	return complete(P, X, I)
+}
+
+// ArgMaxProduct is the ArgMax-Product approximation of the MAP, as described in
+//  Approximation Complexity of Maximum A Posteriori Inference in Sum-Product Networks
+//  Diarmaid Conaty, Denis D. Mauá and Cassio P. de Campos
+//  Uncertainty in Artificial Intelligence 33 (UAI 2017)
+// Each node computes a candidate state bottom-up. Product nodes join their children's candidates
+// and sum nodes choose, among their children's candidates, the one that maximizes the sum node's
+// (soft) value. This is at least as good as MaxProduct, but takes quadratic time on the size of
+// the network.
+type ArgMaxProduct struct{}
+
+// MAP returns the ArgMax-Product state and its log-probability.
+func (ArgMaxProduct) MAP(S SPN, I VarSet) (VarSet, float64) {
+	P := Compile(S)
+	n := P.Len()
+	C := make([]VarSet, n)
+	V := make([]float64, n)
+	// Number of parents yet to be computed, so that candidates can be released early.
+	pa := make([]int, n)
+	for _, c := range P.ch {
+		pa[c]++
+	}
+	for i, Z := range P.nodes {
+		switch P.kind[i] {
+		case kLeaf:
+			C[i], _ = Z.ArgMax(I)
+		case kProduct:
+			X := make(VarSet)
+			for _, c := range P.Ch(i) {
+				for k, v := range C[c] {
+					X[k] = v
+				}
+			}
+			C[i] = X
+		case kSum:
+			D := P.descendants(i)
+			m, mc := math.Inf(-1), -1
+			for _, c := range P.Ch(i) {
+				X := join(C[c], I)
+				if v := P.subValues(D, X, V); mc < 0 || v > m {
+					m, mc = v, c
+				}
+			}
+			if mc >= 0 {
+				C[i] = C[mc]
+			} else {
+				C[i] = make(VarSet)
+			}
+		}
+		for _, c := range P.Ch(i) {
+			if pa[c]--; pa[c] == 0 {
+				C[c] = nil
+			}
+		}
+	}
+	return complete(P, C[P.Root()], I)
+}
+
+// LocalSearch refines the state found by another MAPSolver by hill climbing: at each iteration,
+// every single-variable change of the current state is evaluated exactly, and the best improving
+// change is applied. The search stops when no change improves the log-probability or after
+// MaxIterations iterations. Categorical variables (those of spn.Multinomial and spn.Indicator
+// leaves) range over their categories. Any other variable is only moved to its neighbouring
+// integer values.
+type LocalSearch struct {
+	// Init is the solver to compute the initial state. If nil, MaxProduct is used.
+	Init MAPSolver
+	// MaxIterations is the maximum number of iterations. If <= 0, defaults to 100.
+	MaxIterations int
+}
+
+// MAP returns the refined state and its log-probability.
+func (ls LocalSearch) MAP(S SPN, I VarSet) (VarSet, float64) {
+	init, it := ls.Init, ls.MaxIterations
+	if init == nil {
+		init = MaxProduct{}
+	}
+	if it <= 0 {
+		it = 100
+	}
+	X, v := init.MAP(S, I)
+	P := Compile(S)
+	dom := domains(P)
+	var vars []int
+	for k := range X {
+		if _, e := I[k]; !e {
+			vars = append(vars, k)
+		}
+	}
+	sort.Ints(vars)
+	for l := 0; l < it; l++ {
+		bk, bv, bu := -1, 0, v
+		for _, k := range vars {
+			o := X[k]
+			try := func(u int) {
+				X[k] = u
+				if w := P.Eval(X); w > bu {
+					bk, bv, bu = k, u, w
+				}
+			}
+			if D, e := dom[k]; e {
+				for _, u := range D {
+					if u != o {
+						try(u)
+					}
+				}
+			} else {
+				try(o - 1)
+				try(o + 1)
+			}
+			X[k] = o
+		}
+		if bk < 0 {
+			break
+		}
+		X[bk], v = bv, bu
+	}
+	return X, v
+}
+
+// ExactMPE computes the exact MPE state. When S is selective (also known as deterministic), the
+// max-product algorithm is exact, and thus ExactMPE runs in linear time. Otherwise, ExactMPE
+// enumerates every joint state of the unobserved variables, which is only feasible for small
+// state spaces. If the state space is larger than Limit, or if some unobserved variable is not
+// categorical, the exact MPE cannot be computed: Solve returns an error and MAP panics. Use MAP
+// (the function) for a state that is approximated in such cases.
+type ExactMPE struct {
+	// Limit is the maximum number of joint states to be enumerated. If <= 0, defaults to 1<<16.
+	Limit int
+}
+
+// MAP returns the MPE state and its log-probability. It panics if the MPE cannot be computed; call
+// Solve to handle such errors instead.
+func (e ExactMPE) MAP(S SPN, I VarSet) (VarSet, float64) {
+	X, v, err := e.Solve(S, I)
+	if err != nil {
+		panic(err)
+	}
+	return X, v
+}
+
+// Solve returns the MPE state and its log-probability, or an error if S is not selective and
+// either some unobserved variable is not categorical or the state space is larger than Limit.
+func (e ExactMPE) Solve(S SPN, I VarSet) (VarSet, float64, error) {
+	if _, ok := Selectivity(S); ok {
+		X, v := MaxProduct{}.MAP(S, I)
+		return X, v, nil
+	}
+	lim := e.Limit
+	if lim <= 0 {
+		lim = 1 << 16
+	}
+	P := Compile(S)
+	dom := domains(P)
+	var vars []int
+	t := 1
+	for k := range scopeOf(P) {
+		if _, o := I[k]; o {
+			continue
+		}
+		D, c := dom[k]
+		if !c {
+			return nil, 0, fmt.Errorf("spn: exact MPE: variable %d is not categorical", k)
+		}
+		if t*len(D) > lim {
+			return nil, 0, fmt.Errorf("spn: exact MPE: more than %d joint states", lim)
+		}
+		t *= len(D)
+		vars = append(vars, k)
+	}
+	sort.Ints(vars)
+	X := join(nil, I)
+	var M VarSet
+	m := math.Inf(-1)
+	var enum func(j int)
+	enum = func(j int) {
+		if j == len(vars) {
+			if v := P.Eval(X); M == nil || v > m {
+				M, m = join(X, nil), v
+			}
+			return
+		}
+		k := vars[j]
+		for _, u := range dom[k] {
+			X[k] = u
+			enum(j + 1)
+		}
+	}
+	enum(0)
+	return M, m, nil
+}
+
+// complete joins state X with evidence I and computes its exact log-probability.
+func complete(P *Plan, X, I VarSet) (VarSet, float64) {
+	X = join(X, I)
+	return X, P.Eval(X)
+}
+
+// join returns a new VarSet with the union of X and Y. Values of Y take precedence.
+func join(X, Y VarSet) VarSet {
+	Z := make(VarSet, len(X)+len(Y))
+	for k, v := range X {
+		Z[k] = v
+	}
+	for k, v := range Y {
+		Z[k] = v
+	}
+	return Z
+}
+
+// descendants returns the indices of every node reachable from node i (including i) in increasing
+// order, which is a valid evaluation order.
+func (p *Plan) descendants(i int) []int {
+	V := map[int]bool{i: true}
+	T := []int{i}
+	D := []int{i}
+	for len(T) > 0 {
+		j := T[len(T)-1]
+		T = T[:len(T)-1]
+		for _, c := range p.Ch(j) {
+			if !V[c] {
+				V[c] = true
+				T = append(T, c)
+				D = append(D, c)
+			}
+		}
+	}
+	sort.Ints(D)
+	return D
+}
+
+// subValues evaluates the nodes in D (in order) given the valuation I, using V as storage, and
+// returns the value of the last node in D.
+func (p *Plan) subValues(D []int, I VarSet, V []float64) float64 {
+	for _, i := range D {
+		switch p.kind[i] {
+		case kLeaf:
+			V[i] = p.nodes[i].Value(I)
+		case kSum:
+			V[i] = p.lse(i, V)
+		case kProduct:
+			var r float64
+			for _, c := range p.Ch(i) {
+				r += V[c]
+			}
+			V[i] = r
+		}
+	}
+	return V[D[len(D)-1]]
+}
+
+// domains returns the values each categorical variable can take, as given by the categorical
+// leaves of the plan.
+func domains(P *Plan) map[int][]int {
+	M := make(map[int]map[int]bool)
+	add := func(k, v int) {
+		if M[k] == nil {
+			M[k] = make(map[int]bool)
+		}
+		M[k][v] = true
+	}
+	for i, Z := range P.nodes {
+		if P.kind[i] != kLeaf {
+			continue
+		}
+		switch L := unlazy(Z).(type) {
+		case *Multinomial:
+			for v := range L.pr {
+				add(L.varid, v)
+			}
+		case *Indicator:
+			add(L.varid, L.v)
+		}
+	}
+	// Variables with a non-categorical leaf have no finite domain.
+	for i, Z := range P.nodes {
+		if P.kind[i] != kLeaf {
+			continue
+		}
+		switch unlazy(Z).(type) {
+		case *Multinomial, *Indicator:
+		default:
+			for _, k := range Z.Sc() {
+				delete(M, k)
+			}
+		}
+	}
+	R := make(map[int][]int)
+	for k, S := range M {
+		var D []int
+		for v := range S {
+			D = append(D, v)
+		}
+		sort.Ints(D)
+		R[k] = D
+	}
+	return R
+}
+
+// scopeOf returns the set of variables found at the leaves of the plan.
+func scopeOf(P *Plan) map[int]bool {
+	M := make(map[int]bool)
+	for i, Z := range P.nodes {
+		if P.kind[i] == kLeaf {
+			for _, k := range Z.Sc() {
+				M[k] = true
+			}
+		}
+	}
+	return M
+}
@@ -0,0 +1,89 @@
+package spn
+
+import (
+	"math"
+	"testing"
+)
+
+func selectiveSPN() SPN {
+	R := NewSum()
+	P1, P2 := NewProduct(), NewProduct()
+	P1.AddChild(NewIndicator(0, 0))
+	P1.AddChild(NewMultinomial(1, []float64{0.3, 0.3, 0.4}))
+	P2.AddChild(NewIndicator(0, 1))
+	P2.AddChild(NewMultinomial(1, []float64{0.45, 0.1, 0.45}))
+	R.AddChildW(P1, 0.4)
+	R.AddChildW(P2, 0.6)
+	return R
+}
+
+// bruteMPE returns the max log-probability over all states of variables 0 and 1 consistent with
+// evidence I.
+func bruteMPE(S SPN, I VarSet) float64 {
+	dom := domains(Compile(S))
+	m := math.Inf(-1)
+	for _, a := range dom[0] {
+		for _, b := range dom[1] {
+			X := VarSet{0: a, 1: b}
+			if u, e := I[0]; e && u != a {
+				continue
+			}
+			if u, e := I[1]; e && u != b {
+				continue
+			}
+			if v := Inference(S, X); v > m {
+				m = v
+			}
+		}
+	}
+	return m
+}
+
+func TestMAPSolvers(t *testing.T) {
+	for _, S := range []SPN{selectiveSPN(), mixtureSPN(), lazyDecode(t, selectiveSPN())} {
+		for _, I := range []VarSet{{}, {0: 1}, {1: 0}} {
+			m := bruteMPE(S, I)
+			X, v := ExactMPE{}.MAP(S, I)
+			if math.Abs(v-m) > 1e-12 || math.Abs(Inference(S, X)-v) > 1e-12 {
+				t.Errorf("Expected exact MPE value %f, got %f.", m, v)
+			}
+			for k, u := range I {
+				if X[k] != u {
+					t.Errorf("Expected evidence X_%d=%d to be kept, got %d.", k, u, X[k])
+				}
+			}
+			_, mp := MaxProduct{}.MAP(S, I)
+			_, amp := ArgMaxProduct{}.MAP(S, I)
+			_, ls := LocalSearch{}.MAP(S, I)
+			if amp < mp || ls < mp || amp > m || ls > m {
+				t.Errorf("Expected %f <= {%f (ArgMaxProduct), %f (LocalSearch)} <= %f.", mp, amp, ls, m)
+			}
+		}
+	}
+}
+
+func TestExactMPELimits(t *testing.T) {
+	S := mixtureSPN()
+	if _, _, err := (ExactMPE{Limit: 2}).Solve(S, VarSet{}); err == nil {
+		t.Errorf("Expected an error for a state space larger than the limit.")
+	}
+	X, v, ok := MAP(S, VarSet{})
+	if m := bruteMPE(S, VarSet{}); !ok || math.Abs(v-m) > 1e-12 || len(X) != 2 {
+		t.Errorf("Expected exact MPE value %f, got %f (exact: %v).", m, v, ok)
+	}
+	G := NewSum()
+	G.AddChildW(NewGaussianParams(0, 0, 1), 0.5)
+	G.AddChildW(NewGaussianParams(0, 3, 1), 0.5)
+	if _, _, err := (ExactMPE{}).Solve(G, VarSet{}); err == nil {
+		t.Errorf("Expected an error for a non-categorical variable.")
+	}
+	if _, _, ok := MAP(G, VarSet{}); ok {
+		t.Errorf("Expected MAP to report an approximate state.")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected ExactMPE.MAP to panic.")
+		}
+	}()
+	ExactMPE{}.MAP(G, VarSet{})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/RenatoGeh/gospn/sys"
 	"math"
+	"math/rand"
 )
 
 // Mode of a univariate distribution.
@@ -102,6 +103,23 @@ func NewCountingMultinomial(varid int, counts []int) *Multinomial {
 	return &Multinomial{Node{sc: []int{varid}}, varid, pr, Mode{mi, m}}
 }
 
+// NewMultinomialPrior constructs a new Multinomial from a count slice and a symmetric Dirichlet
+// prior with concentration alpha. The resulting distribution is the posterior mean, that is
+// Pr(X=i) = (counts[i]+alpha)/(N+k*alpha), where N is the total count and k the number of
+// categories. NewCountingMultinomial is the special case alpha=1.
+func NewMultinomialPrior(varid int, counts []int, alpha float64) *Multinomial {
+	pr := make([]float64, len(counts))
+	var s float64
+	for i, c := range counts {
+		pr[i] = float64(c) + alpha
+		s += pr[i]
+	}
+	for i := range pr {
+		pr[i] /= s
+	}
+	return NewMultinomial(varid, pr)
+}
+
 // NewScopedCountingMultinomial does the same as NewCountingMultinomial except it allows multiple
 // variable scope.
 func NewScopedCountingMultinomial(varid int, esc []int, counts []int) *Multinomial {
@@ -182,6 +200,41 @@ func (m *Multinomial) ArgMax(val VarSet) (VarSet, float64) {
 	return retval, math.Log(m.mode.val)
 }
 
+// Soft returns the probability of soft evidence L, that is ln \sum_x Pr(X=x) * L[x].
+func (m *Multinomial) Soft(L []float64) float64 {
+	var p float64
+	for x := 0; x < len(L) && x < len(m.pr); x++ {
+		p += m.pr[x] * L[x]
+	}
+	return math.Log(p)
+}
+
+// Interval returns the probability Pr(a <= X <= b), in logspace.
+func (m *Multinomial) Interval(a, b float64) float64 {
+	a, b = math.Max(math.Ceil(a), 0), math.Min(math.Floor(b), float64(len(m.pr)-1))
+	var p float64
+	for x := int(a); float64(x) <= b; x++ {
+		p += m.pr[x]
+	}
+	return math.Log(p)
+}
+
+// Sample draws a value from this distribution if the variable is not set in val.
+func (m *Multinomial) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[m.varid]; ok {
+		return
+	}
+	u := rng.Float64()
+	k := len(m.pr) - 1
+	for i, p := range m.pr {
+		if u -= p; u < 0 {
+			k = i
+			break
+		}
+	}
+	val[m.varid] = k
+}
+
 // Mean returns the mean of the distribution.
 func (m *Multinomial) Mean() float64 {
 	var mu float64
@@ -220,7 +273,7 @@ func (m *Multinomial) GobEncode() ([]byte, error) {
 	var b bytes.Buffer
 	fmt.Fprintf(&b, "%d %d", m.varid, len(m.pr))
 	for _, p := range m.pr {
-		fmt.Fprintf(&b, " %f", p)
+		fmt.Fprintf(&b, " %v", p)
 	}
 	return b.Bytes(), nil
 }
@@ -0,0 +1,258 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distmv"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// MVGaussianRidge is the value added to the diagonal of covariance matrices estimated by
+// NewMVGaussianML, so that they stay positive definite even on constant or collinear variables.
+const MVGaussianRidge = 1e-6
+
+// MVGaussian represents a multivariate Gaussian distribution with full covariance matrix over
+// continuous variables. Unobserved variables are marginalized out exactly.
+type MVGaussian struct {
+	Node
+	// Variable IDs
+	vars []int
+	// Mean vector
+	mu []float64
+	// Covariance matrix
+	sigma *mat.SymDense
+	// Gonum multivariate normal distribution
+	dist *distmv.Normal
+}
+
+// NewMVGaussian constructs a new MVGaussian over variables vars with mean vector mu and covariance
+// matrix sigma, which must be symmetric positive definite. Panics if it is not.
+func NewMVGaussian(vars []int, mu []float64, sigma [][]float64) *MVGaussian {
+	n := len(vars)
+	S := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			S.SetSym(i, j, sigma[i][j])
+		}
+	}
+	g := &MVGaussian{vars: vars, mu: mu, sigma: S}
+	g.init()
+	return g
+}
+
+// NewMVGaussianML constructs a new MVGaussian over variables vars from the maximum likelihood
+// estimate of dataset D. MVGaussianRidge is added to the diagonal of the covariance matrix.
+func NewMVGaussianML(vars []int, D DatasetF) *MVGaussian {
+	n, N := len(vars), float64(len(D))
+	mu := make([]float64, n)
+	for _, I := range D {
+		for i, v := range vars {
+			mu[i] += I[v] / N
+		}
+	}
+	sigma := make([][]float64, n)
+	for i := range sigma {
+		sigma[i] = make([]float64, n)
+	}
+	for _, I := range D {
+		for i, u := range vars {
+			for j, v := range vars[i:] {
+				sigma[i][i+j] += (I[u] - mu[i]) * (I[v] - mu[i+j]) / N
+			}
+		}
+	}
+	for i := range sigma {
+		sigma[i][i] += MVGaussianRidge
+	}
+	return NewMVGaussian(vars, mu, sigma)
+}
+
+// init computes the scope and the underlying distribution.
+func (g *MVGaussian) init() {
+	g.sc = append([]int(nil), g.vars...)
+	sort.Ints(g.sc)
+	var ok bool
+	if g.dist, ok = distmv.NewNormal(g.mu, g.sigma, nil); !ok {
+		panic("spn: multivariate gaussian covariance matrix is not positive definite")
+	}
+}
+
+// Type returns the type of this node.
+func (g *MVGaussian) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (g *MVGaussian) SubType() string { return "mvgaussian" }
+
+// split returns the indices and values of the variables set in val, and the indices of the unset
+// ones.
+func (g *MVGaussian) split(val VarSetF) ([]int, []float64, []int) {
+	var O, U []int
+	var X []float64
+	for i, v := range g.vars {
+		if x, ok := val[v]; ok {
+			O, X = append(O, i), append(X, x)
+		} else {
+			U = append(U, i)
+		}
+	}
+	return O, X, U
+}
+
+// marginal returns the log-density of the variables set in val, and the distribution of the
+// unset variables conditioned on the set ones, or nil if every variable is set.
+func (g *MVGaussian) marginal(val VarSetF) (float64, *distmv.Normal) {
+	O, X, U := g.split(val)
+	if len(O) == 0 {
+		return 0, g.dist
+	}
+	if len(U) == 0 {
+		return g.dist.LogProb(X), nil
+	}
+	M, ok := g.dist.MarginalNormal(O, nil)
+	if !ok {
+		return math.Inf(-1), nil
+	}
+	C, ok := g.dist.ConditionNormal(O, X, nil)
+	if !ok {
+		return math.Inf(-1), nil
+	}
+	return M.LogProb(X), C
+}
+
+// Value returns the density of a certain valuation, marginalizing unset variables.
+func (g *MVGaussian) Value(val VarSet) float64 { return g.ValueF(val.Continuous()) }
+
+// Max returns the MAP value given a valuation.
+func (g *MVGaussian) Max(val VarSet) float64 { return g.MaxF(val.Continuous()) }
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation. The
+// unset variables are set to their conditional mean rounded to the nearest integer, but the
+// returned value is the density at the conditional mean.
+func (g *MVGaussian) ArgMax(val VarSet) (VarSet, float64) {
+	A, v := g.ArgMaxF(val.Continuous())
+	M := make(VarSet, len(A))
+	for u, x := range A {
+		M[u] = int(math.Round(x))
+	}
+	return M, v
+}
+
+// ValueF returns the density of a certain real-valued valuation, marginalizing unset variables.
+func (g *MVGaussian) ValueF(val VarSetF) float64 {
+	v, _ := g.marginal(val)
+	return v
+}
+
+// MaxF returns the MAP value given a real-valued valuation.
+func (g *MVGaussian) MaxF(val VarSetF) float64 {
+	_, v := g.ArgMaxF(val)
+	return v
+}
+
+// ArgMaxF returns both the arguments and the value of the MAP state given a certain real-valued
+// valuation. Unset variables are set to their mean conditioned on the set ones.
+func (g *MVGaussian) ArgMaxF(val VarSetF) (VarSetF, float64) {
+	v, C := g.marginal(val)
+	A := make(VarSetF, len(g.vars))
+	_, _, U := g.split(val)
+	for _, u := range g.vars {
+		if x, ok := val[u]; ok {
+			A[u] = x
+		}
+	}
+	if C != nil {
+		m := C.Mean(nil)
+		for j, i := range U {
+			A[g.vars[i]] = m[j]
+		}
+		v += C.LogProb(m)
+	}
+	return A, v
+}
+
+// Sample draws the unset variables of val, rounded to the nearest integer, from their
+// distribution conditioned on the set ones.
+func (g *MVGaussian) Sample(val VarSet, rng *rand.Rand) {
+	F := val.Continuous()
+	_, C := g.marginal(F)
+	if C == nil {
+		return
+	}
+	Z := make([]float64, C.Dim())
+	for i := range Z {
+		Z[i] = rng.NormFloat64()
+	}
+	X := C.TransformNormal(nil, Z)
+	_, _, U := g.split(F)
+	for j, i := range U {
+		val[g.vars[i]] = int(math.Round(X[j]))
+	}
+}
+
+// Params returns the variables, the mean vector and the covariance matrix.
+func (g *MVGaussian) Params() ([]int, []float64, [][]float64) {
+	n := len(g.vars)
+	S := make([][]float64, n)
+	for i := range S {
+		S[i] = make([]float64, n)
+		for j := range S[i] {
+			S[i][j] = g.sigma.At(i, j)
+		}
+	}
+	return g.vars, g.mu, S
+}
+
+// GobEncode serializes this multivariate gaussian node. Only the upper triangle of the covariance
+// matrix is written.
+func (g *MVGaussian) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	n := len(g.vars)
+	fmt.Fprintf(&b, "%d", n)
+	for _, v := range g.vars {
+		fmt.Fprintf(&b, " %d", v)
+	}
+	for _, m := range g.mu {
+		fmt.Fprintf(&b, " %v", m)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			fmt.Fprintf(&b, " %v", g.sigma.At(i, j))
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this multivariate gaussian node.
+func (g *MVGaussian) GobDecode(data []byte) error {
+	b := bytes.NewBuffer(data)
+	var n int
+	if _, err := fmt.Fscanf(b, "%d", &n); err != nil {
+		return err
+	}
+	g.vars, g.mu = make([]int, n), make([]float64, n)
+	for i := range g.vars {
+		if _, err := fmt.Fscanf(b, " %d", &g.vars[i]); err != nil {
+			return err
+		}
+	}
+	for i := range g.mu {
+		if _, err := fmt.Fscanf(b, " %f", &g.mu[i]); err != nil {
+			return err
+		}
+	}
+	g.sigma = mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			var s float64
+			if _, err := fmt.Fscanf(b, " %f", &s); err != nil {
+				return err
+			}
+			g.sigma.SetSym(i, j, s)
+		}
+	}
+	g.init()
+	return nil
+}
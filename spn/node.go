@@ -1,15 +1,22 @@
 package spn
 
 import (
+	"sync/atomic"
+
 	"github.com/RenatoGeh/gospn/learn/parameters"
 )
 
+// lastID is the last node ID assigned.
+var lastID uint64
+
 // Node represents a node in an SPN.
 type Node struct {
 	// Children nodes.
 	ch []SPN
 	// Scope of this node.
 	sc []int
+	// Stable identifier of this node. Zero means not yet assigned.
+	id uint64
 }
 
 // An SPN is a node.
@@ -36,9 +43,13 @@ type SPN interface {
 	Height() int
 	// Parameters returns the parameters of this object.
 	Parameters() *parameters.P
+	// ID returns the stable identifier of this node.
+	ID() int
 
 	rawSc() []int
 	setRawSc([]int)
+	setCh([]SPN)
+	setID(int)
 }
 
 func (n *Node) rawSc() []int {
@@ -49,12 +60,53 @@ func (n *Node) setRawSc(sc []int) {
 	n.sc = sc
 }
 
+func (n *Node) setCh(ch []SPN) {
+	n.ch = ch
+}
+
+// ID returns the stable identifier of this node. IDs are positive, assigned on first use and
+// unique within a graph. Once assigned, a node's ID never changes, and is preserved by Marshal and
+// Unmarshal. Loading the same model twice thus yields two graphs with the same IDs, and so graphs
+// loaded separately should not be mixed.
+func (n *Node) ID() int {
+	if id := atomic.LoadUint64(&n.id); id != 0 {
+		return int(id)
+	}
+	atomic.CompareAndSwapUint64(&n.id, 0, atomic.AddUint64(&lastID, 1))
+	return int(atomic.LoadUint64(&n.id))
+}
+
+// setID sets the ID of this node, making sure newly assigned IDs do not collide with it.
+func (n *Node) setID(id int) {
+	atomic.StoreUint64(&n.id, uint64(id))
+	for {
+		l := atomic.LoadUint64(&lastID)
+		if l >= uint64(id) || atomic.CompareAndSwapUint64(&lastID, l, uint64(id)) {
+			return
+		}
+	}
+}
+
+// SetID sets the ID of node S. It is meant for decoders that must preserve IDs across save/load
+// cycles, and should not be used otherwise, as IDs are expected to be unique within a graph (see
+// Index).
+func SetID(S SPN, id int) {
+	S.setID(id)
+}
+
 // VarSet is a variable set specifying variables and their respective instantiations.
 type VarSet map[int]int
 
 // Dataset is a dataset indexed by instances.
 type Dataset []map[int]int
 
+// VarSetF is a variable set specifying variables and their respective real-valued
+// instantiations. It is the continuous counterpart of VarSet (see LeafF).
+type VarSetF map[int]float64
+
+// DatasetF is a real-valued dataset indexed by instances.
+type DatasetF []map[int]float64
+
 // Value returns the value of this node given an instantiation. (virtual)
 func (n *Node) Value(val VarSet) float64 {
 	return -1
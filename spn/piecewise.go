@@ -0,0 +1,252 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// PiecewiseLinear represents a piecewise-linear density over a continuous variable. The density
+// is given by knots x_0 < x_1 < ... < x_m with densities y_0, y_1, ..., y_m, linearly interpolated
+// between consecutive knots and zero outside [x_0, x_m]. Densities are normalized so that the
+// area under the curve is one.
+type PiecewiseLinear struct {
+	Node
+	// Variable ID
+	varid int
+	// Knots
+	x []float64
+	// Densities at each knot
+	y []float64
+	// Index of the knot with highest density
+	mode int
+}
+
+// NewPiecewiseLinear constructs a new PiecewiseLinear with knots x and densities y, normalized so
+// that the density integrates to one.
+func NewPiecewiseLinear(varid int, x, y []float64) *PiecewiseLinear {
+	p := &PiecewiseLinear{Node{sc: []int{varid}}, varid, x, nil, 0}
+	p.SetDensities(y)
+	return p
+}
+
+// NewPiecewiseLinearML constructs a new PiecewiseLinear from data X by smoothing a histogram with
+// bin edges edges (see NewHistogramML). Knots are placed at the center of each bin, with the
+// density of that bin, and at the outermost edges, with the density of the outermost bins.
+func NewPiecewiseLinearML(varid int, edges, X []float64) *PiecewiseLinear {
+	return NewPiecewiseLinearHistogram(NewHistogramML(varid, edges, X))
+}
+
+// NewPiecewiseLinearHistogram constructs a new PiecewiseLinear interpolating the densities of
+// histogram h at the center of its bins.
+func NewPiecewiseLinearHistogram(h *Histogram) *PiecewiseLinear {
+	m := len(h.w)
+	x, y := make([]float64, m+2), make([]float64, m+2)
+	x[0], x[m+1] = h.edges[0], h.edges[m]
+	for i := 0; i < m; i++ {
+		x[i+1] = (h.edges[i] + h.edges[i+1]) / 2
+		y[i+1] = h.w[i] / h.width(i)
+	}
+	y[0], y[m+1] = y[1], y[m]
+	return NewPiecewiseLinear(h.varid, x, y)
+}
+
+// Type returns the type of this node.
+func (p *PiecewiseLinear) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (p *PiecewiseLinear) SubType() string { return "piecewise" }
+
+// logProb returns the log-density at x.
+func (p *PiecewiseLinear) logProb(x float64) float64 {
+	m := len(p.x) - 1
+	if x < p.x[0] || x > p.x[m] || math.IsNaN(x) {
+		return math.Inf(-1)
+	}
+	i := sort.Search(m, func(i int) bool { return p.x[i+1] >= x })
+	if i == m {
+		return math.Log(p.y[m])
+	}
+	return math.Log(p.interp(i, x))
+}
+
+// interp returns the density at x as interpolated by the i-th segment.
+func (p *PiecewiseLinear) interp(i int, x float64) float64 {
+	t := (x - p.x[i]) / (p.x[i+1] - p.x[i])
+	return p.y[i] + t*(p.y[i+1]-p.y[i])
+}
+
+// Interval returns the probability Pr(a <= X <= b), in logspace. Each segment contributes the
+// area of the trapezoid under its overlap with [a, b].
+func (p *PiecewiseLinear) Interval(a, b float64) float64 {
+	var s float64
+	for i := 0; i < len(p.x)-1; i++ {
+		if l, r := math.Max(a, p.x[i]), math.Min(b, p.x[i+1]); r > l {
+			s += (p.interp(i, l) + p.interp(i, r)) * (r - l) / 2
+		}
+	}
+	return math.Log(s)
+}
+
+// Value returns the density of a certain valuation. That is p(X=val[varid]), or 1 if the variable
+// is not set.
+func (p *PiecewiseLinear) Value(val VarSet) float64 {
+	if v, ok := val[p.varid]; ok {
+		return p.logProb(float64(v))
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (p *PiecewiseLinear) Max(val VarSet) float64 {
+	if v, ok := val[p.varid]; ok {
+		return p.logProb(float64(v))
+	}
+	return math.Log(p.y[p.mode])
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation. The
+// MAP state is the knot with highest density rounded to the nearest integer, but the returned
+// value is the density at the knot.
+func (p *PiecewiseLinear) ArgMax(val VarSet) (VarSet, float64) {
+	if v, ok := val[p.varid]; ok {
+		return VarSet{p.varid: v}, p.logProb(float64(v))
+	}
+	return VarSet{p.varid: int(math.Round(p.x[p.mode]))}, math.Log(p.y[p.mode])
+}
+
+// ValueF returns the density of a certain real-valued valuation.
+func (p *PiecewiseLinear) ValueF(val VarSetF) float64 {
+	if v, ok := val[p.varid]; ok {
+		return p.logProb(v)
+	}
+	return 0
+}
+
+// MaxF returns the MAP value given a real-valued valuation.
+func (p *PiecewiseLinear) MaxF(val VarSetF) float64 {
+	if v, ok := val[p.varid]; ok {
+		return p.logProb(v)
+	}
+	return math.Log(p.y[p.mode])
+}
+
+// ArgMaxF returns both the arguments and the value of the MAP state given a certain real-valued
+// valuation. Since the density is linear between knots, the MAP state is the knot with highest
+// density.
+func (p *PiecewiseLinear) ArgMaxF(val VarSetF) (VarSetF, float64) {
+	if v, ok := val[p.varid]; ok {
+		return VarSetF{p.varid: v}, p.logProb(v)
+	}
+	return VarSetF{p.varid: p.x[p.mode]}, math.Log(p.y[p.mode])
+}
+
+// area returns the area under the i-th segment.
+func (p *PiecewiseLinear) area(i int) float64 {
+	return (p.y[i] + p.y[i+1]) * (p.x[i+1] - p.x[i]) / 2
+}
+
+// Sample draws a value from this distribution, rounded to the nearest integer, if the variable is
+// not set in val. A segment is chosen by its area, and a value is then drawn from the segment's
+// trapezoid by inverting its cumulative distribution.
+func (p *PiecewiseLinear) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[p.varid]; ok {
+		return
+	}
+	A := make([]float64, len(p.x)-1)
+	for i := range A {
+		A[i] = p.area(i)
+	}
+	i := sampleIndex(A, rng)
+	w := p.x[i+1] - p.x[i]
+	a, s := p.y[i], (p.y[i+1]-p.y[i])/w
+	u := rng.Float64() * A[i]
+	var t float64
+	if math.Abs(s) < 1e-12 {
+		if a > 0 {
+			t = u / a
+		}
+	} else {
+		t = (math.Sqrt(a*a+2*s*u) - a) / s
+	}
+	val[p.varid] = int(math.Round(p.x[i] + t))
+}
+
+// Knots returns the knots.
+func (p *PiecewiseLinear) Knots() []float64 { return p.x }
+
+// Params returns the densities at each knot.
+func (p *PiecewiseLinear) Params() []float64 { return p.y }
+
+// SetDensities sets the densities at each knot to Y, normalized so that the density integrates to
+// one.
+func (p *PiecewiseLinear) SetDensities(Y []float64) {
+	p.y = append([]float64(nil), Y...)
+	var z float64
+	for i := 0; i < len(p.x)-1; i++ {
+		z += p.area(i)
+	}
+	if z > 0 {
+		for i := range p.y {
+			p.y[i] /= z
+		}
+	}
+	p.computeMode()
+}
+
+// computeMode finds the knot with highest density.
+func (p *PiecewiseLinear) computeMode() {
+	p.mode = 0
+	for i, y := range p.y {
+		if y > p.y[p.mode] {
+			p.mode = i
+		}
+	}
+}
+
+// Sc returns the scope of this node.
+func (p *PiecewiseLinear) Sc() []int {
+	if len(p.sc) == 0 {
+		p.sc = []int{p.varid}
+	}
+	return p.sc
+}
+
+// GobEncode serializes this piecewise-linear node.
+func (p *PiecewiseLinear) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d %d", p.varid, len(p.x))
+	for _, x := range p.x {
+		fmt.Fprintf(&b, " %v", x)
+	}
+	for _, y := range p.y {
+		fmt.Fprintf(&b, " %v", y)
+	}
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this piecewise-linear node.
+func (p *PiecewiseLinear) GobDecode(data []byte) error {
+	b := bytes.NewBuffer(data)
+	var n int
+	_, err := fmt.Fscanf(b, "%d %d", &p.varid, &n)
+	if err != nil {
+		return err
+	}
+	p.sc = []int{p.varid}
+	p.x, p.y = make([]float64, n), make([]float64, n)
+	for i := range p.x {
+		if _, err = fmt.Fscanf(b, "%f", &p.x[i]); err != nil {
+			return err
+		}
+	}
+	for i := range p.y {
+		if _, err = fmt.Fscanf(b, "%f", &p.y[i]); err != nil {
+			return err
+		}
+	}
+	p.computeMode()
+	return nil
+}
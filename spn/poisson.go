@@ -0,0 +1,148 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"gonum.org/v1/gonum/mathext"
+	"math"
+	"math/rand"
+)
+
+// Poisson represents a Poisson distribution with rate lambda over a count variable.
+type Poisson struct {
+	Node
+	// Variable ID
+	varid int
+	// Rate
+	lambda float64
+}
+
+// NewPoisson constructs a new Poisson with rate lambda.
+func NewPoisson(varid int, lambda float64) *Poisson {
+	return &Poisson{Node{sc: []int{varid}}, varid, lambda}
+}
+
+// NewPoissonML constructs a new Poisson from the maximum likelihood estimate of data X, a slice of
+// non-negative counts.
+func NewPoissonML(varid int, X []int) *Poisson {
+	return NewPoisson(varid, meanInts(X))
+}
+
+// Type returns the type of this node.
+func (p *Poisson) Type() string { return "leaf" }
+
+// SubType returns this leaf's subtype.
+func (p *Poisson) SubType() string { return "poisson" }
+
+// logProb returns ln(Pr(X=x)).
+func (p *Poisson) logProb(x int) float64 {
+	if x < 0 {
+		return math.Inf(-1)
+	}
+	if x == 0 {
+		return -p.lambda
+	}
+	return float64(x)*math.Log(p.lambda) - p.lambda - logFactorial(x)
+}
+
+// Interval returns the probability Pr(a <= X <= b), in logspace. Since Pr(X >= n) = P(n, lambda)
+// for n > 0, where P is the regularized lower incomplete gamma function, the probability is
+// computed through P on the upper tail and through its complement on the lower one.
+func (p *Poisson) Interval(a, b float64) float64 {
+	a, b = math.Max(math.Ceil(a), 0), math.Floor(b)
+	if a > b {
+		return math.Inf(-1)
+	}
+	if a > p.lambda {
+		u := mathext.GammaInc(a, p.lambda)
+		if !math.IsInf(b, 1) {
+			u -= mathext.GammaInc(b+1, p.lambda)
+		}
+		return math.Log(u)
+	}
+	u := 1.0
+	if !math.IsInf(b, 1) {
+		u = mathext.GammaIncComp(b+1, p.lambda)
+	}
+	if a > 0 {
+		u -= mathext.GammaIncComp(a, p.lambda)
+	}
+	return math.Log(u)
+}
+
+// mode returns the most probable value.
+func (p *Poisson) mode() int { return int(math.Floor(p.lambda)) }
+
+// Value returns the probability of a certain valuation. That is Pr(X=val[varid]), or 1 if the
+// variable is not set.
+func (p *Poisson) Value(val VarSet) float64 {
+	if v, ok := val[p.varid]; ok {
+		return p.logProb(v)
+	}
+	return 0
+}
+
+// Max returns the MAP value given a valuation.
+func (p *Poisson) Max(val VarSet) float64 {
+	if v, ok := val[p.varid]; ok {
+		return p.logProb(v)
+	}
+	return p.logProb(p.mode())
+}
+
+// ArgMax returns both the arguments and the value of the MAP state given a certain valuation.
+func (p *Poisson) ArgMax(val VarSet) (VarSet, float64) {
+	v, ok := val[p.varid]
+	if !ok {
+		v = p.mode()
+	}
+	return VarSet{p.varid: v}, p.logProb(v)
+}
+
+// Sample draws a value from this distribution if the variable is not set in val. For rates above
+// 30, values are drawn from the normal approximation to the Poisson distribution.
+func (p *Poisson) Sample(val VarSet, rng *rand.Rand) {
+	if _, ok := val[p.varid]; ok {
+		return
+	}
+	if p.lambda > 30 {
+		k := int(math.Round(rng.NormFloat64()*math.Sqrt(p.lambda) + p.lambda))
+		if k < 0 {
+			k = 0
+		}
+		val[p.varid] = k
+		return
+	}
+	// Knuth's algorithm.
+	l, k, q := math.Exp(-p.lambda), 0, rng.Float64()
+	for q > l {
+		k++
+		q *= rng.Float64()
+	}
+	val[p.varid] = k
+}
+
+// Params returns the rate.
+func (p *Poisson) Params() float64 { return p.lambda }
+
+// Sc returns the scope of this node.
+func (p *Poisson) Sc() []int {
+	if len(p.sc) == 0 {
+		p.sc = []int{p.varid}
+	}
+	return p.sc
+}
+
+// GobEncode serializes this poisson node.
+func (p *Poisson) GobEncode() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, p.varid, p.lambda)
+	return b.Bytes(), nil
+}
+
+// GobDecode unserializes this poisson node.
+func (p *Poisson) GobDecode(data []byte) error {
+	_, err := fmt.Fscanln(bytes.NewBuffer(data), &p.varid, &p.lambda)
+	p.sc = []int{p.varid}
+	return err
+}
@@ -0,0 +1,86 @@
+package spn
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/RenatoGeh/gospn/sys"
+)
+
+// Sampler is a leaf that can be sampled from. Sample draws a value for each variable in the leaf's
+// scope that is not yet set in val, and writes it to val. Variables already set are left
+// untouched.
+type Sampler interface {
+	Sample(val VarSet, rng *rand.Rand)
+}
+
+// Sample draws n samples from SPN S conditioned on evidence E through top-down ancestral sampling.
+// First, an upward pass computes the value of each node given E. Then, for each sample, starting
+// from the root, Sample chooses a child of each sum node with probability proportional to its
+// weight times the child's value, recurses into all children of product nodes and samples from
+// the reached leaves (which must implement Sampler). Evidence variables keep their values in
+// every sample. When S is complete and decomposable, samples are drawn from P(X | E=e).
+//
+// Argument rng is the pseudo-random generator to be used. If rng is nil, sys.Random is used
+// instead. Passing the generator returned by sys.RefreshRandom makes sampling deterministic under
+// the given seed. If E has probability zero, Sample returns nil.
+func Sample(S SPN, E VarSet, n int, rng *rand.Rand) []VarSet {
+	if rng == nil {
+		rng = sys.Random
+	}
+	P := Compile(S)
+	V := P.Values(E, nil)
+	if math.IsInf(V[P.Root()], -1) {
+		return nil
+	}
+	R := make([]VarSet, n)
+	var T []int
+	for i := range R {
+		X := make(VarSet, len(E))
+		for k, v := range E {
+			X[k] = v
+		}
+		T = append(T[:0], P.Root())
+		for len(T) > 0 {
+			j := T[len(T)-1]
+			T = T[:len(T)-1]
+			switch P.kind[j] {
+			case kLeaf:
+				if L, ok := P.nodes[j].(Sampler); ok {
+					L.Sample(X, rng)
+				}
+			case kSum:
+				if c := P.sampleChild(j, V, rng); c >= 0 {
+					T = append(T, c)
+				}
+			case kProduct:
+				T = append(T, P.Ch(j)...)
+			}
+		}
+		R[i] = X
+	}
+	return R
+}
+
+// sampleChild chooses a child of sum node i with probability proportional to w_{i,c}*S_c, where
+// S_c is taken from V. Returns -1 if no child has positive probability.
+func (p *Plan) sampleChild(i int, V []float64, rng *rand.Rand) int {
+	a, b := p.off[i], p.off[i+1]
+	var z float64
+	for j := a; j < b; j++ {
+		z += math.Exp(p.lw[j] + V[p.ch[j]] - V[i])
+	}
+	u := rng.Float64() * z
+	c := -1
+	for j := a; j < b; j++ {
+		w := math.Exp(p.lw[j] + V[p.ch[j]] - V[i])
+		if w <= 0 {
+			continue
+		}
+		c = p.ch[j]
+		if u -= w; u < 0 {
+			break
+		}
+	}
+	return c
+}
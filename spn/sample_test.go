@@ -0,0 +1,55 @@
+package spn
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func mixtureSPN() SPN {
+	R := NewSum()
+	P1, P2 := NewProduct(), NewProduct()
+	P1.AddChild(NewMultinomial(0, []float64{0.9, 0.1}))
+	P1.AddChild(NewMultinomial(1, []float64{0.2, 0.8}))
+	P2.AddChild(NewMultinomial(0, []float64{0.1, 0.9}))
+	P2.AddChild(NewMultinomial(1, []float64{0.5, 0.5}))
+	R.AddChildW(P1, 0.3)
+	R.AddChildW(P2, 0.7)
+	return R
+}
+
+func TestSampleDeterministic(t *testing.T) {
+	S := mixtureSPN()
+	A := Sample(S, VarSet{}, 50, rand.New(rand.NewSource(rSeed)))
+	B := Sample(S, VarSet{}, 50, rand.New(rand.NewSource(rSeed)))
+	if !reflect.DeepEqual(A, B) {
+		t.Error("Expected equal samples under the same seed, got different.")
+	}
+}
+
+func TestSampleConditional(t *testing.T) {
+	const n = 20000
+	S := mixtureSPN()
+	E := VarSet{1: 0}
+	X := Sample(S, E, n, rand.New(rand.NewSource(rSeed)))
+	var c int
+	for _, I := range X {
+		if I[1] != 0 {
+			t.Fatalf("Expected evidence to be kept, got %v.", I)
+		}
+		if I[0] == 0 {
+			c++
+		}
+	}
+	p := math.Exp(Inference(S, VarSet{0: 0, 1: 0}) - Inference(S, E))
+	if q := float64(c) / n; math.Abs(p-q) > 0.02 {
+		t.Errorf("Expected P(X_0=0|X_1=0) ~ %f, got %f.", p, q)
+	}
+	Z := NewProduct()
+	Z.AddChild(NewIndicator(0, 1))
+	Z.AddChild(NewMultinomial(1, []float64{0.5, 0.5}))
+	if Sample(Z, VarSet{0: 0}, 1, nil) != nil {
+		t.Error("Expected nil samples on zero probability evidence.")
+	}
+}
@@ -0,0 +1,151 @@
+package spn
+
+// MaxSupportBoxes is the maximum number of boxes used to represent the support of a node in
+// Selectivity. When a support needs more boxes than this, it is over-approximated by its bounding
+// box.
+var MaxSupportBoxes = 64
+
+// A box is a set of states given by a conjunction of per-variable constraints: each variable in
+// the box may only take the values in its set. Variables not in the box may take any value.
+type box map[int]map[int]bool
+
+// A support is a disjunction of boxes.
+type support []box
+
+// Selectivity decides whether each sum node of S is selective, that is, whether for every state
+// at most one child of the sum node has a non-zero value. An SPN is selective (also called
+// deterministic) when all of its sum nodes are selective.
+//
+// Supports (the set of states with non-zero value) are propagated bottom-up. The support of a
+// spn.Multinomial leaf is the set of categories with non-zero probability, and the support of a
+// spn.Indicator is its indicated value. Any other leaf is assumed to have full support. The
+// support of a product node is the intersection of its children's supports, and the support of a
+// sum node is the union. Supports are represented as unions of at most MaxSupportBoxes boxes and
+// are over-approximated when this limit is exceeded. As such, Selectivity never marks a
+// non-selective sum node as selective, but may fail to recognize some selective ones.
+//
+// Returns a map with the verdict for each sum node and whether S as a whole is selective.
+func Selectivity(S SPN) (map[SPN]bool, bool) {
+	P := Compile(S)
+	U := make([]support, P.Len())
+	R := make(map[SPN]bool)
+	ok := true
+	for i, Z := range P.nodes {
+		switch P.kind[i] {
+		case kLeaf:
+			U[i] = support{leafBox(Z)}
+		case kProduct:
+			T := support{box{}}
+			for _, c := range P.Ch(i) {
+				var N support
+				for _, a := range T {
+					for _, b := range U[c] {
+						if x := a.intersect(b); x != nil {
+							N = append(N, x)
+						}
+					}
+				}
+				T = N.bound()
+			}
+			U[i] = T
+		case kSum:
+			ch := P.Ch(i)
+			sel := true
+			for a := 0; a < len(ch) && sel; a++ {
+				for b := a + 1; b < len(ch) && sel; b++ {
+					sel = U[ch[a]].disjoint(U[ch[b]])
+				}
+			}
+			R[Z] = sel
+			ok = ok && sel
+			var T support
+			for _, c := range ch {
+				T = append(T, U[c]...)
+			}
+			U[i] = T.bound()
+		}
+	}
+	return R, ok
+}
+
+// leafBox returns the support of a leaf.
+func leafBox(L SPN) box {
+	switch t := unlazy(L).(type) {
+	case *Multinomial:
+		A := make(map[int]bool)
+		for v, p := range t.pr {
+			if p > 0 {
+				A[v] = true
+			}
+		}
+		return box{t.varid: A}
+	case *Indicator:
+		return box{t.varid: {t.v: true}}
+	}
+	return box{}
+}
+
+// intersect returns the intersection of boxes a and b, or nil if it is empty.
+func (a box) intersect(b box) box {
+	x := make(box)
+	for k, A := range a {
+		x[k] = A
+	}
+	for k, B := range b {
+		A, e := x[k]
+		if !e {
+			x[k] = B
+			continue
+		}
+		C := make(map[int]bool)
+		for v := range A {
+			if B[v] {
+				C[v] = true
+			}
+		}
+		if len(C) == 0 {
+			return nil
+		}
+		x[k] = C
+	}
+	return x
+}
+
+// disjoint returns whether no state is in both supports.
+func (s support) disjoint(t support) bool {
+	for _, a := range s {
+		for _, b := range t {
+			if a.intersect(b) != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bound returns s if it has at most MaxSupportBoxes boxes. Otherwise it returns the bounding box
+// of s, in which each variable may take any value allowed by at least one box.
+func (s support) bound() support {
+	if len(s) <= MaxSupportBoxes {
+		return s
+	}
+	x := make(box)
+	for k := range s[0] {
+		A := make(map[int]bool)
+		full := false
+		for _, b := range s {
+			B, e := b[k]
+			if !e {
+				full = true
+				break
+			}
+			for v := range B {
+				A[v] = true
+			}
+		}
+		if !full {
+			x[k] = A
+		}
+	}
+	return support{x}
+}
@@ -0,0 +1,44 @@
+package spn
+
+import "testing"
+
+func TestSelectivity(t *testing.T) {
+	M, ok := Selectivity(selectiveSPN())
+	if !ok || len(M) != 1 {
+		t.Errorf("Expected selective SPN with one sum node, got %v (%d sums).", ok, len(M))
+	}
+	if _, ok := Selectivity(lazyDecode(t, selectiveSPN())); !ok {
+		t.Error("Expected lazily decoded selective SPN to be selective.")
+	}
+	if _, ok := Selectivity(mixtureSPN()); ok {
+		t.Error("Expected non-selective SPN, got selective.")
+	}
+
+	// Both sum nodes are selective.
+	R := NewSum()
+	P1, P2 := NewProduct(), NewProduct()
+	P1.AddChild(NewIndicator(0, 0))
+	P1.AddChild(NewIndicator(1, 1))
+	P2.AddChild(NewIndicator(0, 1))
+	Z := NewSum()
+	Z.AddChildW(NewIndicator(1, 0), 0.5)
+	Z.AddChildW(NewIndicator(1, 1), 0.5)
+	P2.AddChild(Z)
+	R.AddChildW(P1, 0.5)
+	R.AddChildW(P2, 0.5)
+	M, ok = Selectivity(R)
+	if !ok || !M[R] || !M[Z] {
+		t.Errorf("Expected every sum node to be selective, got %v.", M)
+	}
+
+	// P1 and P3 overlap at X_0=0, X_1=1.
+	P3 := NewProduct()
+	P3.AddChild(NewIndicator(0, 0))
+	P3.AddChild(Z)
+	T := NewSum()
+	T.AddChildW(P1, 0.5)
+	T.AddChildW(P3, 0.5)
+	if M, ok = Selectivity(T); ok || M[T] || !M[Z] {
+		t.Errorf("Expected non-selective root and selective child, got %v.", M)
+	}
+}
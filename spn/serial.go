@@ -1,93 +1,408 @@
 package spn
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"reflect"
 )
 
+// Binary format versions.
+const (
+	// SerialVersion is the version of the binary format written by Encode.
+	SerialVersion = 1
+)
+
+// serialMagic identifies files written by Encode. Files written by the legacy (unversioned) Marshal
+// start with their node count as a little endian uint32, and so would only start with serialMagic
+// if they had 0x4E505389 (about 1.3 billion) nodes.
+var serialMagic = [4]byte{0x89, 'S', 'P', 'N'}
+
+// ErrChecksum is returned by Decode when the checksum of the encoded SPN does not match.
+var ErrChecksum = errors.New("spn: checksum mismatch")
+
+// subtypes maps leaf subtypes to their registered concrete types.
+var subtypes = make(map[string]reflect.Type)
+
 func init() {
 	gob.Register(&Sum{})
 	gob.Register(&Product{})
-	gob.Register(&Gaussian{})
-	gob.Register(&Multinomial{})
-	gob.Register(&Indicator{})
+	RegisterGobType(&Gaussian{})
+	RegisterGobType(&DiscreteGaussian{})
+	RegisterGobType(&Multinomial{})
+	RegisterGobType(&Indicator{})
+	RegisterGobType(&Bernoulli{})
+	RegisterGobType(&Binomial{})
+	RegisterGobType(&Poisson{})
+	RegisterGobType(&Geometric{})
+	RegisterGobType(&Exponential{})
+	RegisterGobType(&Gamma{})
+	RegisterGobType(&LogNormal{})
+	RegisterGobType(&Histogram{})
+	RegisterGobType(&PiecewiseLinear{})
+	RegisterGobType(&ChowLiu{})
+	RegisterGobType(&MVGaussian{})
 	gob.Register([][]uint32{})
 }
 
 // RegisterGobType registers a new SPN type to be marshalled. If you want to create a new SPN type
 // and be able to serialize it, you must implement interfaces GobEncoder and GobDecoder as well as
 // call this function with a pointer to a concrete type (e.g. RegisterGobType(&NewSPNType{})). All
-// basic SPN nodes are already registered.
+// basic SPN nodes are already registered. Leaf types are also registered by subtype (see
+// NewOfSubType).
 func RegisterGobType(t interface{}) {
 	gob.Register(t)
+	if L, ok := t.(SPN); ok && L.Type() == "leaf" {
+		if T := reflect.TypeOf(t); T.Kind() == reflect.Ptr {
+			subtypes[L.SubType()] = T.Elem()
+		}
+	}
 }
 
-func encodeSPN(enc *gob.Encoder, S SPN) {
-	if err := enc.Encode(&S); err != nil {
-		panic(err)
+// NewOfSubType returns a new zero valued leaf of the type registered (through RegisterGobType)
+// under the given subtype, and whether such a type exists. The returned leaf is meant to be filled
+// in by its GobDecode method.
+func NewOfSubType(subtype string) (SPN, bool) {
+	T, e := subtypes[subtype]
+	if !e {
+		return nil, false
 	}
+	return reflect.New(T).Interface().(SPN), true
+}
+
+func encodeSPN(enc *gob.Encoder, S SPN) error {
+	return enc.Encode(&S)
 }
 
-func decodeSPN(dec *gob.Decoder) SPN {
+func decodeSPN(dec *gob.Decoder) (SPN, error) {
 	var S SPN
-	if err := dec.Decode(&S); err != nil {
-		panic(err)
-	}
-	return S
+	err := dec.Decode(&S)
+	return S, err
 }
 
-func Marshal(S SPN) []byte {
-	var net bytes.Buffer
-	enc := gob.NewEncoder(&net)
+// nodeRecord is a node as stored by Encode.
+type nodeRecord struct {
+	// Kind is either sum, product or the subtype of a leaf.
+	Kind string
+	// ID of the node.
+	ID int
+	// Scope of leaves.
+	Sc []int
+	// Data is the GobEncode output of the node.
+	Data []byte
+	// Ch holds the indices of the node's children.
+	Ch []uint32
+}
+
+// crcWriter writes to w while computing the checksum of what has been written.
+type crcWriter struct {
+	w   io.Writer
+	crc hash.Hash32
+}
+
+func (c *crcWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+// crcReader reads from r while computing the checksum of what has been read. It implements
+// io.ByteReader so that gob never reads ahead of what it needs.
+type crcReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	b   [1]byte
+}
 
+func (c *crcReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+func (c *crcReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.b[0] = b
+		c.crc.Write(c.b[:])
+	}
+	return b, err
+}
+
+// Encode writes SPN S to w in a versioned binary format, preserving node IDs. The format consists
+// of a magic number, the format version (SerialVersion), a gob stream with the number of nodes
+// followed by one record per node in dependency order (children first), and a CRC-32 (IEEE)
+// checksum of the gob stream. Each record holds the node's kind, ID, scope (for leaves), GobEncode
+// output and children indices. Weights and leaf parameters of the builtin node types are stored
+// with full float64 precision. Records are written as the graph is traversed, so that Encode does
+// not keep a copy of the serialized model in memory.
+func Encode(w io.Writer, S SPN) error {
+	var O []SPN
 	M := make(map[SPN]uint32)
-	var list [][]uint32
-	var n uint32
 	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
-		list = append(list, []uint32{})
-		M[Z] = n
+		M[Z] = uint32(len(O))
+		O = append(O, Z)
+		return true
+	})
+	var h [6]byte
+	copy(h[:4], serialMagic[:])
+	binary.LittleEndian.PutUint16(h[4:], SerialVersion)
+	if _, err := w.Write(h[:]); err != nil {
+		return err
+	}
+	cw := &crcWriter{w, crc32.NewIEEE()}
+	enc := gob.NewEncoder(cw)
+	if err := enc.Encode(uint32(len(O))); err != nil {
+		return err
+	}
+	for _, Z := range O {
+		r := nodeRecord{ID: Z.ID()}
+		switch Z.Type() {
+		case "sum", "product":
+			r.Kind = Z.Type()
+		default:
+			r.Kind = Z.SubType()
+			r.Sc = Z.Sc()
+		}
+		e, ok := Z.(gob.GobEncoder)
+		if !ok {
+			return fmt.Errorf("spn: node %d (%s) does not implement GobEncoder", r.ID, r.Kind)
+		}
+		var err error
+		if r.Data, err = e.GobEncode(); err != nil {
+			return fmt.Errorf("spn: encoding node %d (%s): %v", r.ID, r.Kind, err)
+		}
 		// Invariant: because of topological order, any child of Z has already been visited.
 		for _, c := range Z.Ch() {
-			p := M[c]
-			list[n] = append(list[n], p)
+			r.Ch = append(r.Ch, M[c])
 		}
-		enc.Encode(n)
-		encodeSPN(enc, Z)
-		n++
-		return true
-	})
-	enc.Encode(list)
+		if err := enc.Encode(&r); err != nil {
+			return err
+		}
+	}
+	var c [4]byte
+	binary.LittleEndian.PutUint32(c[:], cw.crc.Sum32())
+	_, err := w.Write(c[:])
+	return err
+}
 
-	nb := make([]byte, 4)
-	binary.LittleEndian.PutUint32(nb, n)
+// DecodeOptions configures DecodeWith.
+type DecodeOptions struct {
+	// Progress, if not nil, is called after each node is decoded, with the number of nodes decoded
+	// so far and the total number of nodes. The legacy format reports progress only once done.
+	Progress func(done, total int)
+	// Lazy defers decoding of leaf parameters until a leaf is first used. Leaves are then returned
+	// as *LazyLeaf proxies (see LazyLeaf and Materialize). Not honored by the legacy format.
+	Lazy bool
+}
 
-	return append(nb, net.Bytes()...)
+// Decode reads an SPN written by Encode from r, preserving node IDs. It is equivalent to
+// DecodeWith(r, DecodeOptions{}).
+func Decode(r io.Reader) (SPN, error) {
+	return DecodeWith(r, DecodeOptions{})
 }
 
-func Unmarshal(buffer []byte) SPN {
-	net := bytes.NewBuffer(buffer[4:])
-	dec := gob.NewDecoder(net)
+// DecodeWith reads an SPN written by Encode from r, preserving node IDs. The graph is built
+// incrementally as records are read, so that memory usage is bounded by the size of the graph
+// itself, not of the serialized data. DecodeWith also reads SPNs written by the legacy
+// (unversioned) Marshal. An error is returned if the data is malformed,
+// if the version is not supported, if a leaf subtype is not registered (see RegisterGobType) or if
+// the checksum does not match (ErrChecksum).
+func DecodeWith(r io.Reader, opts DecodeOptions) (SPN, error) {
+	br := bufio.NewReader(r)
+	var m [4]byte
+	if _, err := io.ReadFull(br, m[:]); err != nil {
+		return nil, fmt.Errorf("spn: reading header: %v", err)
+	}
+	var S SPN
+	var err error
+	var k int
+	if m != serialMagic {
+		rest, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		S, k, err = decodeLegacy(append(m[:], rest...))
+		if err == nil && opts.Progress != nil {
+			opts.Progress(k, k)
+		}
+		return S, err
+	}
+	var h [2]byte
+	if _, err := io.ReadFull(br, h[:]); err != nil {
+		return nil, fmt.Errorf("spn: reading header: %v", err)
+	}
+	if v := binary.LittleEndian.Uint16(h[:]); v != SerialVersion {
+		return nil, fmt.Errorf("spn: unsupported format version %d", v)
+	}
+	cr := &crcReader{r: br, crc: crc32.NewIEEE()}
+	S, err = decodeRecords(gob.NewDecoder(cr), opts)
+	if err != nil {
+		return nil, err
+	}
+	var c [4]byte
+	if _, err := io.ReadFull(br, c[:]); err != nil {
+		return nil, fmt.Errorf("spn: reading checksum: %v", err)
+	}
+	if binary.LittleEndian.Uint32(c[:]) != cr.crc.Sum32() {
+		return nil, ErrChecksum
+	}
+	return S, nil
+}
 
-	n := binary.LittleEndian.Uint32(buffer[:4])
-	M := make([]SPN, n)
-	for i := uint32(0); i < n; i++ {
-		var k uint32
-		dec.Decode(&k)
-		Z := decodeSPN(dec)
-		M[k] = Z
+// decodeRecords decodes the node count and node records from dec.
+func decodeRecords(dec *gob.Decoder, opts DecodeOptions) (SPN, error) {
+	var k uint32
+	if err := dec.Decode(&k); err != nil {
+		return nil, fmt.Errorf("spn: decoding node count: %v", err)
+	}
+	if k == 0 {
+		return nil, errors.New("spn: no nodes")
+	}
+	var M []SPN
+	for i := 0; i < int(k); i++ {
+		var r nodeRecord
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("spn: decoding node %d: %v", i, err)
+		}
+		Z, err := decodeRecord(&r, opts.Lazy)
+		if err != nil {
+			return nil, fmt.Errorf("spn: decoding node %d (%s): %v", r.ID, r.Kind, err)
+		}
+		for _, c := range r.Ch {
+			// Invariant: children come before their parents.
+			if int(c) >= i {
+				return nil, fmt.Errorf("spn: node %d has invalid child %d", r.ID, c)
+			}
+			Z.AddChild(M[c])
+		}
+		Z.setID(r.ID)
+		M = append(M, Z)
+		if opts.Progress != nil {
+			opts.Progress(i+1, int(k))
+		}
+	}
+	// Invariant: topological sort guarantees last guy is root
+	return M[k-1], nil
+}
+
+// decodeRecord builds the node described by record r.
+func decodeRecord(r *nodeRecord, lazy bool) (SPN, error) {
+	var Z SPN
+	switch r.Kind {
+	case "sum":
+		Z = NewSum()
+	case "product":
+		return NewProduct(), nil
+	default:
+		if _, e := subtypes[r.Kind]; !e {
+			return nil, errors.New("unregistered leaf subtype")
+		}
+		if lazy {
+			return &LazyLeaf{Node: Node{sc: r.Sc}, subtype: r.Kind, data: r.Data}, nil
+		}
+		Z, _ = NewOfSubType(r.Kind)
+	}
+	d, ok := Z.(gob.GobDecoder)
+	if !ok {
+		return nil, errors.New("GobDecoder not implemented")
+	}
+	return Z, d.GobDecode(r.Data)
+}
+
+// decodeNodes decodes k gob encoded nodes, each preceded by its index, their children and
+// (optionally) their IDs from dec, as written by the legacy Marshal.
+func decodeNodes(dec *gob.Decoder, k uint32) (SPN, error) {
+	if k == 0 {
+		return nil, errors.New("spn: no nodes")
+	}
+	var M []SPN
+	for i := uint32(0); i < k; i++ {
+		var j uint32
+		if err := dec.Decode(&j); err != nil {
+			return nil, fmt.Errorf("spn: decoding node index: %v", err)
+		}
+		if j != i {
+			return nil, fmt.Errorf("spn: expected node index %d, got %d", i, j)
+		}
+		Z, err := decodeSPN(dec)
+		if err != nil {
+			return nil, fmt.Errorf("spn: decoding node %d: %v", i, err)
+		}
+		if Z == nil {
+			return nil, fmt.Errorf("spn: node %d is nil", i)
+		}
+		M = append(M, Z)
 	}
 
 	var list [][]uint32
-	dec.Decode(&list)
+	if err := dec.Decode(&list); err != nil {
+		return nil, fmt.Errorf("spn: decoding children: %v", err)
+	}
+	if len(list) != len(M) {
+		return nil, fmt.Errorf("spn: %d children lists for %d nodes", len(list), len(M))
+	}
 	for i, ch := range list {
 		S := M[i]
 		for _, c := range ch {
-			Z := M[c]
-			S.AddChild(Z)
+			// Invariant: children come before their parents.
+			if int(c) >= i {
+				return nil, fmt.Errorf("spn: node %d has invalid child %d", i, c)
+			}
+			S.AddChild(M[c])
+		}
+	}
+
+	var ids []int
+	if err := dec.Decode(&ids); err != nil {
+		// Node IDs are absent in files written before IDs were introduced.
+		if err != io.EOF {
+			return nil, fmt.Errorf("spn: decoding IDs: %v", err)
 		}
+	} else if len(ids) != len(M) {
+		return nil, fmt.Errorf("spn: %d IDs for %d nodes", len(ids), len(M))
+	}
+	for i, id := range ids {
+		M[i].setID(id)
 	}
 
 	// Invariant: topological sort guarantees last guy is root
-	return M[n-1]
+	return M[k-1], nil
+}
+
+// decodeLegacy decodes data written by the legacy (unversioned) Marshal: the node count as a
+// little endian uint32 followed by a gob stream.
+func decodeLegacy(data []byte) (SPN, int, error) {
+	if len(data) < 4 {
+		return nil, 0, errors.New("spn: data too short")
+	}
+	k := binary.LittleEndian.Uint32(data[:4])
+	S, err := decodeNodes(gob.NewDecoder(bytes.NewReader(data[4:])), k)
+	return S, int(k), err
+}
+
+// Marshal serializes SPN S into a slice of bytes with Encode. It panics on failure.
+func Marshal(S SPN) []byte {
+	var b bytes.Buffer
+	if err := Encode(&b, S); err != nil {
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+// Unmarshal unserializes an SPN serialized by Marshal (or by its legacy version) with Decode. It
+// panics on failure.
+func Unmarshal(buffer []byte) SPN {
+	S, err := Decode(bytes.NewReader(buffer))
+	if err != nil {
+		panic(err)
+	}
+	return S
 }
@@ -0,0 +1,161 @@
+package spn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"testing"
+)
+
+// lazyDecode returns a copy of S decoded with lazy leaves.
+func lazyDecode(t *testing.T, S SPN) SPN {
+	T, err := DecodeWith(bytes.NewReader(Marshal(S)), DecodeOptions{Lazy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return T
+}
+
+// marshalLegacy serializes S in the unversioned format written by older versions of Marshal.
+func marshalLegacy(S SPN, ids bool) []byte {
+	var net bytes.Buffer
+	enc := gob.NewEncoder(&net)
+	M := make(map[SPN]uint32)
+	var list [][]uint32
+	var I []int
+	var n uint32
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		list = append(list, []uint32{})
+		I = append(I, Z.ID())
+		M[Z] = n
+		for _, c := range Z.Ch() {
+			list[n] = append(list[n], M[c])
+		}
+		enc.Encode(n)
+		encodeSPN(enc, Z)
+		n++
+		return true
+	})
+	enc.Encode(list)
+	if ids {
+		enc.Encode(I)
+	}
+	nb := make([]byte, 4)
+	binary.LittleEndian.PutUint32(nb, n)
+	return append(nb, net.Bytes()...)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	S := sampleSPN()
+	S.(*Sum).w[0], S.(*Sum).w[1] = 0.1234567891234, 1-0.1234567891234
+	var b bytes.Buffer
+	if err := Encode(&b, S); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	T, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(S, T, 0) {
+		t.Errorf("Expected full precision round trip.")
+	}
+	if S.ID() != T.ID() {
+		t.Errorf("Expected root ID %d, got %d.", S.ID(), T.ID())
+	}
+	// Corrupted payload.
+	C := append([]byte(nil), data...)
+	C[len(C)/2] ^= 0xff
+	if _, err := Decode(bytes.NewReader(C)); err == nil {
+		t.Errorf("Expected an error on corrupted data.")
+	}
+	// Corrupted checksum.
+	C = append([]byte(nil), data...)
+	C[len(C)-1] ^= 0xff
+	if _, err := Decode(bytes.NewReader(C)); err != ErrChecksum {
+		t.Errorf("Expected ErrChecksum, got %v.", err)
+	}
+	// Unsupported version.
+	C = append([]byte(nil), data...)
+	C[4] = 0xff
+	if _, err := Decode(bytes.NewReader(C)); err == nil {
+		t.Errorf("Expected an error on unsupported version.")
+	}
+	// Truncated data.
+	for _, k := range []int{0, 3, 10, len(data) / 2, len(data) - 1} {
+		if _, err := Decode(bytes.NewReader(data[:k])); err == nil {
+			t.Errorf("Expected an error on data truncated to %d bytes.", k)
+		}
+	}
+}
+
+func TestDecodeLegacy(t *testing.T) {
+	S := sampleSPN()
+	for _, ids := range []bool{true, false} {
+		T, err := Decode(bytes.NewReader(marshalLegacy(S, ids)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Equal(S, T, 1e-6) {
+			t.Errorf("Expected legacy data to be decoded.")
+		}
+		if ids != (S.ID() == T.ID()) {
+			t.Errorf("Expected IDs to be preserved only if present.")
+		}
+	}
+}
+
+func TestDecodeWith(t *testing.T) {
+	S := sampleSPN()
+	data := Marshal(S)
+	var calls, last, total int
+	T, err := DecodeWith(bytes.NewReader(data), DecodeOptions{
+		Progress: func(done, n int) { calls, last, total = calls+1, done, n },
+		Lazy:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countNodes(S); calls != n || last != n || total != n {
+		t.Errorf("Expected %d progress calls up to %d, got %d calls up to %d/%d.", n, n, calls, last,
+			total)
+	}
+	var L []*LazyLeaf
+	TopSortTarjanFunc(T, nil, func(Z SPN) bool {
+		if l, ok := Z.(*LazyLeaf); ok {
+			L = append(L, l)
+		}
+		return true
+	})
+	if len(L) != 6 {
+		t.Fatalf("Expected 6 lazy leaves, got %d.", len(L))
+	}
+	// Re-encoding does not decode leaves.
+	if !bytes.Equal(Marshal(T), data) {
+		t.Errorf("Expected lazy SPN to encode to the same data.")
+	}
+	for _, l := range L {
+		if l.Decoded() {
+			t.Errorf("Expected leaf %d not to be decoded yet.", l.ID())
+		}
+	}
+	sameValues(t, S, T, allInstances())
+	for _, l := range L {
+		if !l.Decoded() {
+			t.Errorf("Expected leaf %d to be decoded after use.", l.ID())
+		}
+	}
+	U, err := Materialize(T)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(S, U, 0) {
+		t.Errorf("Expected materialized SPN to be equal to the original.")
+	}
+	TopSortTarjanFunc(U, nil, func(Z SPN) bool {
+		if _, ok := Z.(*LazyLeaf); ok {
+			t.Errorf("Expected no lazy leaves after Materialize.")
+		}
+		return true
+	})
+}
@@ -0,0 +1,203 @@
+package spn
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Statistics is a summary of the structure of an SPN. It can be printed as text (see String) or
+// encoded as JSON, and is mostly useful for comparing models learned with different parameters.
+type Statistics struct {
+	// Nodes is the number of nodes.
+	Nodes int `json:"nodes"`
+	// Types counts nodes by type (sum, product, leaf).
+	Types map[string]int `json:"types"`
+	// Leaves counts leaves by subtype (e.g. multinomial, gaussian, indicator).
+	Leaves map[string]int `json:"leaves"`
+	// Edges is the number of edges.
+	Edges int `json:"edges"`
+	// Params is the number of free parameters: sum weights plus the parameters of the leaves of
+	// this package. Parameters that must sum to one (e.g. the weights of a sum or the probabilities
+	// of a multinomial) count as one less than their number. Indicators have no parameters, the
+	// number of trials of a binomial is not counted, and leaves of types unknown to this package
+	// are not accounted for.
+	Params int `json:"params"`
+	// Depth is the length of the longest path from the root to a leaf.
+	Depth int `json:"depth"`
+	// MaxFanIn and MeanFanIn are the maximum and mean number of parents over all non-root nodes.
+	MaxFanIn  int     `json:"max_fan_in"`
+	MeanFanIn float64 `json:"mean_fan_in"`
+	// MaxFanOut and MeanFanOut are the maximum and mean number of children over all inner nodes.
+	MaxFanOut  int     `json:"max_fan_out"`
+	MeanFanOut float64 `json:"mean_fan_out"`
+	// Shared is the number of nodes with more than one parent.
+	Shared int `json:"shared"`
+	// Scopes holds, for each type, a histogram of scope sizes (scope size -> number of nodes).
+	Scopes map[string]map[int]int `json:"scopes"`
+}
+
+// Stats computes the Statistics of SPN S.
+func Stats(S SPN) *Statistics {
+	st := &Statistics{
+		Types:  make(map[string]int),
+		Leaves: make(map[string]int),
+		Scopes: make(map[string]map[int]int),
+	}
+	pa := make(map[SPN]int)
+	// Scopes are computed from the leaves, as inner node scopes may not have been computed yet.
+	sc := make(map[SPN][]int)
+	// Longest path from each node to a leaf.
+	h := make(map[SPN]int)
+	var inner int
+	TopSortTarjanFunc(S, nil, func(Z SPN) bool {
+		t := Z.Type()
+		ch := Z.Ch()
+		st.Nodes++
+		st.Types[t]++
+		st.Edges += len(ch)
+		if st.Scopes[t] == nil {
+			st.Scopes[t] = make(map[int]int)
+		}
+		if len(ch) == 0 {
+			sc[Z] = Z.Sc()
+		} else {
+			sc[Z] = unionScopes(ch, sc)
+		}
+		st.Scopes[t][len(sc[Z])]++
+		if len(ch) == 0 {
+			st.Leaves[Z.SubType()]++
+			st.Params += leafParams(Z)
+		} else {
+			inner++
+			if len(ch) > st.MaxFanOut {
+				st.MaxFanOut = len(ch)
+			}
+		}
+		if s, ok := Z.(*Sum); ok {
+			st.Params += free(len(s.w))
+		}
+		for _, c := range ch {
+			pa[c]++
+			if h[c]+1 > h[Z] {
+				h[Z] = h[c] + 1
+			}
+		}
+		return true
+	})
+	st.Depth = h[S]
+	for _, n := range pa {
+		if n > st.MaxFanIn {
+			st.MaxFanIn = n
+		}
+		if n > 1 {
+			st.Shared++
+		}
+	}
+	if len(pa) > 0 {
+		st.MeanFanIn = float64(st.Edges) / float64(len(pa))
+	}
+	if inner > 0 {
+		st.MeanFanOut = float64(st.Edges) / float64(inner)
+	}
+	return st
+}
+
+// unionScopes returns the union of the scopes sc of nodes ch.
+func unionScopes(ch []SPN, sc map[SPN][]int) []int {
+	M := make(map[int]bool)
+	var U []int
+	for _, c := range ch {
+		for _, v := range sc[c] {
+			if !M[v] {
+				M[v] = true
+				U = append(U, v)
+			}
+		}
+	}
+	return U
+}
+
+// free returns the number of free parameters of a probability vector of n elements.
+func free(n int) int {
+	if n > 0 {
+		return n - 1
+	}
+	return 0
+}
+
+// leafParams returns the number of free parameters of leaf L, decoding it if it is a LazyLeaf.
+func leafParams(L SPN) int {
+	switch T := unlazy(L).(type) {
+	case *Multinomial:
+		return free(len(T.pr))
+	case *Histogram:
+		return free(len(T.w))
+	case *PiecewiseLinear:
+		// Densities are normalized so that their area is one.
+		return free(len(T.y))
+	case *ChowLiu:
+		var n int
+		for _, R := range T.pr {
+			n += len(R) * free(len(R[0]))
+		}
+		return n
+	case *MVGaussian:
+		n := len(T.vars)
+		return n + n*(n+1)/2
+	case *Gaussian, *DiscreteGaussian, *Gamma, *LogNormal:
+		return 2
+	case *Bernoulli, *Binomial, *Poisson, *Geometric, *Exponential:
+		return 1
+	}
+	return 0
+}
+
+// String returns a human-readable report of the statistics.
+func (st *Statistics) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "nodes: %d (%s)\n", st.Nodes, countsString(st.Types))
+	fmt.Fprintf(&b, "leaves: %s\n", countsString(st.Leaves))
+	fmt.Fprintf(&b, "edges: %d\n", st.Edges)
+	fmt.Fprintf(&b, "params: %d\n", st.Params)
+	fmt.Fprintf(&b, "depth: %d\n", st.Depth)
+	fmt.Fprintf(&b, "fan-in: max %d, mean %.3f\n", st.MaxFanIn, st.MeanFanIn)
+	fmt.Fprintf(&b, "fan-out: max %d, mean %.3f\n", st.MaxFanOut, st.MeanFanOut)
+	fmt.Fprintf(&b, "shared: %d\n", st.Shared)
+	T := make([]string, 0, len(st.Scopes))
+	for t := range st.Scopes {
+		T = append(T, t)
+	}
+	sort.Strings(T)
+	for _, t := range T {
+		H := st.Scopes[t]
+		K := make([]int, 0, len(H))
+		for k := range H {
+			K = append(K, k)
+		}
+		sort.Ints(K)
+		fmt.Fprintf(&b, "scope sizes (%s):", t)
+		for _, k := range K {
+			fmt.Fprintf(&b, " %d:%d", k, H[k])
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// countsString returns counts as a sorted list of key=count pairs.
+func countsString(C map[string]int) string {
+	K := make([]string, 0, len(C))
+	for k := range C {
+		K = append(K, k)
+	}
+	sort.Strings(K)
+	var b bytes.Buffer
+	for i, k := range K {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s=%d", k, C[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package spn
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	st := Stats(sampleSPN())
+	I := []int{st.Nodes, st.Types["sum"], st.Types["product"], st.Types["leaf"],
+		st.Leaves["multinomial"], st.Edges, st.Params, st.Depth, st.MaxFanIn, st.MaxFanOut, st.Shared,
+		st.Scopes["leaf"][1], st.Scopes["sum"][1], st.Scopes["product"][3], st.Scopes["sum"][4]}
+	E := []int{13, 5, 2, 6, 6, 16, 11, 3, 2, 3, 4, 6, 4, 2, 1}
+	for i := range E {
+		if I[i] != E[i] {
+			t.Errorf("Expected %v, got %v.", E, I)
+			break
+		}
+	}
+	if math.Abs(st.MeanFanIn-16.0/12) > 1e-12 || math.Abs(st.MeanFanOut-16.0/7) > 1e-12 {
+		t.Errorf("Expected mean fan-in %v and fan-out %v, got %v and %v.", 16.0/12, 16.0/7,
+			st.MeanFanIn, st.MeanFanOut)
+	}
+	if s := st.String(); !strings.Contains(s, "nodes: 13 (leaf=6, product=2, sum=5)") ||
+		!strings.Contains(s, "scope sizes (sum): 1:4 4:1") {
+		t.Errorf("Unexpected text report:\n%s", s)
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var u Statistics
+	if err := json.Unmarshal(data, &u); err != nil || u.Scopes["product"][3] != 2 {
+		t.Errorf("Expected JSON round trip, got %v: %s", err, data)
+	}
+}
@@ -1,12 +1,10 @@
 package spn
 
 import (
-	"fmt"
 	"math"
 
 	"github.com/RenatoGeh/gospn/common"
 	"github.com/RenatoGeh/gospn/sys"
-	"github.com/RenatoGeh/gospn/utils"
 )
 
 // Some of the following functions are non-recursive versions of equivalent spn.SPN methods. They
@@ -21,9 +19,6 @@ import (
 // InferenceY returns the value of S(I, Y=y). This convenience function allows for fast computation
 // of soft inference values without having to create another VarSet for each valuation of Y.
 func InferenceY(S SPN, I VarSet, Y, y int) float64 {
-	if len(S.Ch()) == 0 {
-		return 0
-	}
 	J := map[int]int{Y: y}
 	O := common.Queue{}
 	TopSortTarjan(S, &O)
@@ -62,97 +57,27 @@ func InferenceY(S SPN, I VarSet, Y, y int) float64 {
 	return V[S]
 }
 
-// Inference simply returns the value of S(I), without storing values for later use.
+// Inference simply returns the value of S(I), without storing values for later use. Inference
+// compiles S into a Plan on each call. When evaluating many instances on the same SPN, prefer
+// compiling S once with Compile and calling Plan.Eval.
 func Inference(S SPN, I VarSet) float64 {
-	if len(S.Ch()) == 0 {
-		return 0
-	}
-	O := common.Queue{}
-	TopSortTarjan(S, &O)
-	V := make(map[SPN]float64)
-	for !O.Empty() {
-		s := O.Dequeue().(SPN)
-		switch t := s.Type(); t {
-		case "leaf":
-			V[s] = s.Value(I)
-		case "sum":
-			sum := s.(*Sum)
-			ch := sum.Ch()
-			W := sum.Weights()
-			n := len(ch)
-			vals := make([]float64, n)
-			for i, cs := range ch {
-				v := V[cs]
-				vals[i] = v + math.Log(W[i])
-			}
-			V[s] = sum.Compute(vals)
-		case "product":
-			prod := s.(*Product)
-			ch := prod.Ch()
-			n := len(ch)
-			vals := make([]float64, n)
-			for i, ch := range ch {
-				vals[i] = V[ch]
-			}
-			V[s] = prod.Compute(vals)
-		}
-	}
-	return V[S]
+	return Compile(S).Eval(I)
 }
 
 // StoreInference takes an SPN S and stores the values for an instance I on a DP table storage
 // at the position designated by the ticket tk. Returns S and the ticket used (if tk < 0,
 // StoreInference creates a new ticket).
 func StoreInference(S SPN, I VarSet, tk int, storage *Storer) (SPN, int) {
-	if len(S.Ch()) == 0 {
-		return nil, -1
-	}
-
 	if tk < 0 {
 		tk = storage.NewTicket()
 	}
 
-	// Get topological order.
-	O := common.Queue{}
-	TopSortTarjan(S, &O)
-	sys.Free()
+	P := Compile(S)
+	V := P.Values(I, nil)
 
 	table, _ := storage.Table(tk)
-	for !O.Empty() {
-		s := O.Dequeue().(SPN)
-		switch t := s.Type(); t {
-		case "leaf":
-			table.StoreSingle(s, s.Value(I))
-		case "sum":
-			sum := s.(*Sum)
-			ch := sum.Ch()
-			W := sum.Weights()
-			n := len(ch)
-			vals := make([]float64, n)
-			for i, cs := range ch {
-				v, e := table.Single(cs)
-				if !e {
-					fmt.Println("Error: The SPN graph has sums or products as leaves.")
-					sys.Free()
-					return nil, -1
-				}
-				vals[i] = v + math.Log(W[i])
-			}
-			if n == 0 {
-				table.StoreSingle(s, utils.LogZero)
-			} else {
-				table.StoreSingle(s, sum.Compute(vals))
-			}
-		case "product":
-			prod := s.(*Product)
-			ch := prod.Ch()
-			n := len(ch)
-			vals := make([]float64, n)
-			for i, cs := range ch {
-				vals[i], _ = table.Single(cs)
-			}
-			table.StoreSingle(s, prod.Compute(vals))
-		}
+	for i, v := range V {
+		table.StoreSingle(P.Node(i), v)
 	}
 	sys.Free()
 	return S, tk
@@ -162,98 +87,19 @@ func StoreInference(S SPN, I VarSet, tk int, storage *Storer) (SPN, int) {
 // at the position designated by the ticket tk. Returns S and the ticket used (if tk < 0,
 // StoreMAP creates a new ticket).
 func StoreMAP(S SPN, I VarSet, tk int, storage *Storer) (SPN, int, VarSet) {
-	if len(S.Ch()) == 0 {
-		return nil, -1, nil
-	}
-
 	if tk < 0 {
 		tk = storage.NewTicket()
 	}
 
-	// Get topological order.
-	T := common.Queue{}
-	TopSortTarjan(S, &T)
+	P := Compile(S)
+	V := P.MaxValues(I, nil)
 
 	tab, _ := storage.Table(tk)
-	// Find max values.
-	for !T.Empty() {
-		s := T.Dequeue().(SPN)
-		switch t := s.Type(); t {
-		case "leaf":
-			m := s.Max(I)
-			tab.StoreSingle(s, m)
-		case "sum":
-			sum := s.(*Sum)
-			W := sum.Weights()
-			ch := s.Ch()
-			mv := math.Inf(-1)
-			for i, c := range ch {
-				v, _ := tab.Single(c)
-				u := math.Log(W[i]) + v
-				if u > mv {
-					mv = u
-				}
-			}
-			tab.StoreSingle(s, mv)
-		case "product":
-			ch := s.Ch()
-			var v float64
-			for _, c := range ch {
-				cv, _ := tab.Single(c)
-				v += cv
-			}
-			tab.StoreSingle(s, v)
-		}
+	for i, v := range V {
+		tab.StoreSingle(P.Node(i), v)
 	}
 
-	Q := common.Queue{}
-	V := make(map[SPN]bool)
-	Q.Enqueue(S)
-	V[S] = true
-	M := make(VarSet)
-
-	// Find MAP states.
-	for !Q.Empty() {
-		s := Q.Dequeue().(SPN)
-		switch t := s.Type(); t {
-		case "leaf":
-			N, _ := s.ArgMax(I)
-			for k, v := range N {
-				M[k] = v
-			}
-		case "sum":
-			sum := s.(*Sum)
-			W := sum.Weights()
-			ch := s.Ch()
-			m := math.Inf(-1)
-			var mv []SPN
-			for i, c := range ch {
-				v, _ := tab.Single(c)
-				u := math.Log(W[i]) + v
-				if u > m {
-					mv, m = []SPN{c}, u
-				} else if u == m {
-					mv = append(mv, c)
-				}
-			}
-			// Randomly break ties.
-			mvc := mv[sys.RandIntn(len(mv))]
-			if mvc != nil && !V[mvc] {
-				Q.Enqueue(mvc)
-				V[mvc] = true
-			}
-		case "product":
-			ch := s.Ch()
-			for _, c := range ch {
-				if !V[c] {
-					Q.Enqueue(c)
-					V[c] = true
-				}
-			}
-		}
-	}
-
-	return S, tk, M
+	return S, tk, P.trace(I, V)
 }
 
 func norm(v []float64) {
@@ -358,109 +204,16 @@ func ComputeScope(S SPN) []int {
 	return S.rawSc()
 }
 
-// Complete returns whether the SPN is complete.
+// Complete returns whether the SPN is complete. See Validate for a detailed report.
 func Complete(S SPN) bool {
-	ComputeScope(S)
-	Q := common.Queue{}
-	V := make(map[SPN]bool)
-
-	Q.Enqueue(S)
-	V[S] = true
-
-	for !Q.Empty() {
-		s := Q.Dequeue().(SPN)
-		ch := s.Ch()
-		if s.Type() == "sum" {
-			sc := s.rawSc()
-			v := make(map[int]int)
-			for _, u := range sc {
-				v[u]++
-			}
-			for _, c := range ch {
-				csc := c.rawSc()
-				// Invariant: ComputeScope guarantees that there will be no duplicates.
-				if len(csc) != len(sc) {
-					sys.Printf("len(csc)=%d != len(sc)=%d\n", len(csc), len(sc))
-					sys.Printf("%v\n%v\n", csc, sc)
-					return false
-				}
-				for _, u := range csc {
-					_, e := v[u]
-					if !e {
-						sys.Printf("v[%d] does not exist\n", u)
-						return false
-					}
-					v[u]++
-				}
-			}
-			k := len(ch) + 1
-			for _, u := range v {
-				if u != k {
-					sys.Printf("u=%d != k=%d\n", u, k)
-					return false
-				}
-			}
-		}
-		for _, c := range ch {
-			if !V[S] && c.Type() != "leaf" {
-				Q.Enqueue(c)
-				V[c] = true
-			}
-		}
-	}
-
-	return true
+	R := Validate(S)
+	return !R.Has(Incomplete) && !R.Has(Cycle)
 }
 
-// Decomposable returns whether the SPN is decomposable.
+// Decomposable returns whether the SPN is decomposable. See Validate for a detailed report.
 func Decomposable(S SPN) bool {
-	ComputeScope(S)
-	Q := common.Queue{}
-	V := make(map[SPN]bool)
-
-	Q.Enqueue(S)
-	V[S] = true
-
-	for !Q.Empty() {
-		s := Q.Dequeue().(SPN)
-		ch := s.Ch()
-		if s.Type() == "product" {
-			sc := s.rawSc()
-			v := make(map[int]int)
-			for _, u := range sc {
-				v[u]++
-			}
-			n := len(sc)
-			for _, c := range ch {
-				csc := c.rawSc()
-				// Invariant: ComputeScope guarantees that there will be no duplicates.
-				for _, u := range csc {
-					_, e := v[u]
-					if !e {
-						return false
-					}
-					v[u]++
-				}
-				n -= len(csc)
-			}
-			for _, u := range v {
-				if u != 2 {
-					return false
-				}
-			}
-			if n != 0 {
-				return false
-			}
-		}
-		for _, c := range ch {
-			if !V[S] && c.Type() != "leaf" {
-				Q.Enqueue(c)
-				V[c] = true
-			}
-		}
-	}
-
-	return true
+	R := Validate(S)
+	return !R.Has(NonDecomposable) && !R.Has(Cycle)
 }
 
 // TraceMAP returns the max child index of each sum node in a map. We assume decomposability and
@@ -0,0 +1,279 @@
+package spn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/RenatoGeh/gospn/sys"
+)
+
+// Violation kinds found by Validate.
+const (
+	// Incomplete is a sum node whose children have different scopes.
+	Incomplete ViolationKind = iota
+	// NonDecomposable is a product node whose children have overlapping scopes.
+	NonDecomposable
+	// Unnormalized is a sum node whose weights do not sum to one.
+	Unnormalized
+	// InvalidWeight is a sum node with negative, NaN or infinite weights.
+	InvalidWeight
+	// WeightCount is a sum node whose number of weights differs from its number of children.
+	WeightCount
+	// EmptyScope is a leaf with an empty scope.
+	EmptyScope
+	// Childless is a sum or product node with no children.
+	Childless
+	// Cycle is a node with an edge that closes a directed cycle.
+	Cycle
+	// NilChild is a node with a nil child.
+	NilChild
+)
+
+// ViolationKind is the kind of a structural violation.
+type ViolationKind int
+
+// String returns a textual representation of this kind.
+func (k ViolationKind) String() string {
+	switch k {
+	case Incomplete:
+		return "incomplete"
+	case NonDecomposable:
+		return "non-decomposable"
+	case Unnormalized:
+		return "unnormalized weights"
+	case InvalidWeight:
+		return "invalid weight"
+	case WeightCount:
+		return "weight count mismatch"
+	case EmptyScope:
+		return "empty scope"
+	case Childless:
+		return "childless inner node"
+	case Cycle:
+		return "cycle"
+	case NilChild:
+		return "nil child"
+	}
+	return "unknown"
+}
+
+// Violation is a structural problem found at a node.
+type Violation struct {
+	// Kind of violation.
+	Kind ViolationKind
+	// ID of the node (see SPN.ID).
+	ID int
+	// Node is the violating node.
+	Node SPN
+	// Scope of the node. Nil if it could not be computed (e.g. the graph has cycles).
+	Scope []int
+	// Msg describes the violation.
+	Msg string
+}
+
+// String returns a textual representation of this violation.
+func (v Violation) String() string {
+	return fmt.Sprintf("node %d (%s) %v: %s: %s", v.ID, v.Node.Type(), v.Scope, v.Kind, v.Msg)
+}
+
+// Report is the result of a structural validation.
+type Report struct {
+	// Nodes is the number of nodes visited.
+	Nodes int
+	// Violations found, ordered by node ID.
+	Violations []Violation
+}
+
+// Valid returns whether no violation was found.
+func (r *Report) Valid() bool { return len(r.Violations) == 0 }
+
+// Has returns whether at least one violation of kind k was found.
+func (r *Report) Has(k ViolationKind) bool {
+	for _, v := range r.Violations {
+		if v.Kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns nil if no violations were found, and an error listing every violation otherwise.
+func (r *Report) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	return errors.New(r.String())
+}
+
+// String returns a textual representation of this report, with one violation per line.
+func (r *Report) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d nodes, %d violations", r.Nodes, len(r.Violations))
+	for _, v := range r.Violations {
+		fmt.Fprintf(&b, "\n  %s", v.String())
+	}
+	return b.String()
+}
+
+func (r *Report) add(k ViolationKind, id int, S SPN, sc []int, msg string, args ...interface{}) {
+	r.Violations = append(r.Violations, Violation{k, id, S, sc, fmt.Sprintf(msg, args...)})
+}
+
+// Validate checks the structure of SPN S, returning a report of every violating node. Validate
+// looks for incomplete sum nodes, non-decomposable product nodes, sum nodes with non-normalized,
+// negative or NaN weights or with a mismatching number of weights, leaves with empty scope,
+// inner nodes with no children, directed cycles and nil children. Weights are considered
+// normalized if they sum to one up to sys.EqualEpsilon. Scope related checks are skipped if the
+// graph has cycles.
+//
+// Validate does not change the graph (not even cached scopes), except for assigning IDs to nodes
+// that had none.
+func Validate(S SPN) *Report {
+	R := &Report{}
+	// Iterative DFS with white (absent), gray (1) and black (2) colors.
+	const gray, black = 1, 2
+	C := make(map[SPN]int)
+	var O []SPN
+	type frame struct {
+		s SPN
+		i int
+	}
+	var cyclic bool
+	var cycles [][2]SPN
+	T := []frame{{S, 0}}
+	C[S] = gray
+	for len(T) > 0 {
+		f := &T[len(T)-1]
+		ch := f.s.Ch()
+		if f.i == len(ch) {
+			C[f.s] = black
+			O = append(O, f.s)
+			T = T[:len(T)-1]
+			continue
+		}
+		c := ch[f.i]
+		f.i++
+		if c == nil {
+			continue
+		}
+		switch C[c] {
+		case gray:
+			cyclic = true
+			cycles = append(cycles, [2]SPN{f.s, c})
+		case 0:
+			C[c] = gray
+			T = append(T, frame{c, 0})
+		}
+	}
+	R.Nodes = len(O)
+
+	Sc := make(map[SPN][]int)
+	for _, s := range O {
+		id := s.ID()
+		ch := s.Ch()
+		for i, c := range ch {
+			if c == nil {
+				R.add(NilChild, id, s, nil, "child %d is nil", i)
+			}
+		}
+		for _, e := range cycles {
+			if e[0] == s {
+				R.add(Cycle, id, s, nil, "edge to node %d closes a cycle", e[1].ID())
+			}
+		}
+		t := s.Type()
+		if t == "sum" {
+			validateWeights(R, id, s.(*Sum))
+		}
+		if t != "leaf" && len(ch) == 0 {
+			R.add(Childless, id, s, nil, "%s node has no children", t)
+		}
+		if cyclic {
+			continue
+		}
+		var sc []int
+		if len(ch) == 0 {
+			sc = uniqueSorted(s.Sc())
+			if len(sc) == 0 && t != "sum" && t != "product" {
+				R.add(EmptyScope, id, s, sc, "leaf has empty scope")
+			}
+			Sc[s] = sc
+			continue
+		}
+		n := make(map[int]int)
+		for _, c := range ch {
+			if c != nil {
+				for _, v := range Sc[c] {
+					n[v]++
+				}
+			}
+		}
+		for v := range n {
+			sc = append(sc, v)
+		}
+		sort.Ints(sc)
+		Sc[s] = sc
+		switch t {
+		case "sum":
+			for i, c := range ch {
+				if c != nil && len(Sc[c]) != len(sc) {
+					R.add(Incomplete, id, s, sc, "child %d (node %d) has scope %v", i, c.ID(), Sc[c])
+				}
+			}
+		case "product":
+			var o []int
+			for _, v := range sc {
+				if n[v] > 1 {
+					o = append(o, v)
+				}
+			}
+			if len(o) > 0 {
+				R.add(NonDecomposable, id, s, sc, "variables %v appear in more than one child", o)
+			}
+		}
+	}
+	for i := range R.Violations {
+		if v := &R.Violations[i]; v.Scope == nil && !cyclic {
+			v.Scope = Sc[v.Node]
+		}
+	}
+	sort.SliceStable(R.Violations, func(i, j int) bool {
+		return R.Violations[i].ID < R.Violations[j].ID
+	})
+	return R
+}
+
+func validateWeights(R *Report, id int, s *Sum) {
+	W := s.Weights()
+	if n, m := len(W), len(s.Ch()); n != m {
+		R.add(WeightCount, id, s, nil, "%d weights for %d children", n, m)
+	}
+	var z float64
+	ok := true
+	for i, w := range W {
+		if math.IsNaN(w) || math.IsInf(w, 0) || w < 0 {
+			R.add(InvalidWeight, id, s, nil, "weight %d is %v", i, w)
+			ok = false
+		}
+		z += w
+	}
+	if ok && len(W) > 0 && math.Abs(z-1) > sys.EqualEpsilon {
+		R.add(Unnormalized, id, s, nil, "weights sum to %v", z)
+	}
+}
+
+func uniqueSorted(sc []int) []int {
+	M := make(map[int]bool)
+	var u []int
+	for _, v := range sc {
+		if !M[v] {
+			M[v] = true
+			u = append(u, v)
+		}
+	}
+	sort.Ints(u)
+	return u
+}
@@ -0,0 +1,48 @@
+package spn
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	if R := Validate(mixtureSPN()); !R.Valid() || R.Err() != nil || R.Nodes != 7 {
+		t.Errorf("Expected valid SPN with 7 nodes, got:\n%s", R)
+	}
+	if !Complete(mixtureSPN()) || !Decomposable(mixtureSPN()) {
+		t.Error("Expected complete and decomposable SPN.")
+	}
+	// sampleSPN's root has children with different scopes.
+	if S := sampleSPN(); Complete(S) || !Decomposable(S) {
+		t.Error("Expected incomplete and decomposable SPN.")
+	}
+
+	R := NewSum()
+	P := NewProduct()
+	A, B := NewMultinomial(0, []float64{0.5, 0.5}), NewMultinomial(1, []float64{0.5, 0.5})
+	P.AddChild(A)
+	P.AddChild(A)
+	P.AddChild(B)
+	Z := NewSum()
+	Z.AddChildW(B, math.NaN())
+	Z.AddChild(nil)
+	R.AddChildW(P, 0.5)
+	R.AddChildW(Z, 0.2)
+	R.AddChildW(NewProduct(), 0.1)
+	V := Validate(R)
+	for _, k := range []ViolationKind{Incomplete, NonDecomposable, Unnormalized, InvalidWeight,
+		WeightCount, Childless, NilChild} {
+		if !V.Has(k) {
+			t.Errorf("Expected violation %q, got none.", k)
+		}
+	}
+	if V.Has(Cycle) || V.Has(EmptyScope) {
+		t.Errorf("Expected no cycles or empty scopes, got:\n%s", V)
+	}
+
+	// Introduce a cycle.
+	P.AddChild(R)
+	if V := Validate(R); !V.Has(Cycle) {
+		t.Errorf("Expected cycle, got:\n%s", V)
+	}
+}